@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	nethttp "net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	benchScanSizeMetric = "oo_jaeger_openobserve_scan_size_total"
+	benchSearchesMetric = "oo_jaeger_openobserve_searches_total"
+)
+
+type benchResult struct {
+	latency time.Duration
+	status  int // 0 means the request failed
+}
+
+// runBench replays the queries of a file, or the ones synthesized from the services of the proxy, at a
+// target qps and reports the latency percentiles and the OpenObserve searches and scan size they caused.
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	target := fs.String("target", "http://localhost:8080", "base url of the proxy")
+	queries := fs.String("queries", "", "file of the recorded queries, one path with query string per line, e.g. /api/traces?service=a&lookback=1h; empty means synthesized from /api/services")
+	qps := fs.Float64("qps", 5, "target requests per second")
+	duration := fs.Duration("duration", 30*time.Second, "how long to send requests")
+	concurrency := fs.Int("concurrency", 32, "max in-flight requests, the requests over it are dropped and counted")
+	auth := fs.String("auth", "", "Authorization header of the requests, e.g. \"Basic dXNlcjpwYXNz\"")
+	lookback := fs.String("lookback", "1h", "lookback of the synthesized trace searches")
+	fs.Parse(args)
+
+	if *qps <= 0 || *concurrency <= 0 {
+		fmt.Println("error: qps and concurrency must be > 0")
+		return 2
+	}
+
+	base := strings.TrimRight(*target, "/")
+	client := &nethttp.Client{Timeout: time.Minute}
+	get := func(path string) (*nethttp.Response, error) {
+		req, err := nethttp.NewRequest(nethttp.MethodGet, base+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		if *auth != "" {
+			req.Header.Set("Authorization", *auth)
+		}
+		return client.Do(req)
+	}
+
+	var paths []string
+	var err error
+	if *queries != "" {
+		paths, err = readBenchQueries(*queries)
+	} else {
+		paths, err = synthesizeBenchQueries(get, *lookback)
+	}
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		return 1
+	}
+	if len(paths) == 0 {
+		fmt.Println("error: no queries to replay")
+		return 1
+	}
+
+	before := scrapeBenchMetrics(get)
+
+	results := make([]benchResult, 0, int(*qps*duration.Seconds())+1)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	inflight := make(chan struct{}, *concurrency)
+	dropped := 0
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / *qps))
+	defer ticker.Stop()
+	start := time.Now()
+	for i := 0; time.Since(start) < *duration; i++ {
+		<-ticker.C
+		select {
+		case inflight <- struct{}{}:
+		default:
+			dropped++
+			continue
+		}
+
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			defer func() { <-inflight }()
+
+			r := benchResult{}
+			t := time.Now()
+			resp, err := get(path)
+			if err == nil {
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				r.status = resp.StatusCode
+			}
+			r.latency = time.Since(t)
+
+			mu.Lock()
+			results = append(results, r)
+			mu.Unlock()
+		}(paths[i%len(paths)])
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	after := scrapeBenchMetrics(get)
+	printBenchReport(results, dropped, elapsed, before, after)
+	return 0
+}
+
+func readBenchQueries(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	paths := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, "/") {
+			return nil, fmt.Errorf("%s: query %q must be a path starting with /", file, line)
+		}
+		paths = append(paths, line)
+	}
+	return paths, scanner.Err()
+}
+
+// synthesizeBenchQueries builds the services, operations and trace search queries of the UI for every service of the proxy.
+func synthesizeBenchQueries(get func(string) (*nethttp.Response, error), lookback string) ([]string, error) {
+	resp, err := get("/api/services")
+	if err != nil {
+		return nil, fmt.Errorf("fetching the services to synthesize the queries: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != nethttp.StatusOK {
+		return nil, fmt.Errorf("fetching the services to synthesize the queries: status %d", resp.StatusCode)
+	}
+
+	var services struct {
+		Data []string `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&services); err != nil {
+		return nil, fmt.Errorf("decoding the services: %w", err)
+	}
+
+	paths := []string{"/api/services"}
+	for _, service := range services.Data {
+		paths = append(paths,
+			"/api/services/"+url.PathEscape(service)+"/operations",
+			"/api/traces?"+url.Values{"service": {service}, "lookback": {lookback}, "limit": {"20"}}.Encode(),
+		)
+	}
+	return paths, nil
+}
+
+// scrapeBenchMetrics reads the OpenObserve search counters of the proxy, nil if /metrics is not reachable.
+func scrapeBenchMetrics(get func(string) (*nethttp.Response, error)) map[string]float64 {
+	resp, err := get("/metrics")
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != nethttp.StatusOK {
+		return nil
+	}
+
+	values := make(map[string]float64)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		for _, name := range []string{benchScanSizeMetric, benchSearchesMetric} {
+			if !strings.HasPrefix(line, name) {
+				continue
+			}
+			fields := strings.Fields(line)
+			if v, err := strconv.ParseFloat(fields[len(fields)-1], 64); err == nil {
+				values[name] += v
+			}
+		}
+	}
+	return values
+}
+
+func printBenchReport(results []benchResult, dropped int, elapsed time.Duration, before, after map[string]float64) {
+	statuses := make(map[int]int)
+	latencies := make([]time.Duration, 0, len(results))
+	failed := 0
+	for _, r := range results {
+		statuses[r.status]++
+		if r.status == 0 || r.status >= nethttp.StatusInternalServerError {
+			failed++
+		}
+		latencies = append(latencies, r.latency)
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	percentile := func(p float64) float64 {
+		if len(latencies) == 0 {
+			return 0
+		}
+		i := int(math.Ceil(p*float64(len(latencies)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return float64(latencies[i].Microseconds()) / 1e3
+	}
+
+	fmt.Printf("requests: %d in %s (%.1f/s), failed: %d, dropped: %d\n",
+		len(results), elapsed.Round(time.Millisecond), float64(len(results))/elapsed.Seconds(), failed, dropped)
+	fmt.Printf("latency ms: p50 %.1f, p90 %.1f, p99 %.1f, max %.1f\n", percentile(0.5), percentile(0.9), percentile(0.99), percentile(1))
+
+	codes := make([]int, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	parts := make([]string, 0, len(codes))
+	for _, code := range codes {
+		name := strconv.Itoa(code)
+		if code == 0 {
+			name = "failed"
+		}
+		parts = append(parts, fmt.Sprintf("%s: %d", name, statuses[code]))
+	}
+	fmt.Printf("status: %s\n", strings.Join(parts, ", "))
+
+	if before == nil || after == nil {
+		fmt.Println("openobserve: /metrics of the proxy not reachable, scan cost unknown")
+		return
+	}
+	searches := after[benchSearchesMetric] - before[benchSearchesMetric]
+	scanned := after[benchScanSizeMetric] - before[benchScanSizeMetric]
+	perRequest := 0.0
+	if len(results) > 0 {
+		perRequest = scanned / float64(len(results))
+	}
+	fmt.Printf("openobserve: %.0f searches, scan_size %.1f MB (%.2f MB per request), includes the other clients of the proxy\n", searches, scanned, perRequest)
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/jaegertracing/jaeger/model"
+	"github.com/jaegertracing/jaeger/plugin/storage/es/spanstore/dbmodel"
+	"io"
+	"log"
+	nethttp "net/http"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/jaeger_service"
+	"openobserve-jaeger/internal/openobserve_service"
+	"os"
+	"strings"
+	"time"
+)
+
+const importRetries = 3
+
+// importCheckpoint is the resume point of an import, the sort values of the last ingested span.
+type importCheckpoint struct {
+	SearchAfter []interface{} `json:"search_after"`
+	Spans       int           `json:"spans"`
+}
+
+// runImport copies the spans of the jaeger-span-* Elasticsearch indices into OpenObserve as OTLP, in startTime
+// order with a checkpoint file after every batch, so an interrupted import resumes where it stopped.
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	conf := fs.String("conf", "", "config file with the openobserve addr, auth and org")
+	es := fs.String("es", "http://localhost:9200", "base url of Elasticsearch")
+	esAuth := fs.String("es-auth", "", "Authorization header of the Elasticsearch requests, e.g. \"Basic dXNlcjpwYXNz\"")
+	index := fs.String("index", "jaeger-span-*", "span indices")
+	stream := fs.String("stream", "default", "openobserve traces stream")
+	batch := fs.Int("batch", 1000, "spans per Elasticsearch page and openobserve request")
+	rate := fs.Float64("rate", 0, "max spans per second, 0 means no limit")
+	checkpoint := fs.String("checkpoint", "import.checkpoint", "resume file, removed when the import completes")
+	fs.Parse(args)
+
+	cfg, err := config.Load(*conf)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		return 1
+	}
+	config.Set(cfg)
+	if *batch <= 0 || *rate < 0 {
+		fmt.Println("error: batch must be > 0 and rate >= 0")
+		return 2
+	}
+
+	cp, err := readCheckpoint(*checkpoint)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		return 1
+	}
+	if cp.SearchAfter != nil {
+		log.Printf("resuming after %d spans from %s", cp.Spans, *checkpoint)
+	}
+
+	reader := &esSpanReader{
+		url:    strings.TrimRight(*es, "/") + "/" + *index + "/_search",
+		auth:   *esAuth,
+		size:   *batch,
+		client: &nethttp.Client{Timeout: time.Minute},
+	}
+	ooservice := openobserve_service.NewOpenObserveService()
+	converter := jaeger_service.NewToDomain("@")
+
+	start, imported := time.Now(), 0
+	for {
+		dbSpans, after, err := reader.next(cp.SearchAfter)
+		if err != nil {
+			fmt.Printf("error: reading %s: %v\n", *index, err)
+			return 1
+		}
+		if len(dbSpans) == 0 {
+			break
+		}
+
+		spans := make([]*model.Span, 0, len(dbSpans))
+		for i := range dbSpans {
+			span, err := converter.SpanToDomain(&dbSpans[i])
+			if err != nil {
+				log.Printf("span %s skipped: %v", dbSpans[i].SpanID, err)
+				continue
+			}
+			spans = append(spans, span)
+		}
+
+		if len(spans) > 0 {
+			body, err := json.Marshal(jaeger_service.ToOTLP(spans))
+			if err != nil {
+				fmt.Printf("error: %v\n", err)
+				return 1
+			}
+			if err := ingestWithRetry(ooservice, *stream, body); err != nil {
+				fmt.Printf("error: ingesting into %s: %v, rerun to resume from %s\n", *stream, err, *checkpoint)
+				return 1
+			}
+		}
+
+		imported += len(spans)
+		cp = importCheckpoint{SearchAfter: after, Spans: cp.Spans + len(dbSpans)}
+		if err := writeCheckpoint(*checkpoint, cp); err != nil {
+			fmt.Printf("error: %v\n", err)
+			return 1
+		}
+		log.Printf("imported %d spans, %d read in total", imported, cp.Spans)
+
+		if *rate > 0 {
+			// stay under rate spans per second on average
+			if wait := time.Duration(float64(imported)/(*rate)*float64(time.Second)) - time.Since(start); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+	}
+
+	os.Remove(*checkpoint)
+	fmt.Printf("import done: %d spans in %s\n", imported, time.Since(start).Round(time.Second))
+	return 0
+}
+
+func ingestWithRetry(ooservice *openobserve_service.OpenObserveService, stream string, body []byte) error {
+	var err error
+	for attempt := 0; attempt < importRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 2 * time.Second)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		err = ooservice.IngestTraces(ctx, stream, "application/json", body)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		log.Printf("ingest attempt %d failed: %v", attempt+1, err)
+	}
+	return err
+}
+
+// esSpanReader pages through the span indices in startTime, spanID order with search_after.
+type esSpanReader struct {
+	url    string
+	auth   string
+	size   int
+	client *nethttp.Client
+}
+
+// next returns the spans after the sort values after (nil means from the start) and the sort values
+// of the last one.
+func (r *esSpanReader) next(after []interface{}) ([]dbmodel.Span, []interface{}, error) {
+	query := map[string]interface{}{
+		"size": r.size,
+		"sort": []map[string]string{{"startTime": "asc"}, {"spanID": "asc"}},
+	}
+	if after != nil {
+		query["search_after"] = after
+	}
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := nethttp.NewRequest(nethttp.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.auth != "" {
+		req.Header.Set("Authorization", r.auth)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != nethttp.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, nil, fmt.Errorf("status %d: %s", resp.StatusCode, msg)
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source dbmodel.Span  `json:"_source"`
+				Sort   []interface{} `json:"sort"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	dec := json.NewDecoder(resp.Body)
+	// the tag values keep their type, see ToDomain.convertTagField
+	dec.UseNumber()
+	if err := dec.Decode(&result); err != nil {
+		return nil, nil, err
+	}
+
+	hits := result.Hits.Hits
+	spans := make([]dbmodel.Span, 0, len(hits))
+	for _, hit := range hits {
+		spans = append(spans, hit.Source)
+	}
+	if len(hits) == 0 {
+		return spans, after, nil
+	}
+	return spans, hits[len(hits)-1].Sort, nil
+}
+
+func readCheckpoint(path string) (importCheckpoint, error) {
+	var cp importCheckpoint
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return cp, err
+	}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return cp, fmt.Errorf("checkpoint %s: %w", path, err)
+	}
+	return cp, nil
+}
+
+// writeCheckpoint replaces the checkpoint file atomically, a crash leaves the old or the new one.
+func writeCheckpoint(path string, cp importCheckpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
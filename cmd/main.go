@@ -1,29 +1,62 @@
 package main
 
 import (
-	"flag"
-	"gopkg.in/yaml.v3"
-	"io/ioutil"
-	"log"
-	"openobserve-jaeger/internal/config"
-	"openobserve-jaeger/internal/transport/http"
+	"fmt"
+	"openobserve-jaeger/configs"
+	"openobserve-jaeger/internal/version"
+	"os"
+	"strings"
+	// tz of the api responses works in images without a zoneinfo database
+	_ "time/tzdata"
 )
 
-var conf = flag.String("conf", "", "set your config file path. Example: ./configs/config.yaml")
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) int
+}
+
+var commands = []command{
+	{name: "serve", usage: "serve the jaeger query api (default), -conf <file>", run: runServe},
+	{name: "validate-config", usage: "validate the config and check OpenObserve connectivity, auth and streams, -conf <file>", run: runValidateConfig},
+	{name: "print-default-config", usage: "print the example config", run: runPrintDefaultConfig},
+	{name: "version", usage: "print the version", run: runVersion},
+	{name: "bench", usage: "replay queries against a running proxy at a target qps, -target <url> -queries <file> -qps <n> -duration <d>", run: runBench},
+	{name: "import", usage: "copy the spans of the jaeger Elasticsearch indices into OpenObserve, resumable, -conf <file> -es <url> -index <pattern> -rate <spans/s>", run: runImport},
+}
 
 func main() {
-	flag.Parse()
-	data, err := ioutil.ReadFile(*conf)
-	if err != nil {
-		log.Fatalf("error: %v", err)
+	// no subcommand, e.g. the flags only "-conf configs/config.yaml", means serve
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			os.Exit(cmd.run(args))
+		}
 	}
 
-	err = yaml.Unmarshal(data, &config.Cfg)
-	if err != nil {
-		log.Fatalf("error: %v", err)
+	if name != "help" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
 	}
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\ncommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-22s %s\n", cmd.name, cmd.usage)
+	}
+	if name != "help" {
+		os.Exit(2)
+	}
+}
+
+func runPrintDefaultConfig(args []string) int {
+	os.Stdout.Write(configs.Default)
+	return 0
+}
 
-	r := http.NewHTTPServer()
-	// Listen and Server in 0.0.0.0:8080
-	r.Run(":8080")
+func runVersion(args []string) int {
+	info := version.Get()
+	fmt.Printf("openobserve-jaeger %s (commit %s, built %s, %s)\n", info.Version, info.Commit, info.BuildDate, info.GoVersion)
+	return 0
 }
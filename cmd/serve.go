@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	nethttp "net/http"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/openobserve_service"
+	"openobserve-jaeger/internal/tracing"
+	"openobserve-jaeger/internal/transport/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const selfCheckTimeout = 10 * time.Second
+
+// runServe loads the config and serves the jaeger query api until SIGINT or SIGTERM.
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	conf := fs.String("conf", "", "set your config file path. Example: ./configs/config.yaml")
+	// kept for the existing deployments, same as the validate-config command
+	validate := fs.Bool("validate", false, "validate the config and check OpenObserve connectivity, auth and streams, then exit")
+	fs.Parse(args)
+
+	cfg, err := config.Load(*conf)
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	config.Set(cfg)
+
+	if *validate {
+		return runValidate()
+	}
+
+	if err := config.Validate(cfg); err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	if err := selfCheck(); err != nil {
+		log.Printf("startup self-check failed, queries will fail until it's fixed: %v", err)
+	}
+
+	tracing.Init(cfg.Tracing)
+
+	go config.Watch(*conf, time.Duration(cfg.ReloadInterval)*time.Second)
+
+	if cfg.Debug.Enabled {
+		debug := http.NewDebugServer(cfg.Debug)
+		go func() {
+			log.Printf("debug endpoints on %s", debug.Addr)
+			if err := debug.ListenAndServe(); err != nil {
+				log.Printf("debug server: %v", err)
+			}
+		}()
+	}
+
+	r, err := http.NewHTTPServer()
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	// Listen and Server in 0.0.0.0:8080
+	srv := &nethttp.Server{Addr: ":8080", Handler: r}
+	if srv.TLSConfig, err = cfg.TLS.ServerTLS(); err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	go func() {
+		var err error
+		if srv.TLSConfig != nil {
+			// the certificates are in srv.TLSConfig
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != nethttp.ErrServerClosed {
+			log.Fatalf("error: %v", err)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+	shutdown(srv)
+	return 0
+}
+
+// shutdown stops accepting connections and waits for the in-flight requests, at most shutdown_timeout.
+func shutdown(srv *nethttp.Server) {
+	ctx := context.Background()
+	if timeout := config.Get().ShutdownTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+
+	log.Printf("shutting down, draining in-flight requests")
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("shutdown: %v", err)
+	}
+	http.Shutdown(ctx)
+}
+
+// runValidateConfig checks the config file and the OpenObserve connectivity, auth and streams.
+func runValidateConfig(args []string) int {
+	fs := flag.NewFlagSet("validate-config", flag.ExitOnError)
+	conf := fs.String("conf", "", "set your config file path. Example: ./configs/config.yaml")
+	fs.Parse(args)
+
+	cfg, err := config.Load(*conf)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		return 1
+	}
+	config.Set(cfg)
+	return runValidate()
+}
+
+func runValidate() int {
+	if err := config.Validate(*config.Get()); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Println("config ok")
+
+	if err := selfCheck(); err != nil {
+		fmt.Printf("openobserve check failed: %v\n", err)
+		return 1
+	}
+	fmt.Println("openobserve ok")
+	return 0
+}
+
+func selfCheck() error {
+	ctx, cancel := context.WithTimeout(context.Background(), selfCheckTimeout)
+	defer cancel()
+	return openobserve_service.NewOpenObserveService().SelfCheck(ctx)
+}
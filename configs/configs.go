@@ -0,0 +1,8 @@
+package configs
+
+import _ "embed"
+
+// Default is the example config.yaml, printed by the print-default-config command.
+//
+//go:embed config.yaml
+var Default []byte
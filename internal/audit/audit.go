@@ -0,0 +1,103 @@
+// Package audit records the searches of the UI with the OpenObserve queries they ran, and flags the slow ones.
+package audit
+
+import (
+	"context"
+	"openobserve-jaeger/internal/config"
+	"sync"
+)
+
+// ContextKey is the key of the request *Entry, gin.Context resolves string keys set by ctx.Set in Value.
+const ContextKey = "audit"
+
+const defaultSlowQuery = 4000 // millisecond
+
+// Query is an OpenObserve search run for the audited request.
+type Query struct {
+	API        string `json:"api"`
+	Stream     string `json:"stream"`
+	SearchType string `json:"search_type"`
+	SQL        string `json:"sql"`
+	StartTime  int64  `json:"start_time"`      // unix microseconds
+	EndTime    int64  `json:"end_time"`        // unix microseconds
+	Took       int    `json:"took_ms"`         // took_detail.total
+	WaitQueue  int    `json:"wait_queue_ms"`   // took_detail.wait_queue
+	ScanSize   int    `json:"scan_size_mb"`    // scan_size
+	Hits       int    `json:"hits"`            // rows returned
+	SessionID  string `json:"session_id"`      // the trace_id of the search in the OpenObserve logs
+	Slow       bool   `json:"slow,omitempty"`  // took longer than audit.slow_query
+	Error      string `json:"error,omitempty"` // the failed search
+}
+
+// Record is the audit line of a request.
+type Record struct {
+	Timestamp int64   `json:"_timestamp"` // unix microseconds of the request start, the time column of OpenObserve
+	RequestID string  `json:"request_id"`
+	User      string  `json:"user,omitempty"`
+	IP        string  `json:"ip"`
+	Route     string  `json:"route"`
+	Path      string  `json:"path"`
+	Params    string  `json:"params"` // the raw query string
+	Status    int     `json:"status"`
+	Results   int     `json:"results"`     // traces or items of the response
+	Duration  float64 `json:"duration_ms"` // of the whole request
+	Slow      bool    `json:"slow"`        // one of the queries was slow
+	Queries   []Query `json:"queries"`
+}
+
+// Entry collects the queries and the result of an audited request.
+type Entry struct {
+	mu      sync.Mutex
+	queries []Query
+	results int
+}
+
+// AddQuery records an OpenObserve search of the request, a nil Entry (no audit) ignores it.
+func (e *Entry) AddQuery(q Query) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	e.queries = append(e.queries, q)
+	e.mu.Unlock()
+}
+
+// SetResults records the result count of the response.
+func (e *Entry) SetResults(n int) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	e.results = n
+	e.mu.Unlock()
+}
+
+// Fill copies the collected queries and result count into r.
+func (e *Entry) Fill(r *Record) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	r.Queries = append([]Query(nil), e.queries...)
+	r.Results = e.results
+	for _, q := range r.Queries {
+		r.Slow = r.Slow || q.Slow
+	}
+}
+
+// FromContext returns the Entry of the request, nil if it's not audited.
+func FromContext(ctx context.Context) *Entry {
+	if ctx == nil {
+		return nil
+	}
+	e, _ := ctx.Value(ContextKey).(*Entry)
+	return e
+}
+
+// IsSlow reports whether an OpenObserve search of took milliseconds exceeds audit.slow_query, default 4000 ms.
+// The slow searches are logged whether the audit is enabled or not.
+func IsSlow(took int) bool {
+	threshold := config.Get().Audit.SlowQuery
+	if threshold <= 0 {
+		threshold = defaultSlowQuery
+	}
+	return took > threshold
+}
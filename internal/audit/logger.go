@@ -0,0 +1,178 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"openobserve-jaeger/internal/config"
+	"os"
+	"time"
+)
+
+const (
+	SinkFile        = "file"
+	SinkOpenObserve = "openobserve"
+
+	defaultFile       = "audit.log"
+	defaultMaxSize    = 100 // MB
+	defaultMaxBackups = 5
+	defaultStream     = "jaeger_audit"
+
+	queueSize     = 1024
+	batchSize     = 100
+	flushInterval = time.Second
+	ingestTimeout = 10 * time.Second
+)
+
+// IngestFunc posts a JSON array of records to an OpenObserve log stream.
+type IngestFunc func(ctx context.Context, stream string, body []byte) error
+
+type sink interface {
+	write(records []Record) error
+}
+
+// Logger writes the records to the audit sink in the background, the records are dropped
+// when the sink can't keep up so the requests never wait for it.
+type Logger struct {
+	sink  sink
+	queue chan Record
+}
+
+// NewLogger starts the logger of the configured sink, ingest is used by the openobserve sink.
+func NewLogger(cfg config.AuditConfig, ingest IngestFunc) (*Logger, error) {
+	l := &Logger{queue: make(chan Record, queueSize)}
+	switch cfg.Sink {
+	case "", SinkFile:
+		file := &fileSink{path: cfg.File, maxSize: int64(cfg.MaxSize) << 20, maxBackups: cfg.MaxBackups}
+		if file.path == "" {
+			file.path = defaultFile
+		}
+		if file.maxSize <= 0 {
+			file.maxSize = defaultMaxSize << 20
+		}
+		if file.maxBackups <= 0 {
+			file.maxBackups = defaultMaxBackups
+		}
+		if err := file.open(); err != nil {
+			return nil, err
+		}
+		l.sink = file
+	case SinkOpenObserve:
+		stream := cfg.Stream
+		if stream == "" {
+			stream = defaultStream
+		}
+		l.sink = &streamSink{stream: stream, ingest: ingest}
+	default:
+		return nil, fmt.Errorf("unknown audit sink %q", cfg.Sink)
+	}
+
+	go l.run()
+	return l, nil
+}
+
+// Log queues r for the sink.
+func (l *Logger) Log(r Record) {
+	select {
+	case l.queue <- r:
+	default:
+	}
+}
+
+func (l *Logger) run() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	records := make([]Record, 0, batchSize)
+	for {
+		select {
+		case r := <-l.queue:
+			records = append(records, r)
+			if len(records) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(records) == 0 {
+				continue
+			}
+		}
+
+		if err := l.sink.write(records); err != nil {
+			log.Printf("audit: writing %d records err: %v", len(records), err)
+		}
+		records = make([]Record, 0, batchSize)
+	}
+}
+
+// fileSink writes JSON lines, the file is rotated to path.1 ... path.<maxBackups> at maxSize bytes.
+type fileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	file *os.File
+	size int64
+}
+
+func (s *fileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	s.file, s.size = f, info.Size()
+	return nil
+}
+
+func (s *fileSink) write(records []Record) error {
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+		if s.size+int64(len(line)) > s.maxSize && s.size > 0 {
+			if err := s.rotate(); err != nil {
+				return err
+			}
+		}
+		n, err := s.file.Write(line)
+		s.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileSink) rotate() error {
+	s.file.Close()
+	for i := s.maxBackups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+	}
+	if err := os.Rename(s.path, s.path+".1"); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+// streamSink ingests the records into an OpenObserve log stream.
+type streamSink struct {
+	stream string
+	ingest IngestFunc
+}
+
+func (s *streamSink) write(records []Record) error {
+	body, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ingestTimeout)
+	defer cancel()
+	return s.ingest(ctx, s.stream, body)
+}
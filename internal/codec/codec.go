@@ -0,0 +1,28 @@
+// Package codec is the JSON encoder of the trace responses and the OpenObserve results.
+// It is encoding/json by default, building with -tags sonic swaps in bytedance/sonic,
+// which gin's own renderer also uses with that tag.
+package codec
+
+import "io"
+
+// Encoder writes JSON values to a stream
+type Encoder interface {
+	Encode(v interface{}) error
+}
+
+// Name is the encoder of the build
+func Name() string {
+	return name
+}
+
+func Marshal(v interface{}) ([]byte, error) {
+	return marshal(v)
+}
+
+func Unmarshal(data []byte, v interface{}) error {
+	return unmarshal(data, v)
+}
+
+func NewEncoder(w io.Writer) Encoder {
+	return newEncoder(w)
+}
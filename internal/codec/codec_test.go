@@ -0,0 +1,98 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"io/ioutil"
+	"testing"
+)
+
+// benchTrace is a trace of n spans with the tags and logs of a typical instrumented service.
+func benchTrace(n int) *ui.Trace {
+	trace := &ui.Trace{
+		TraceID:   "4bf92f3577b34da6a3ce929d0e0e4736",
+		Spans:     make([]ui.Span, n),
+		Processes: map[ui.ProcessID]ui.Process{"p1": {ServiceName: "checkout", Tags: []ui.KeyValue{{Key: "hostname", Type: ui.StringType, Value: "checkout-7d9f"}}}},
+		Warnings:  []string{},
+	}
+	for i := range trace.Spans {
+		trace.Spans[i] = ui.Span{
+			TraceID:       trace.TraceID,
+			SpanID:        ui.SpanID(fmt.Sprintf("%016x", i+1)),
+			OperationName: "GET /api/orders/:id",
+			References:    []ui.Reference{{RefType: ui.ChildOf, TraceID: trace.TraceID, SpanID: ui.SpanID(fmt.Sprintf("%016x", i/2+1))}},
+			StartTime:     1700000000000000 + uint64(i)*10,
+			Duration:      1500,
+			Tags: []ui.KeyValue{
+				{Key: "http.method", Type: ui.StringType, Value: "GET"},
+				{Key: "http.status_code", Type: ui.Int64Type, Value: int64(200)},
+				{Key: "http.url", Type: ui.StringType, Value: "https://shop.example.com/api/orders/12345?expand=items"},
+				{Key: "error", Type: ui.BoolType, Value: false},
+			},
+			Logs: []ui.Log{{Timestamp: 1700000000000100, Fields: []ui.KeyValue{
+				{Key: "event", Type: ui.StringType, Value: "cache miss"},
+				{Key: "key", Type: ui.StringType, Value: "order:12345"},
+			}}},
+			ProcessID: "p1",
+			Warnings:  []string{},
+		}
+	}
+	return trace
+}
+
+// BenchmarkMarshalTrace encodes a large trace with the encoder of the build, compare it to
+// BenchmarkMarshalTraceStd with and without -tags sonic.
+func BenchmarkMarshalTrace(b *testing.B) {
+	trace := benchTrace(10000)
+	b.Run(name, func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, err := Marshal(trace)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.SetBytes(int64(len(data)))
+		}
+	})
+}
+
+func BenchmarkMarshalTraceStd(b *testing.B) {
+	trace := benchTrace(10000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, err := json.Marshal(trace)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(len(data)))
+	}
+}
+
+// BenchmarkEncodeTrace is the encoder of the chunked trace responses writing the spans one at a time.
+func BenchmarkEncodeTrace(b *testing.B) {
+	trace := benchTrace(10000)
+	b.Run(name, func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			enc := NewEncoder(ioutil.Discard)
+			for j := range trace.Spans {
+				if err := enc.Encode(&trace.Spans[j]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func TestMarshalMatchesStd(t *testing.T) {
+	trace := benchTrace(10)
+	got, err := Marshal(trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := json.Marshal(trace)
+	if string(got) != string(want) {
+		t.Fatalf("%s output differs from encoding/json:\n%s\n%s", name, got, want)
+	}
+}
@@ -0,0 +1,23 @@
+//go:build sonic
+// +build sonic
+
+package codec
+
+import (
+	"github.com/bytedance/sonic"
+	"io"
+)
+
+const name = "sonic"
+
+// ConfigStd keeps the encoding/json output, e.g. the escaped HTML and the sorted map keys
+var api = sonic.ConfigStd
+
+var (
+	marshal   = api.Marshal
+	unmarshal = api.Unmarshal
+)
+
+func newEncoder(w io.Writer) Encoder {
+	return api.NewEncoder(w)
+}
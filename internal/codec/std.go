@@ -0,0 +1,20 @@
+//go:build !sonic
+// +build !sonic
+
+package codec
+
+import (
+	"encoding/json"
+	"io"
+)
+
+const name = "encoding/json"
+
+var (
+	marshal   = json.Marshal
+	unmarshal = json.Unmarshal
+)
+
+func newEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
@@ -1,18 +1,409 @@
 package config
 
+import "sync/atomic"
+
+const (
+	LogLevelInfo  = "info"
+	LogLevelDebug = "debug"
+
+	// ResponseCompatLegacy omits the empty errors and never sends a null data, for the older jaeger-ui
+	ResponseCompatLegacy = "legacy"
+	// ResponseCompatModern never sends null errors and omits the unset limit and offset, for the newer jaeger-ui
+	ResponseCompatModern = "modern"
+
+	AccessLogText = "text"
+	AccessLogJSON = "json"
+	AccessLogOff  = "off"
+
+	StreamDurationNanoseconds  = "ns"
+	StreamDurationMicroseconds = "us"
+	StreamDurationMilliseconds = "ms"
+	// StreamDurationAuto derives the span durations from the start_time and end_time nanoseconds
+	StreamDurationAuto = "auto"
+)
+
 type Config struct {
-	OpenObserve OpenObserveConfig `yaml:"openobserve"`
+	ReloadInterval    int  `yaml:"reload_interval"`     // unit: second, 0 means reload on SIGHUP only
+	ShutdownTimeout   int  `yaml:"shutdown_timeout"`    // unit: second, in-flight requests are drained for at most this long, 0 means no limit
+	StrictQueryParams bool `yaml:"strict_query_params"` // reject unknown /api/traces query parameters with 400
+	RootCauseHints    bool `yaml:"root_cause_hints"`    // annotate returned traces with triage hints in span warnings
+	RequestTimeout    int  `yaml:"request_timeout"`     // unit: second, deadline of the api requests without X-Timeout, 0 means none
+	MaxRequestTimeout int  `yaml:"max_request_timeout"` // unit: second, cap of the X-Timeout header, 0 means no cap
+
+	LogLevel       string `yaml:"log_level"`       // info or debug, debug also logs the sql and the results of the queries, default: info
+	DurationUnit   string `yaml:"duration_unit"`   // unit of the bare integer minDuration and maxDuration of /api/traces: ns, us, ms, s, m or h, default: us
+	ResponseCompat string `yaml:"response_compat"` // legacy or modern jaeger-ui response shape, empty keeps errors and data as set
+	SearchStrategy string `yaml:"search_strategy"` // strategy of the trace searches without ?strategy=, default: default
+
+	TLS            TLSConfig            `yaml:"tls"`
+	OpenObserve    OpenObserveConfig    `yaml:"openobserve"`
+	LoadShedding   LoadSheddingConfig   `yaml:"load_shedding"`
+	Auth           AuthConfig           `yaml:"auth"`
+	Chunked        ChunkedConfig        `yaml:"chunked"`
+	Compression    CompressionConfig    `yaml:"compression"`
+	Cache          CacheConfig          `yaml:"cache"`
+	RequestBody    RequestBodyConfig    `yaml:"request_body"`
+	CORS           CORSConfig           `yaml:"cors"`
+	Tracing        TracingConfig        `yaml:"tracing"`
+	RateLimit      RateLimitConfig      `yaml:"rate_limit"`
+	Tail           TailConfig           `yaml:"tail"`
+	SpanMetrics    SpanMetricsConfig    `yaml:"span_metrics"`
+	CrossOrg       CrossOrgConfig       `yaml:"cross_org"`
+	Warmup         WarmupConfig         `yaml:"warmup"`
+	Admin          AdminConfig          `yaml:"admin"`
+	CacheControl   CacheControlConfig   `yaml:"cache_control"`
+	UI             UIConfig             `yaml:"ui"`
+	Debug          DebugConfig          `yaml:"debug"`
+	Ingest         IngestConfig         `yaml:"ingest"`
+	Audit          AuditConfig          `yaml:"audit"`
+	Batch          BatchConfig          `yaml:"batch"`
+	RawSearch      RawSearchConfig      `yaml:"raw_search"`
+	QualityMetrics QualityMetricsConfig `yaml:"quality_metrics"`
+	SearchJobs     SearchJobsConfig     `yaml:"search_jobs"`
+	TimeRange      TimeRangeConfig      `yaml:"time_range"`
+	Concurrency    ConcurrencyConfig    `yaml:"concurrency"`
+	Server         ServerConfig         `yaml:"server"`
+	// SearchStrategies are the named ways of running the trace searches, they add to and override BuiltinSearchStrategies
+	SearchStrategies map[string]SearchStrategyConfig `yaml:"search_strategies"`
+	// ServiceOverrides are the search defaults of the services searched alone, service name -> overrides
+	ServiceOverrides map[string]ServiceOverrideConfig `yaml:"service_overrides"`
 }
 
 // OpenObserveConfig holds the configuration for OpenObserve
 type OpenObserveConfig struct {
-	Addr                          string `yaml:"addr"`
-	Auth                          string `yaml:"auth"`
-	DefaultTraceDetailSearchRange int    `yaml:"default_trace_detail_search_range_time"`
-	DefaultQueryUIMaxSearchRange  int    `yaml:"default_queryui_max_search_range_time"`
-	DefaultServiceNameSize        int64  `yaml:"default_servicename_size"`
-	DefaultOperationNameSize      int64  `yaml:"default_operationname_size"`
-	DefaultSpanSize               int    `yaml:"default_span_size"`
+	Addr                          string  `yaml:"addr"`
+	Auth                          string  `yaml:"auth"`
+	AuthFile                      string  `yaml:"auth_file"` // file holding auth, e.g. a mounted secret, re-read when it changes; overrides auth
+	Org                           string  `yaml:"org"`       // organization segment of the api path, default: default
+	DefaultTraceDetailSearchRange int     `yaml:"default_trace_detail_search_range_time"`
+	TraceLookupRange              int     `yaml:"trace_lookup_range_time"`               // unit: hour, trace_list_index window searched for the trace time range, 0 means disabled
+	DefaultQueryUIMaxSearchRange  int     `yaml:"default_queryui_max_search_range_time"` // unit: hour, longest time range of a trace search, default: 1
+	DefaultServiceNameSize        int64   `yaml:"default_servicename_size"`
+	DefaultOperationNameSize      int64   `yaml:"default_operationname_size"`
+	DefaultSpanSize               int     `yaml:"default_span_size"`
+	DefaultSearchLimit            int     `yaml:"default_search_limit"`     // traces of a trace search without limit, default: 20
+	MaxSearchLimit                int     `yaml:"max_search_limit"`         // larger search limits are reduced to it with a warning, default: 1500
+	MaxTraceSpans                 int     `yaml:"max_trace_spans"`          // spans of a trace detail, the first by start time, more are truncated; 0 means no limit
+	TraceFetchPadding             int     `yaml:"trace_fetch_padding"`      // unit: second, spans of the found traces fetched before their first span in the window, default: 60, < 0 means disabled
+	ConfirmTruncatedSearch        bool    `yaml:"confirm_truncated_search"` // re-check searches returning exactly limit traces
+	SearchParallelism             int     `yaml:"search_parallelism"`       // split trace id searches into n concurrent sub-ranges, <= 1 means disabled
+	ProcessTagsPattern            string  `yaml:"process_tags_pattern"`     // regexp of the resource attribute columns emitted as process tags
+	ArrayTags                     string  `yaml:"array_tags"`               // array attributes as one json string tag or indexed tags key.0, key.1, default: json
+	SearchSampleRatio             float64 `yaml:"search_sample_ratio"`      // default ?sample= of /api/traces, 0 means no sampling
+	PostFilterMaxTraces           int     `yaml:"post_filter_max_traces"`   // candidate traces filtered in the proxy for the tags which are no columns, 0 means disabled
+	MaxSQLLength                  int     `yaml:"max_sql_length"`           // unit: byte, longer trace id IN() lists are split into several queries, 0 means no limit
+	SkipWal                       bool    `yaml:"skip_wal"`                 // trace searches skip the not yet compacted spans of the WAL, faster but misses the newest spans
+	BackgroundSearch              bool    `yaml:"background_search"`        // trace searches use the reports search type instead of ui
+	ErrorTagFallback              bool    `yaml:"error_tag_fallback"`       // error=true searches also match the spans with an error attribute 'true', not only span_status ERROR
+
+	ErrorTagColumns []string `yaml:"error_tag_columns"` // the attribute columns of error_tag_fallback, default: [error]
+
+	FieldMapping map[string]string `yaml:"field_mapping"` // span field -> column of the default stream, for the streams not using the OpenObserve names
+
+	MaxSearchRangeUsers map[string]int `yaml:"max_search_range_users"` // auth user -> unit: hour, overrides default_queryui_max_search_range_time, e.g. for the on-call users
+
+	Streams map[string]StreamSearchConfig `yaml:"streams"` // stream name -> search defaults
+
+	UserAgent string            `yaml:"user_agent"`
+	Headers   map[string]string `yaml:"headers"` // sent with every request, e.g. routing hints of a gateway
+
+	Queriers       []string `yaml:"queriers"`        // base urls the queries of one trace are pinned to by trace id hash, empty means addr
+	AffinityHeader string   `yaml:"affinity_header"` // header carrying the trace id of the trace queries for hashing load balancers
+
+	Clusters []ClusterConfig `yaml:"clusters"` // searches go to the clusters owning their time range and are merged, empty means addr only
+
+	TLS ClientTLSConfig `yaml:"tls"`
+}
+
+// ClusterConfig holds an OpenObserve cluster owning the spans of a time range, e.g. the hot or the cold one.
+// The ownership is relative to now, overlapping ranges are searched in both clusters and deduplicated.
+type ClusterConfig struct {
+	Name   string `yaml:"name"`
+	Addr   string `yaml:"addr"`
+	Auth   string `yaml:"auth"`    // default: openobserve.auth
+	MinAge int    `yaml:"min_age"` // unit: hour, the cluster owns the spans older than this, 0 means up to now
+	MaxAge int    `yaml:"max_age"` // unit: hour, the cluster owns the spans newer than this, 0 means unbounded
+}
+
+// ClientTLSConfig holds the configuration of the https connections to OpenObserve, read at startup
+type ClientTLSConfig struct {
+	CAFile             string `yaml:"ca_file"`              // PEM bundle verifying the server, empty means the system roots
+	CertFile           string `yaml:"cert_file"`            // PEM client certificate for mutual tls
+	KeyFile            string `yaml:"key_file"`             // PEM key of cert_file
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"` // the server certificate is not verified, for testing only
+}
+
+// StreamSearchConfig holds the search defaults of one OpenObserve stream,
+// they apply when the query does not set the field itself
+type StreamSearchConfig struct {
+	Size            int64  `yaml:"size"`             // 0 means no size
+	SqlMode         string `yaml:"sql_mode"`         // default: full
+	SearchType      string `yaml:"search_type"`      // ui or reports, default: ui
+	BackgroundRange int    `yaml:"background_range"` // unit: hour, wider searches use reports, 0 means disabled
+	DurationUnit    string `yaml:"duration_unit"`    // ns, us, ms or auto, unit of the duration column of the spans, default: us
+}
+
+// TLSConfig holds the configuration for serving https, read at startup
+type TLSConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	CertFile     string `yaml:"cert_file"`      // PEM certificate chain
+	KeyFile      string `yaml:"key_file"`       // PEM key of cert_file
+	ClientCAFile string `yaml:"client_ca_file"` // PEM bundle the required client certificates are verified with, empty means no client auth
 }
 
-var Cfg Config
+// LoadSheddingConfig holds the configuration for heap based load shedding
+type LoadSheddingConfig struct {
+	HeapWatermarkMB int64 `yaml:"heap_watermark_mb"` // 0 means disabled
+	CheckInterval   int   `yaml:"check_interval"`    // unit: second
+}
+
+// AuthConfig holds the configuration for the query endpoints authentication
+type AuthConfig struct {
+	Enabled      bool              `yaml:"enabled"`
+	BasicUsers   map[string]string `yaml:"basic_users"`   // username -> password
+	BearerTokens []string          `yaml:"bearer_tokens"` // static tokens
+	AllowPaths   []string          `yaml:"allow_paths"`   // paths served without authentication
+}
+
+// ChunkedConfig holds the configuration for encoding large trace responses span by span.
+// The trace is still built whole in memory, only the encode buffer of the response is bounded.
+type ChunkedConfig struct {
+	Enabled    bool `yaml:"enabled"`
+	MinSpans   int  `yaml:"min_spans"`   // encode traces with at least this many spans span by span
+	FlushSpans int  `yaml:"flush_spans"` // flush the response every n spans
+	Gzip       bool `yaml:"gzip"`        // gzip the response when the client accepts it
+}
+
+// CompressionConfig holds the configuration for gzip/deflate response compression
+type CompressionConfig struct {
+	Enabled      bool     `yaml:"enabled"`
+	MinSize      int      `yaml:"min_size"`      // unit: byte, smaller responses are not compressed
+	Level        int      `yaml:"level"`         // 1 (best speed) - 9 (best compression), 0 means default
+	ContentTypes []string `yaml:"content_types"` // compressed content type prefixes
+}
+
+// CacheConfig holds the configuration for the FindTraces response cache
+type CacheConfig struct {
+	Enabled    bool `yaml:"enabled"`
+	TTL        int  `yaml:"ttl"` // unit: second, also the time bucket of the cache key
+	MaxEntries int  `yaml:"max_entries"`
+}
+
+// CacheControlConfig holds the Cache-Control and Surrogate-Control headers of the api routes,
+// for a CDN or nginx cache in front of the proxy
+type CacheControlConfig struct {
+	Enabled bool                         `yaml:"enabled"`
+	Routes  map[string]RouteCacheControl `yaml:"routes"` // route path, e.g. /api/traces/:id -> headers
+}
+
+// RouteCacheControl holds the caching of the successful responses of a route, the failed and
+// partial ones are sent with no-store
+type RouteCacheControl struct {
+	MaxAge          int `yaml:"max_age"`           // unit: second, Cache-Control max-age
+	SurrogateMaxAge int `yaml:"surrogate_max_age"` // unit: second, Surrogate-Control max-age for the CDN, 0 means not sent
+	Settle          int `yaml:"settle"`            // unit: second, traces with a span ended more recently may still grow and are not cached
+}
+
+// RequestBodyConfig holds the configuration for the request bodies of POST endpoints
+type RequestBodyConfig struct {
+	MaxSize int64 `yaml:"max_size"` // unit: byte, limit of the (decompressed) body
+	Gzip    bool  `yaml:"gzip"`     // accept Content-Encoding: gzip bodies
+}
+
+// CORSConfig holds the configuration for cross-origin requests from browser based clients
+type CORSConfig struct {
+	Enabled          bool     `yaml:"enabled"`
+	AllowedOrigins   []string `yaml:"allowed_origins"` // "*" allows any origin
+	AllowedMethods   []string `yaml:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	MaxAge           int      `yaml:"max_age"` // unit: second, preflight cache duration
+}
+
+// TracingConfig holds the configuration for tracing the proxy itself, read at startup
+type TracingConfig struct {
+	Enabled       bool              `yaml:"enabled"`
+	Endpoint      string            `yaml:"endpoint"`       // OTLP/HTTP base url, /v1/traces is appended
+	Headers       map[string]string `yaml:"headers"`        // e.g. Authorization of the collector
+	ServiceName   string            `yaml:"service_name"`   // default: openobserve-jaeger
+	SampleRatio   float64           `yaml:"sample_ratio"`   // ratio of the new traces sampled, 0 means 1
+	BatchSize     int               `yaml:"batch_size"`     // spans per export request
+	FlushInterval int               `yaml:"flush_interval"` // unit: second
+}
+
+// RateLimitConfig holds the configuration for the per client token bucket rate limiting, read at startup
+type RateLimitConfig struct {
+	Enabled  bool      `yaml:"enabled"`
+	Key      string    `yaml:"key"`      // ip or api_key (the Authorization header, ip if none), default: ip
+	Traces   RateLimit `yaml:"traces"`   // trace searches and details, dependencies, the aggregations and diagnostics
+	Metadata RateLimit `yaml:"metadata"` // services, operations and the other routes
+}
+
+// RateLimit is a token bucket
+type RateLimit struct {
+	Rate  float64 `yaml:"rate"`  // unit: request per second, 0 means unlimited
+	Burst int     `yaml:"burst"` // 0 means rate rounded up
+}
+
+// TailConfig holds the configuration for the /api/traces/:id/spans live tail
+type TailConfig struct {
+	PollInterval int `yaml:"poll_interval"` // unit: second, default: 2
+	Lookback     int `yaml:"lookback"`      // unit: second, window re-queried behind the newest span for late spans, default: 60
+	MaxDuration  int `yaml:"max_duration"`  // unit: second, the stream ends after it, default: 600
+}
+
+// SpanMetricsConfig holds the configuration for the /metrics/spanmetrics endpoint, read at startup
+type SpanMetricsConfig struct {
+	Enabled bool      `yaml:"enabled"`
+	Window  int       `yaml:"window"`  // unit: second, the first scrape and the longest window aggregated per scrape, default: 60
+	Delay   int       `yaml:"delay"`   // unit: second, the newest spans are left for the next scrape as they may be incomplete, default: 30
+	Buckets []float64 `yaml:"buckets"` // unit: millisecond, latency histogram upper bounds
+}
+
+// CrossOrgConfig holds the configuration for the /admin/traces/:id trace lookup in all the orgs
+type CrossOrgConfig struct {
+	Enabled bool     `yaml:"enabled"` // read at startup
+	Orgs    []string `yaml:"orgs"`    // searched orgs
+	Users   []string `yaml:"users"`   // auth users allowed to search, empty means any user passing auth
+}
+
+// WarmupConfig holds the configuration for the background refresh of the services and operations, read at startup
+type WarmupConfig struct {
+	Enabled  bool `yaml:"enabled"`
+	Interval int  `yaml:"interval"` // unit: second, default: 300
+}
+
+// AdminConfig holds the configuration of the /admin api changing the live settings, read at startup
+type AdminConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Users   []string `yaml:"users"` // auth users allowed to use it, empty means any user passing auth
+}
+
+// UIConfig holds the configuration of the embedded jaeger-ui, read at startup
+type UIConfig struct {
+	Enabled  bool   `yaml:"enabled"`   // serve the jaeger-ui at /, the paths of no api route get it
+	BasePath string `yaml:"base_path"` // prefix the UI and the api are served under as well, e.g. /jaeger, default: /
+}
+
+// DebugConfig holds the configuration of the pprof and runtime stats listener, read at startup
+type DebugConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Addr    string `yaml:"addr"` // host:port of its own listener, default: localhost:6060
+}
+
+// IngestConfig holds the configuration of the OTLP/HTTP ingest on /v1/traces forwarded to OpenObserve, read at startup
+type IngestConfig struct {
+	Enabled       bool              `yaml:"enabled"`
+	Stream        string            `yaml:"stream"`         // openobserve traces stream, default: default
+	Attributes    map[string]string `yaml:"attributes"`     // resource attributes added to the spans missing them, e.g. deployment.environment: prod
+	UserAttribute string            `yaml:"user_attribute"` // resource attribute set to the auth user of the export, e.g. tenant.id, empty means none
+	BatchSize     int               `yaml:"batch_size"`     // spans per openobserve request, default: 1000
+	FlushInterval int               `yaml:"flush_interval"` // unit: second, default: 1
+	QueueSize     int               `yaml:"queue_size"`     // exports waiting for a batch, more are answered with 503, default: 1000
+	Retries       int               `yaml:"retries"`        // of a batch failing with a connection error, 429 or 5xx, default: 3
+}
+
+// AuditConfig holds the configuration of the search audit log, read at startup except slow_query
+type AuditConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	Sink       string `yaml:"sink"`        // file or openobserve, default: file
+	File       string `yaml:"file"`        // path of the file sink, default: audit.log
+	MaxSize    int    `yaml:"max_size"`    // unit: MB, the file is rotated at it, default: 100
+	MaxBackups int    `yaml:"max_backups"` // rotated files kept as file.1 ... file.N, default: 5
+	Stream     string `yaml:"stream"`      // openobserve log stream of the openobserve sink, default: jaeger_audit
+	SlowQuery  int    `yaml:"slow_query"`  // unit: millisecond, slower searches are logged and flagged, audit or not, default: 4000
+}
+
+// BatchConfig holds the limits of the batch trace fetch on POST /api/traces:batch
+type BatchConfig struct {
+	MaxTraces   int `yaml:"max_traces"`  // trace ids per request, default: 100
+	Concurrency int `yaml:"concurrency"` // traces fetched at a time, default: 8
+}
+
+// RawSearchConfig holds the configuration of the SQL passthrough on POST /api/raw-search, read at startup
+// except the limits
+type RawSearchConfig struct {
+	Enabled  bool              `yaml:"enabled"`
+	Users    []string          `yaml:"users"`     // auth users allowed to use it, empty means any user passing auth
+	Streams  map[string]string `yaml:"streams"`   // stream -> type (traces, logs or metadata) the statements may read, default: default: traces
+	MaxRange int               `yaml:"max_range"` // unit: hour, longest time range of a statement, default: 1
+	MaxLimit int               `yaml:"max_limit"` // rows of a statement, a missing or larger LIMIT becomes it, default: 1000
+}
+
+// QualityMetricsConfig holds the configuration of /api/quality-metrics, the scan is started at startup
+type QualityMetricsConfig struct {
+	Window       int `yaml:"window"`        // unit: second, the metrics cover the traces converted in the last one to two windows, default: 3600
+	ScanInterval int `yaml:"scan_interval"` // unit: second, a sample of the traces of the last interval is fetched and counted every interval, 0 means disabled
+	ScanTraces   int `yaml:"scan_traces"`   // traces of a scan sample, default: 20
+}
+
+// SearchJobsConfig holds the configuration of the background trace searches of /api/search-jobs
+type SearchJobsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	TTL     int  `yaml:"ttl"`      // unit: second, a finished job and its result are kept this long, default: 600
+	Timeout int  `yaml:"timeout"`  // unit: second, a running search is canceled after it, default: 600
+	MaxJobs int  `yaml:"max_jobs"` // jobs kept at a time, running or finished, default: 100
+}
+
+// TimeRangeConfig is the policy for the search time ranges of the clients with skewed clocks, the corrected
+// ranges are searched with a warning in the response instead of failing or matching nothing
+type TimeRangeConfig struct {
+	Normalize     bool `yaml:"normalize"`
+	FutureSkew    int  `yaml:"future_skew"`    // unit: second, an end later than now plus it is clamped to it, default: 60
+	SwapTolerance int  `yaml:"swap_tolerance"` // unit: second, a start after the end by at most it is swapped with it, default: 300
+}
+
+// ServiceOverrideConfig holds the search defaults of one service, e.g. tighter limits for a high-volume gateway
+type ServiceOverrideConfig struct {
+	Limit       int               `yaml:"limit"`        // traces of the searches setting no limit, and the largest limit, 0 means no override
+	MaxLookback int               `yaml:"max_lookback"` // unit: hour, a longer search range is cut to its last max_lookback hours, 0 means no cut
+	Tags        map[string]string `yaml:"tags"`         // tag filters added to the searches not filtering on the key, e.g. http.route: /api/%
+	Stream      string            `yaml:"stream"`       // openobserve traces stream holding the spans of the service, default: default
+}
+
+// ConcurrencyConfig holds the configuration for the in-flight limits of the expensive routes, read at startup
+type ConcurrencyConfig struct {
+	Enabled bool             `yaml:"enabled"`
+	Search  ConcurrencyLimit `yaml:"search"` // trace searches: /api/traces, compare, batch, raw-search and the zipkin search
+	Trace   ConcurrencyLimit `yaml:"trace"`  // trace details, stats and exports
+}
+
+// ConcurrencyLimit is a semaphore, the requests over max_in_flight wait for a slot
+type ConcurrencyLimit struct {
+	MaxInFlight int `yaml:"max_in_flight"` // requests served at once, 0 means unlimited
+	MaxQueue    int `yaml:"max_queue"`     // requests waiting for a slot, more are rejected at once, 0 means unbounded
+	MaxWait     int `yaml:"max_wait"`      // unit: second, longest wait for a slot, default: 10
+}
+
+// ServerConfig holds the configuration for the gin engine of the api, read at startup
+type ServerConfig struct {
+	Mode            string   `yaml:"mode"`              // gin mode: release, debug or test, default: release
+	TrustedProxies  []string `yaml:"trusted_proxies"`   // IPs or CIDRs of the ingress, their remote_ip_headers give the client ip; empty trusts none
+	RemoteIPHeaders []string `yaml:"remote_ip_headers"` // headers with the client ip set by the trusted proxies, default: X-Forwarded-For, X-Real-IP
+	AccessLog       string   `yaml:"access_log"`        // text, json or off, default: text
+}
+
+// SearchStrategyConfig is a named way of running the trace searches on OpenObserve, selected with ?strategy=
+type SearchStrategyConfig struct {
+	SkipWal     bool   `yaml:"skip_wal"`    // skip the spans still in the openobserve WAL, faster but misses the newest seconds
+	SearchType  string `yaml:"search_type"` // ui or reports, the openobserve search type, default: ui
+	Stream      string `yaml:"stream"`      // where the trace ids are searched: index (trace_list_index when the filters allow) or spans, default: index
+	Parallelism int    `yaml:"parallelism"` // trace id search sub-ranges queried concurrently, 0 means openobserve.search_parallelism
+}
+
+var current atomic.Value // *Config
+
+func init() {
+	current.Store(&Config{})
+}
+
+// Get returns the current config snapshot. A reload stores a new snapshot instead of changing
+// this one, so it must be treated as read-only; read it once per request for consistent values.
+func Get() *Config {
+	return current.Load().(*Config)
+}
+
+// Set makes cfg the current config.
+func Set(cfg Config) {
+	current.Store(&cfg)
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Load reads and parses the yaml config file, then applies the OO_JAEGER_* environment overrides
+// and reads openobserve.auth_file.
+func Load(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	err = yaml.Unmarshal(data, &cfg)
+	if err != nil {
+		return cfg, err
+	}
+
+	if err = applyEnv(&cfg); err != nil {
+		return cfg, err
+	}
+
+	if file := cfg.OpenObserve.AuthFile; file != "" {
+		auth, err := os.ReadFile(file)
+		if err != nil {
+			return cfg, fmt.Errorf("openobserve.auth_file: %w", err)
+		}
+		cfg.OpenObserve.Auth = strings.TrimSpace(string(auth))
+	}
+	return cfg, nil
+}
+
+// Watch reloads the config from path on SIGHUP, and also when the modification time of the file or of
+// openobserve.auth_file changes if interval > 0, so a rotated secret is picked up. A config that fails
+// to load or validate is logged and the old one is kept.
+func Watch(path string, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	modTime, authModTime := fileModTime(path), fileModTime(Get().OpenObserve.AuthFile)
+	for {
+		select {
+		case <-hup:
+			log.Printf("SIGHUP received, reloading config %s", path)
+		case <-tick:
+			mt, authMt := fileModTime(path), fileModTime(Get().OpenObserve.AuthFile)
+			if mt.Equal(modTime) && authMt.Equal(authModTime) {
+				continue
+			}
+			if mt.Equal(modTime) {
+				log.Printf("openobserve.auth_file changed, reloading config %s", path)
+			} else {
+				log.Printf("config %s changed, reloading", path)
+			}
+			modTime, authModTime = mt, authMt
+		}
+
+		cfg, err := Load(path)
+		if err != nil {
+			log.Printf("reload config %s err: %v", path, err)
+			continue
+		}
+		if err := Validate(cfg); err != nil {
+			log.Printf("reload config %s rejected: %v", path, err)
+			continue
+		}
+		Set(cfg)
+	}
+}
+
+func fileModTime(path string) time.Time {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
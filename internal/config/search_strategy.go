@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	DefaultSearchStrategy = "default"
+
+	SearchStreamIndex = "index"
+	SearchStreamSpans = "spans"
+)
+
+// BuiltinSearchStrategies are the search strategies known without search_strategies, background_no_wal and
+// background are the former version=v3 and version=v4 of /api/traces.
+var BuiltinSearchStrategies = map[string]SearchStrategyConfig{
+	DefaultSearchStrategy: {},
+	"background":          {SearchType: "reports"},
+	"background_no_wal":   {SkipWal: true, SearchType: "reports"},
+}
+
+// LookupSearchStrategy returns the search strategy of name, search_strategies first, then the builtin ones.
+func (c *Config) LookupSearchStrategy(name string) (SearchStrategyConfig, bool) {
+	if s, ok := c.SearchStrategies[name]; ok {
+		return s, true
+	}
+	s, ok := BuiltinSearchStrategies[name]
+	return s, ok
+}
+
+// SearchStrategyNames are the sorted names of the known search strategies.
+func (c *Config) SearchStrategyNames() []string {
+	names := make([]string, 0, len(BuiltinSearchStrategies)+len(c.SearchStrategies))
+	for name := range BuiltinSearchStrategies {
+		names = append(names, name)
+	}
+	for name := range c.SearchStrategies {
+		if _, ok := BuiltinSearchStrategies[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String describes what the strategy changes, e.g. in the error of an unknown strategy.
+func (s SearchStrategyConfig) String() string {
+	parts := make([]string, 0, 4)
+	if s.SkipWal {
+		parts = append(parts, "skip_wal")
+	}
+	if s.SearchType != "" {
+		parts = append(parts, "search_type "+s.SearchType)
+	}
+	if s.Stream != "" {
+		parts = append(parts, "stream "+s.Stream)
+	}
+	if s.Parallelism > 0 {
+		parts = append(parts, fmt.Sprintf("parallelism %d", s.Parallelism))
+	}
+	if len(parts) == 0 {
+		return "the openobserve defaults"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,354 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ValidationError lists all the problems found in a config
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks the required fields and the value ranges of cfg.
+func Validate(cfg Config) error {
+	problems := make([]string, 0)
+	add := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	oo := cfg.OpenObserve
+	if oo.Addr == "" {
+		add("openobserve.addr is required, e.g. http://openobserve:5080")
+	} else if u, err := url.Parse(oo.Addr); err != nil || u.Scheme == "" || u.Host == "" {
+		add("openobserve.addr %q must be an absolute url with scheme and host, e.g. http://openobserve:5080", oo.Addr)
+	}
+	for _, querier := range oo.Queriers {
+		if u, err := url.Parse(querier); err != nil || u.Scheme == "" || u.Host == "" {
+			add("openobserve.queriers %q must be an absolute url with scheme and host", querier)
+		}
+	}
+	for i, c := range oo.Clusters {
+		if u, err := url.Parse(c.Addr); err != nil || u.Scheme == "" || u.Host == "" {
+			add("openobserve.clusters[%d].addr %q must be an absolute url with scheme and host", i, c.Addr)
+		}
+		if c.MinAge < 0 || c.MaxAge < 0 {
+			add("openobserve.clusters[%d] min_age and max_age must be >= 0 (hours)", i)
+		} else if c.MaxAge > 0 && c.MaxAge <= c.MinAge {
+			add("openobserve.clusters[%d].max_age must be > min_age, it owns no time range", i)
+		}
+	}
+	if strings.EqualFold(oo.AffinityHeader, "Authorization") || strings.EqualFold(oo.AffinityHeader, "Content-Type") {
+		add("openobserve.affinity_header must not be %s", oo.AffinityHeader)
+	}
+	if oo.Auth == "" {
+		add("openobserve.auth or openobserve.auth_file is required: base64 of \"user:password\" for Basic auth")
+	}
+	for k := range oo.Headers {
+		if strings.EqualFold(k, "Authorization") || strings.EqualFold(k, "Content-Type") {
+			add("openobserve.headers must not set %s, it is managed by the proxy (use openobserve.auth)", k)
+		}
+	}
+	if oo.DefaultTraceDetailSearchRange <= 0 {
+		add("openobserve.default_trace_detail_search_range_time must be > 0 (hours)")
+	}
+	if oo.TraceLookupRange < 0 {
+		add("openobserve.trace_lookup_range_time must be >= 0 (hours)")
+	}
+	if oo.DefaultServiceNameSize <= 0 {
+		add("openobserve.default_servicename_size must be > 0")
+	}
+	if oo.DefaultOperationNameSize <= 0 {
+		add("openobserve.default_operationname_size must be > 0")
+	}
+	if oo.DefaultSpanSize <= 0 {
+		add("openobserve.default_span_size must be > 0")
+	}
+	if oo.MaxTraceSpans < 0 {
+		add("openobserve.max_trace_spans must be >= 0, 0 means no limit")
+	}
+
+	if _, err := regexp.Compile(oo.ProcessTagsPattern); err != nil {
+		add("openobserve.process_tags_pattern is not a valid regexp: %v", err)
+	}
+	if oo.ArrayTags != "" && oo.ArrayTags != "json" && oo.ArrayTags != "indexed" {
+		add("openobserve.array_tags must be json or indexed")
+	}
+	if oo.SearchSampleRatio < 0 || oo.SearchSampleRatio > 1 {
+		add("openobserve.search_sample_ratio must be between 0 and 1")
+	}
+	if oo.PostFilterMaxTraces < 0 {
+		add("openobserve.post_filter_max_traces must be >= 0")
+	}
+	for _, column := range oo.ErrorTagColumns {
+		if column == "" {
+			add("openobserve.error_tag_columns must not contain empty column names")
+		}
+	}
+	if oo.MaxSQLLength != 0 && oo.MaxSQLLength < 1024 {
+		add("openobserve.max_sql_length must be 0 or >= 1024 (bytes)")
+	}
+	for field, column := range oo.FieldMapping {
+		if !containsField(MappableFields, field) {
+			add("openobserve.field_mapping.%s is no span field, one of %s", field, strings.Join(MappableFields, ", "))
+		} else if !columnPattern.MatchString(column) {
+			add("openobserve.field_mapping.%s column %q must be a plain column name", field, column)
+		}
+	}
+	if oo.SearchParallelism < 0 {
+		add("openobserve.search_parallelism must be >= 0")
+	}
+	for stream, d := range oo.Streams {
+		if d.SqlMode != "" && d.SqlMode != "full" && d.SqlMode != "context" {
+			add("openobserve.streams.%s.sql_mode must be full or context", stream)
+		}
+		if d.SearchType != "" && d.SearchType != "ui" && d.SearchType != "reports" {
+			add("openobserve.streams.%s.search_type must be ui or reports", stream)
+		}
+		if d.BackgroundRange < 0 {
+			add("openobserve.streams.%s.background_range must be >= 0 (hours)", stream)
+		}
+		switch d.DurationUnit {
+		case "", StreamDurationNanoseconds, StreamDurationMicroseconds, StreamDurationMilliseconds, StreamDurationAuto:
+		default:
+			add("openobserve.streams.%s.duration_unit must be ns, us, ms or auto", stream)
+		}
+	}
+
+	if cfg.TLS.Enabled && (cfg.TLS.CertFile == "" || cfg.TLS.KeyFile == "") {
+		add("tls.cert_file and tls.key_file are required when tls is enabled")
+	} else if _, err := cfg.TLS.ServerTLS(); err != nil {
+		add("%v", err)
+	}
+	if _, err := oo.TLS.ClientTLS(); err != nil {
+		add("%v", err)
+	}
+
+	if cfg.ReloadInterval < 0 {
+		add("reload_interval must be >= 0")
+	}
+	if cfg.RequestTimeout < 0 || cfg.MaxRequestTimeout < 0 {
+		add("request_timeout and max_request_timeout must be >= 0")
+	}
+	if cfg.ShutdownTimeout < 0 {
+		add("shutdown_timeout must be >= 0")
+	}
+	if cfg.Auth.Enabled && len(cfg.Auth.BasicUsers) == 0 && len(cfg.Auth.BearerTokens) == 0 {
+		add("auth.enabled requires at least one of auth.basic_users or auth.bearer_tokens")
+	}
+	for i, token := range cfg.Auth.BearerTokens {
+		if token == "" {
+			add("auth.bearer_tokens[%d] must not be empty", i)
+		}
+	}
+	if cfg.Warmup.Interval < 0 {
+		add("warmup.interval must be >= 0")
+	}
+	if cfg.CrossOrg.Enabled && len(cfg.CrossOrg.Orgs) == 0 {
+		add("cross_org.enabled requires cross_org.orgs")
+	}
+	if cfg.Admin.Enabled && !cfg.Auth.Enabled {
+		add("admin.enabled requires auth.enabled, the admin api changes the live settings")
+	}
+	if rs := cfg.RawSearch; rs.Enabled {
+		if !cfg.Auth.Enabled {
+			add("raw_search.enabled requires auth.enabled, the statements bypass the jaeger api")
+		}
+		if rs.MaxRange < 0 || rs.MaxLimit < 0 {
+			add("raw_search max_range and max_limit must be >= 0")
+		}
+		for stream, streamType := range rs.Streams {
+			if streamType != "traces" && streamType != "logs" && streamType != "metadata" {
+				add("raw_search.streams[%s] %q must be traces, logs or metadata", stream, streamType)
+			}
+		}
+	}
+	if cfg.LogLevel != "" && cfg.LogLevel != LogLevelInfo && cfg.LogLevel != LogLevelDebug {
+		add("log_level must be info or debug")
+	}
+	if qm := cfg.QualityMetrics; qm.Window < 0 || qm.ScanInterval < 0 || qm.ScanTraces < 0 {
+		add("quality_metrics window, scan_interval and scan_traces must be >= 0")
+	}
+	if sj := cfg.SearchJobs; sj.TTL < 0 || sj.Timeout < 0 || sj.MaxJobs < 0 {
+		add("search_jobs ttl, timeout and max_jobs must be >= 0")
+	}
+	for service, o := range cfg.ServiceOverrides {
+		if o.Limit < 0 || o.MaxLookback < 0 {
+			add("service_overrides.%s limit and max_lookback must be >= 0", service)
+		}
+	}
+	if cfg.OpenObserve.DefaultSearchLimit < 0 || cfg.OpenObserve.MaxSearchLimit < 0 {
+		add("openobserve.default_search_limit and openobserve.max_search_limit must be >= 0")
+	}
+	if cfg.TimeRange.FutureSkew < 0 || cfg.TimeRange.SwapTolerance < 0 {
+		add("time_range.future_skew and time_range.swap_tolerance must be >= 0 (seconds)")
+	}
+	if c := cfg.Concurrency; c.Enabled {
+		if c.Search.MaxInFlight < 0 || c.Trace.MaxInFlight < 0 || c.Search.MaxQueue < 0 || c.Trace.MaxQueue < 0 {
+			add("concurrency max_in_flight and max_queue must be >= 0")
+		}
+		if c.Search.MaxWait < 0 || c.Trace.MaxWait < 0 {
+			add("concurrency max_wait must be >= 0 (seconds)")
+		}
+	}
+	switch cfg.Server.Mode {
+	case "", "release", "debug", "test":
+	default:
+		add("server.mode must be release, debug or test, got %q", cfg.Server.Mode)
+	}
+	for _, proxy := range cfg.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			add("server.trusted_proxies %q is no IP or CIDR", proxy)
+		}
+	}
+	switch cfg.Server.AccessLog {
+	case "", AccessLogText, AccessLogJSON, AccessLogOff:
+	default:
+		add("server.access_log must be %s, %s or %s, got %q", AccessLogText, AccessLogJSON, AccessLogOff, cfg.Server.AccessLog)
+	}
+	if cfg.SearchStrategy != "" {
+		if _, ok := cfg.LookupSearchStrategy(cfg.SearchStrategy); !ok {
+			add("search_strategy %q is unknown, one of %s", cfg.SearchStrategy, strings.Join(cfg.SearchStrategyNames(), ", "))
+		}
+	}
+	for name, s := range cfg.SearchStrategies {
+		if s.SearchType != "" && s.SearchType != "ui" && s.SearchType != "reports" {
+			add("search_strategies.%s.search_type must be ui or reports", name)
+		}
+		if s.Stream != "" && s.Stream != SearchStreamIndex && s.Stream != SearchStreamSpans {
+			add("search_strategies.%s.stream must be %s or %s", name, SearchStreamIndex, SearchStreamSpans)
+		}
+		if s.Parallelism < 0 {
+			add("search_strategies.%s.parallelism must be >= 0", name)
+		}
+	}
+	switch cfg.ResponseCompat {
+	case "", ResponseCompatLegacy, ResponseCompatModern:
+	default:
+		add("response_compat must be %s or %s, got %q", ResponseCompatLegacy, ResponseCompatModern, cfg.ResponseCompat)
+	}
+	switch cfg.DurationUnit {
+	case "", "ns", "us", "ms", "s", "m", "h":
+	default:
+		add("duration_unit must be ns, us, ms, s, m or h")
+	}
+	if len(cfg.CrossOrg.Users) > 0 && !cfg.Auth.Enabled {
+		add("cross_org.users requires auth.enabled, the users are not known otherwise")
+	}
+	for route, c := range cfg.CacheControl.Routes {
+		if !strings.HasPrefix(route, "/") {
+			add("cache_control.routes %q must be a route path like /api/services", route)
+		}
+		if c.MaxAge < 0 || c.SurrogateMaxAge < 0 || c.Settle < 0 {
+			add("cache_control.routes.%s max_age, surrogate_max_age and settle must be >= 0", route)
+		}
+	}
+	if p := cfg.UI.BasePath; p != "" && (!strings.HasPrefix(p, "/") || strings.ContainsAny(p, "?#") || strings.Contains(p, "/api")) {
+		add("ui.base_path %q must be a path like /jaeger, without /api, query or fragment", p)
+	}
+	if cfg.Debug.Enabled && cfg.Debug.Addr != "" {
+		if _, _, err := net.SplitHostPort(cfg.Debug.Addr); err != nil {
+			add("debug.addr %q must be host:port, e.g. localhost:6060", cfg.Debug.Addr)
+		}
+	}
+	if in := cfg.Ingest; in.Enabled {
+		if in.UserAttribute != "" && !cfg.Auth.Enabled {
+			add("ingest.user_attribute requires auth.enabled, the users are not known otherwise")
+		}
+		if in.BatchSize < 0 || in.FlushInterval < 0 || in.QueueSize < 0 || in.Retries < 0 {
+			add("ingest batch_size, flush_interval, queue_size and retries must be >= 0")
+		}
+	}
+	if a := cfg.Audit; a.Enabled {
+		if a.Sink != "" && a.Sink != "file" && a.Sink != "openobserve" {
+			add("audit.sink %q must be file or openobserve", a.Sink)
+		}
+		if a.MaxSize < 0 || a.MaxBackups < 0 {
+			add("audit max_size and max_backups must be >= 0")
+		}
+	}
+	for user, hours := range cfg.OpenObserve.MaxSearchRangeUsers {
+		if hours <= 0 {
+			add("openobserve.max_search_range_users[%s] must be > 0", user)
+		}
+	}
+	if cfg.Batch.MaxTraces < 0 || cfg.Batch.Concurrency < 0 {
+		add("batch max_traces and concurrency must be >= 0")
+	}
+	if cfg.Audit.SlowQuery < 0 {
+		add("audit.slow_query must be >= 0")
+	}
+	if cfg.Compression.Level < 0 || cfg.Compression.Level > 9 {
+		add("compression.level must be between 0 and 9")
+	}
+	if cfg.Cache.Enabled && cfg.Cache.TTL <= 0 {
+		add("cache.enabled requires cache.ttl > 0 (seconds)")
+	}
+	if cfg.CORS.Enabled && len(cfg.CORS.AllowedOrigins) == 0 {
+		add("cors.enabled requires cors.allowed_origins")
+	}
+
+	if cfg.Tracing.Enabled {
+		if u, err := url.Parse(cfg.Tracing.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			add("tracing.enabled requires tracing.endpoint, an OTLP/HTTP url like http://otel-collector:4318")
+		}
+	}
+	if cfg.Tracing.SampleRatio < 0 || cfg.Tracing.SampleRatio > 1 {
+		add("tracing.sample_ratio must be between 0 and 1")
+	}
+
+	if rl := cfg.RateLimit; rl.Enabled {
+		if rl.Key != "" && rl.Key != "ip" && rl.Key != "api_key" {
+			add("rate_limit.key must be ip or api_key")
+		}
+		if rl.Traces.Rate < 0 || rl.Metadata.Rate < 0 || rl.Traces.Burst < 0 || rl.Metadata.Burst < 0 {
+			add("rate_limit rates and bursts must be >= 0")
+		}
+	}
+
+	if cfg.Tail.PollInterval < 0 || cfg.Tail.Lookback < 0 || cfg.Tail.MaxDuration < 0 {
+		add("tail.poll_interval, tail.lookback and tail.max_duration must be >= 0 (seconds)")
+	}
+
+	if sm := cfg.SpanMetrics; sm.Enabled {
+		if sm.Window < 0 || sm.Delay < 0 {
+			add("span_metrics.window and span_metrics.delay must be >= 0 (seconds)")
+		}
+		for _, b := range sm.Buckets {
+			if b <= 0 {
+				add("span_metrics.buckets must be > 0 (milliseconds)")
+				break
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// MappableFields are the span fields openobserve.field_mapping can map to other columns
+var MappableFields = []string{
+	"service_name", "start_time", "end_time", "trace_id", "span_id", "duration", "flags", "operation_name",
+	"span_kind", "span_status", "reference_parent_span_id", "reference_parent_trace_id", "reference_ref_type",
+	"links", "events",
+}
+
+var columnPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func containsField(fields []string, field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package errors
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
@@ -23,6 +24,8 @@ type Error struct {
 	Reason   string            `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
 	Message  string            `protobuf:"bytes,3,opt,name=message,proto3" json:"msg,omitempty"`
 	Metadata map[string]string `protobuf:"bytes,4,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+
+	cause error
 }
 
 func (e Error) Error() string {
@@ -33,13 +36,61 @@ func (e Error) ErrorWithCode() string {
 	return strconv.Itoa(int(e.Code)) + ": " + e.Message
 }
 
-func (e Error) Is(err error) bool {
+// Is matches the errors of the same code and reason, e.g. errors.Is(err, errors.ErrNotFound).
+func (e *Error) Is(err error) bool {
 	if se := new(Error); errors.As(err, &se) {
-		return true
+		return se.Code == e.Code && se.Reason == e.Reason
 	}
 	return false
 }
 
+// Unwrap returns the cause of the error.
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// WithCause returns a copy of the error with the underlying cause.
+func (e *Error) WithCause(cause error) *Error {
+	err := Clone(e)
+	err.cause = cause
+	return err
+}
+
+// Clone deep clones the error, the cause included.
+func Clone(err *Error) *Error {
+	if err == nil {
+		return nil
+	}
+	metadata := make(map[string]string, len(err.Metadata))
+	for k, v := range err.Metadata {
+		metadata[k] = v
+	}
+	return &Error{
+		Code:     err.Code,
+		Reason:   err.Reason,
+		Message:  err.Message,
+		Metadata: metadata,
+		cause:    err.cause,
+	}
+}
+
+// Wrap adds message to err, keeping its code and reason, e.g. a timed out search stays a BackendTimeout.
+// The plain errors become UnknownCode. Wrap of nil is nil.
+func Wrap(err error, message string) error {
+	if err == nil {
+		return nil
+	}
+	se := Clone(FromError(err))
+	se.Message = message + ": " + se.Message
+	se.cause = err
+	return se
+}
+
+// Wrapf is Wrap with a formatted message.
+func Wrapf(err error, format string, a ...interface{}) error {
+	return Wrap(err, fmt.Sprintf(format, a...))
+}
+
 func New(code int32, message string) *Error {
 	return &Error{
 		Code:    code,
@@ -69,6 +120,12 @@ func FromError(err error) *Error {
 	if se := new(Error); errors.As(err, &se) {
 		return se
 	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return BackendTimeout(err.Error()).WithCause(err)
+	}
+	if errors.Is(err, context.Canceled) {
+		return New(ClientClosed, err.Error()).WithCause(err)
+	}
 
 	gs, ok := status.FromError(err)
 	if ok {
@@ -85,5 +142,5 @@ func FromError(err error) *Error {
 		}
 		return ret
 	}
-	return New(UnknownCode, err.Error())
+	return New(UnknownCode, err.Error()).WithCause(err)
 }
@@ -12,7 +12,6 @@ import (
 
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/proto"
 
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
@@ -38,7 +37,7 @@ func (e *Error) GRPCStatus() *status.Status {
 
 // WithMetadata with an MD formed by the mapping of key, value.
 func (e *Error) WithMetadata(md map[string]string) *Error {
-	err := proto.Clone(e).(*Error)
+	err := Clone(e)
 	err.Metadata = md
 	return err
 }
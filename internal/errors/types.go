@@ -0,0 +1,103 @@
+package errors
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// The reasons of the errors the handlers return, HTTPStatus maps them to the response status.
+const (
+	ReasonNotFound       = "NOT_FOUND"
+	ReasonBadRequest     = "BAD_REQUEST"
+	ReasonBackendTimeout = "BACKEND_TIMEOUT"
+	ReasonUnauthorized   = "UNAUTHORIZED"
+	ReasonUnprocessable  = "UNPROCESSABLE"
+)
+
+// The sentinels to match with errors.Is, only the code and reason are compared.
+var (
+	ErrNotFound       = NotFound("")
+	ErrBadRequest     = BadRequest("")
+	ErrBackendTimeout = BackendTimeout("")
+	ErrUnauthorized   = Unauthorized("")
+	ErrUnprocessable  = Unprocessable("")
+)
+
+// reasonStatus is the HTTP status of each reason, the errors of other reasons use their code.
+var reasonStatus = map[string]int{
+	ReasonNotFound:       http.StatusNotFound,
+	ReasonBadRequest:     http.StatusBadRequest,
+	ReasonBackendTimeout: http.StatusGatewayTimeout,
+	ReasonUnauthorized:   http.StatusUnauthorized,
+	ReasonUnprocessable:  http.StatusUnprocessableEntity,
+}
+
+// NotFound is the error of a missing trace, service or resource.
+func NotFound(message string) *Error {
+	return &Error{Code: http.StatusNotFound, Reason: ReasonNotFound, Message: message}
+}
+
+// BadRequest is the error of invalid request parameters.
+func BadRequest(message string) *Error {
+	return &Error{Code: http.StatusBadRequest, Reason: ReasonBadRequest, Message: message}
+}
+
+// BackendTimeout is the error of an OpenObserve query cancelled by the deadline.
+func BackendTimeout(message string) *Error {
+	return &Error{Code: http.StatusGatewayTimeout, Reason: ReasonBackendTimeout, Message: message}
+}
+
+// Unauthorized is the error of missing or wrong credentials.
+func Unauthorized(message string) *Error {
+	return &Error{Code: http.StatusUnauthorized, Reason: ReasonUnauthorized, Message: message}
+}
+
+// Unprocessable is the error of a well-formed query OpenObserve cannot run, e.g. an unknown function.
+func Unprocessable(message string) *Error {
+	return &Error{Code: http.StatusUnprocessableEntity, Reason: ReasonUnprocessable, Message: message}
+}
+
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+func IsBadRequest(err error) bool {
+	return errors.Is(err, ErrBadRequest)
+}
+
+func IsBackendTimeout(err error) bool {
+	return errors.Is(err, ErrBackendTimeout)
+}
+
+func IsUnauthorized(err error) bool {
+	return errors.Is(err, ErrUnauthorized)
+}
+
+func IsUnprocessable(err error) bool {
+	return errors.Is(err, ErrUnprocessable)
+}
+
+// HTTPStatus is the response status of err: the status of its reason, else its code when it is an HTTP
+// error status, e.g. the status OpenObserve answered, else 500. The deadline and cancellation errors of
+// the context are 504 and 499, nil is 200.
+func HTTPStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return ClientClosed
+	}
+
+	se := FromError(err)
+	if status, ok := reasonStatus[se.Reason]; ok {
+		return status
+	}
+	if se.Code >= http.StatusBadRequest && se.Code <= 599 {
+		return int(se.Code)
+	}
+	return http.StatusInternalServerError
+}
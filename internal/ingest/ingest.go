@@ -0,0 +1,248 @@
+// Package ingest forwards the OTLP/HTTP trace exports received by the proxy to the OpenObserve ingestion,
+// enriched with the configured resource attributes, in batches and with retries.
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"openobserve-jaeger/internal/config"
+	ooerrors "openobserve-jaeger/internal/errors"
+	"openobserve-jaeger/internal/metrics"
+	"openobserve-jaeger/internal/openobserve_service"
+	"sort"
+	"time"
+)
+
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+
+	defaultBatchSize     = 1000
+	defaultFlushInterval = 1 // second
+	defaultQueueSize     = 1000
+	defaultRetries       = 3
+	forwardTimeout       = 30 * time.Second
+)
+
+// ErrQueueFull is returned by Enqueue when OpenObserve can't keep up, the exporters retry on the 503 it becomes.
+var ErrQueueFull = errors.New("ingest queue is full")
+
+var ingestedSpans = metrics.NewCounter("ingest_spans_total",
+	"Spans received on /v1/traces by result: forwarded, dropped after the retries, or rejected as the queue was full.",
+	"result")
+
+// attribute is a string resource attribute added to the received spans. An override replaces the
+// attribute the exporter set, the others are only added when missing.
+type attribute struct {
+	key, value string
+	override   bool
+}
+
+// export is a received request waiting for its batch
+type export struct {
+	protobuf      bool
+	body          []byte            // protobuf: the encoded request, requests merge by concatenation
+	resourceSpans []json.RawMessage // json: the resourceSpans of the request
+	spans         int
+}
+
+// Forwarder batches the received exports per encoding and sends them to OpenObserve.
+type Forwarder struct {
+	ooservice     *openobserve_service.OpenObserveService
+	stream        string
+	attributes    []attribute
+	userAttribute string
+	batchSize     int
+	interval      time.Duration
+	retries       int
+	queue         chan export
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+// NewForwarder starts forwarding the enqueued exports.
+func NewForwarder(cfg config.IngestConfig) *Forwarder {
+	f := &Forwarder{
+		ooservice:     openobserve_service.NewOpenObserveService(),
+		stream:        cfg.Stream,
+		userAttribute: cfg.UserAttribute,
+		batchSize:     cfg.BatchSize,
+		interval:      time.Duration(cfg.FlushInterval) * time.Second,
+		retries:       cfg.Retries,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	if f.batchSize <= 0 {
+		f.batchSize = defaultBatchSize
+	}
+	if f.interval <= 0 {
+		f.interval = defaultFlushInterval * time.Second
+	}
+	if f.retries <= 0 {
+		f.retries = defaultRetries
+	}
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	f.queue = make(chan export, queueSize)
+
+	keys := make([]string, 0, len(cfg.Attributes))
+	for k := range cfg.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		f.attributes = append(f.attributes, attribute{key: k, value: cfg.Attributes[k]})
+	}
+
+	go f.run()
+	return f
+}
+
+// Enqueue enriches a received export request, user is the authenticated user of the request. A malformed
+// body returns an error of code 400, ErrQueueFull means the request was not taken.
+func (f *Forwarder) Enqueue(body []byte, protobuf bool, user string) error {
+	attrs := f.attributes
+	if f.userAttribute != "" && user != "" {
+		attrs = append(append(make([]attribute, 0, len(attrs)+1), attrs...), attribute{key: f.userAttribute, value: user, override: true})
+	}
+
+	e := export{protobuf: protobuf}
+	var err error
+	if protobuf {
+		e.body, e.spans, err = enrichProtobuf(body, attrs)
+	} else {
+		e.resourceSpans, e.spans, err = enrichJSON(body, attrs)
+	}
+	if err != nil {
+		return ooerrors.BadRequest(err.Error())
+	}
+	if e.spans == 0 {
+		return nil
+	}
+
+	select {
+	case f.queue <- e:
+		return nil
+	default:
+		ingestedSpans.Add(float64(e.spans), "rejected")
+		return ErrQueueFull
+	}
+}
+
+// Close forwards the queued exports, waiting at most until ctx is done. Enqueue must not be called after it.
+func (f *Forwarder) Close(ctx context.Context) error {
+	close(f.stop)
+	select {
+	case <-f.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (f *Forwarder) run() {
+	defer close(f.done)
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	batches := map[bool]*batch{false: {}, true: {}}
+	add := func(e export) {
+		b := batches[e.protobuf]
+		b.add(e)
+		if b.spans >= f.batchSize {
+			f.forward(b, e.protobuf)
+		}
+	}
+
+	for {
+		select {
+		case e := <-f.queue:
+			add(e)
+		case <-ticker.C:
+			for protobuf, b := range batches {
+				f.forward(b, protobuf)
+			}
+		case <-f.stop:
+			for len(f.queue) > 0 {
+				add(<-f.queue)
+			}
+			for protobuf, b := range batches {
+				f.forward(b, protobuf)
+			}
+			return
+		}
+	}
+}
+
+// forward sends the batch and resets it, a batch failing all the retries is dropped.
+func (f *Forwarder) forward(b *batch, protobuf bool) {
+	if b.spans == 0 {
+		return
+	}
+	defer b.reset()
+
+	contentType, body := ContentTypeJSON, b.body.Bytes()
+	if protobuf {
+		contentType = ContentTypeProtobuf
+	} else {
+		var err error
+		if body, err = json.Marshal(map[string][]json.RawMessage{"resourceSpans": b.resourceSpans}); err != nil {
+			log.Printf("ingest: encoding %d spans err: %v", b.spans, err)
+			ingestedSpans.Add(float64(b.spans), "dropped")
+			return
+		}
+	}
+
+	var err error
+	for attempt := 0; attempt <= f.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), forwardTimeout)
+		err = f.ooservice.IngestTraces(ctx, f.stream, contentType, body)
+		cancel()
+		if err == nil || !retryable(err) {
+			break
+		}
+	}
+	if err != nil {
+		log.Printf("ingest: forwarding %d spans err: %v", b.spans, err)
+		ingestedSpans.Add(float64(b.spans), "dropped")
+		return
+	}
+	ingestedSpans.Add(float64(b.spans), "forwarded")
+}
+
+// retryable reports whether a failed ingestion may succeed later: connection errors, 429 and 5xx.
+func retryable(err error) bool {
+	var e *ooerrors.Error
+	if !errors.As(err, &e) {
+		return true
+	}
+	code := int(e.GetCode())
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+type batch struct {
+	body          bytes.Buffer
+	resourceSpans []json.RawMessage
+	spans         int
+}
+
+func (b *batch) add(e export) {
+	b.body.Write(e.body)
+	b.resourceSpans = append(b.resourceSpans, e.resourceSpans...)
+	b.spans += e.spans
+}
+
+func (b *batch) reset() {
+	b.body = bytes.Buffer{}
+	b.resourceSpans = nil
+	b.spans = 0
+}
@@ -0,0 +1,91 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// enrichJSON adds the attributes to the resources of an OTLP JSON export request and returns its
+// resourceSpans, for merging into a batch, and the span count. Unknown fields are kept.
+func enrichJSON(body []byte, attrs []attribute) ([]json.RawMessage, int, error) {
+	var req struct {
+		ResourceSpans []map[string]interface{} `json:"resourceSpans"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	// the 64 bit integers of the attributes must not pass through float64
+	dec.UseNumber()
+	if err := dec.Decode(&req); err != nil {
+		return nil, 0, fmt.Errorf("malformed OTLP JSON: %v", err)
+	}
+
+	resourceSpans := make([]json.RawMessage, 0, len(req.ResourceSpans))
+	spans := 0
+	for _, rs := range req.ResourceSpans {
+		if rs == nil {
+			continue
+		}
+		spans += countJSONSpans(rs)
+		if len(attrs) > 0 {
+			enrichJSONResource(rs, attrs)
+		}
+		raw, err := json.Marshal(rs)
+		if err != nil {
+			return nil, 0, err
+		}
+		resourceSpans = append(resourceSpans, raw)
+	}
+	return resourceSpans, spans, nil
+}
+
+func countJSONSpans(rs map[string]interface{}) int {
+	count := 0
+	for _, key := range []string{"scopeSpans", "instrumentationLibrarySpans"} {
+		list, _ := rs[key].([]interface{})
+		for _, item := range list {
+			if ss, ok := item.(map[string]interface{}); ok {
+				spans, _ := ss["spans"].([]interface{})
+				count += len(spans)
+			}
+		}
+	}
+	return count
+}
+
+// enrichJSONResource works like enrichResource on the decoded JSON of a ResourceSpans.
+func enrichJSONResource(rs map[string]interface{}, attrs []attribute) {
+	resource, _ := rs["resource"].(map[string]interface{})
+	if resource == nil {
+		resource = make(map[string]interface{})
+		rs["resource"] = resource
+	}
+
+	overrides := make(map[string]bool, len(attrs))
+	for _, a := range attrs {
+		if a.override {
+			overrides[a.key] = true
+		}
+	}
+
+	list, _ := resource["attributes"].([]interface{})
+	kept := make([]interface{}, 0, len(list)+len(attrs))
+	existing := make(map[string]bool, len(list))
+	for _, item := range list {
+		kv, _ := item.(map[string]interface{})
+		key, _ := kv["key"].(string)
+		if overrides[key] {
+			continue
+		}
+		existing[key] = true
+		kept = append(kept, item)
+	}
+	for _, a := range attrs {
+		if a.override || !existing[a.key] {
+			kept = append(kept, map[string]interface{}{
+				"key":   a.key,
+				"value": map[string]interface{}{"stringValue": a.value},
+			})
+		}
+	}
+	resource["attributes"] = kept
+}
@@ -0,0 +1,168 @@
+package ingest
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// field numbers of the OTLP trace protos, walked on the wire as the generated types are not a dependency
+const (
+	requestResourceSpans      = 1    // ExportTraceServiceRequest.resource_spans
+	resourceSpansResource     = 1    // ResourceSpans.resource
+	resourceSpansScopeSpans   = 2    // ResourceSpans.scope_spans
+	resourceSpansLibrarySpans = 1000 // ResourceSpans.instrumentation_library_spans, before scope_spans
+	scopeSpansSpans           = 2    // ScopeSpans.spans and InstrumentationLibrarySpans.spans
+	resourceAttributes        = 1    // Resource.attributes
+	keyValueKey               = 1    // KeyValue.key
+	keyValueValue             = 2    // KeyValue.value
+	anyValueString            = 1    // AnyValue.string_value
+)
+
+// enrichProtobuf adds the attributes to the resources of an ExportTraceServiceRequest and counts its spans.
+// The other fields are copied as they are, so the request keeps what this proxy does not know.
+func enrichProtobuf(body []byte, attrs []attribute) ([]byte, int, error) {
+	out := make([]byte, 0, len(body)+64)
+	spans := 0
+	err := walkFields(body, func(num protowire.Number, typ protowire.Type, field, value []byte) error {
+		if num != requestResourceSpans || typ != protowire.BytesType {
+			out = append(out, field...)
+			return nil
+		}
+		rs, count, err := enrichResourceSpans(value, attrs)
+		if err != nil {
+			return err
+		}
+		spans += count
+		out = protowire.AppendTag(out, requestResourceSpans, protowire.BytesType)
+		out = protowire.AppendBytes(out, rs)
+		return nil
+	})
+	return out, spans, err
+}
+
+func enrichResourceSpans(body []byte, attrs []attribute) ([]byte, int, error) {
+	out := make([]byte, 0, len(body)+64)
+	spans, hasResource := 0, false
+	err := walkFields(body, func(num protowire.Number, typ protowire.Type, field, value []byte) error {
+		switch {
+		case num == resourceSpansResource && typ == protowire.BytesType:
+			resource, err := enrichResource(value, attrs)
+			if err != nil {
+				return err
+			}
+			hasResource = true
+			out = protowire.AppendTag(out, resourceSpansResource, protowire.BytesType)
+			out = protowire.AppendBytes(out, resource)
+			return nil
+		case (num == resourceSpansScopeSpans || num == resourceSpansLibrarySpans) && typ == protowire.BytesType:
+			err := walkFields(value, func(num protowire.Number, typ protowire.Type, _, _ []byte) error {
+				if num == scopeSpansSpans && typ == protowire.BytesType {
+					spans++
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		out = append(out, field...)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if !hasResource && len(attrs) > 0 {
+		resource, _ := enrichResource(nil, attrs)
+		out = protowire.AppendTag(out, resourceSpansResource, protowire.BytesType)
+		out = protowire.AppendBytes(out, resource)
+	}
+	return out, spans, nil
+}
+
+// enrichResource drops the attributes overridden by attrs and appends attrs, the ones which don't
+// override only if the resource has no such attribute.
+func enrichResource(body []byte, attrs []attribute) ([]byte, error) {
+	overrides := make(map[string]bool, len(attrs))
+	for _, a := range attrs {
+		if a.override {
+			overrides[a.key] = true
+		}
+	}
+
+	out := make([]byte, 0, len(body)+64)
+	existing := make(map[string]bool)
+	err := walkFields(body, func(num protowire.Number, typ protowire.Type, field, value []byte) error {
+		if num != resourceAttributes || typ != protowire.BytesType {
+			out = append(out, field...)
+			return nil
+		}
+		key, err := keyValueKeyOf(value)
+		if err != nil {
+			return err
+		}
+		if overrides[key] {
+			return nil
+		}
+		existing[key] = true
+		out = append(out, field...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range attrs {
+		if a.override || !existing[a.key] {
+			out = protowire.AppendTag(out, resourceAttributes, protowire.BytesType)
+			out = protowire.AppendBytes(out, stringKeyValue(a.key, a.value))
+		}
+	}
+	return out, nil
+}
+
+func keyValueKeyOf(body []byte) (string, error) {
+	key := ""
+	err := walkFields(body, func(num protowire.Number, typ protowire.Type, _, value []byte) error {
+		if num == keyValueKey && typ == protowire.BytesType {
+			key = string(value)
+		}
+		return nil
+	})
+	return key, err
+}
+
+func stringKeyValue(key, value string) []byte {
+	anyValue := protowire.AppendTag(nil, anyValueString, protowire.BytesType)
+	anyValue = protowire.AppendString(anyValue, value)
+
+	kv := protowire.AppendTag(nil, keyValueKey, protowire.BytesType)
+	kv = protowire.AppendString(kv, key)
+	kv = protowire.AppendTag(kv, keyValueValue, protowire.BytesType)
+	return protowire.AppendBytes(kv, anyValue)
+}
+
+// walkFields calls fn with every field of a message: its number, wire type, the raw bytes of the whole
+// field and, for the length delimited ones, the value.
+func walkFields(body []byte, fn func(num protowire.Number, typ protowire.Type, field, value []byte) error) error {
+	for len(body) > 0 {
+		num, typ, n := protowire.ConsumeTag(body)
+		if n < 0 {
+			return fmt.Errorf("malformed protobuf: %v", protowire.ParseError(n))
+		}
+		m := protowire.ConsumeFieldValue(num, typ, body[n:])
+		if m < 0 {
+			return fmt.Errorf("malformed protobuf field %d: %v", num, protowire.ParseError(m))
+		}
+
+		var value []byte
+		if typ == protowire.BytesType {
+			value, _ = protowire.ConsumeBytes(body[n:])
+		}
+		if err := fn(num, typ, body[:n+m], value); err != nil {
+			return err
+		}
+		body = body[n+m:]
+	}
+	return nil
+}
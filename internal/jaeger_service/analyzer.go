@@ -0,0 +1,170 @@
+package jaeger_service
+
+import (
+	"fmt"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"sort"
+	"time"
+)
+
+const hintPrefix = "hint: "
+
+// annotateRootCauseHints adds triage hints to the span warnings: the span with the most
+// self time on the critical path, the first error span and the largest idle gap before a child.
+func annotateRootCauseHints(trace *ui.Trace) {
+	if trace == nil || len(trace.Spans) == 0 {
+		return
+	}
+
+	spans, children, roots := spanTree(trace)
+	if span, self := slowestOnCriticalPath(roots, children); span != nil {
+		addHint(span, fmt.Sprintf("slowest span on the critical path, self time %v", usDuration(self)))
+	}
+
+	if span := firstErrorSpan(trace.Spans); span != nil {
+		addHint(span, "first error span of the trace")
+	}
+
+	if parent, child, gap := largestGap(spans, children); child != nil && gap > 0 {
+		addHint(child, fmt.Sprintf("largest gap of the trace, %v idle in parent %s before this span started", usDuration(gap), parent.OperationName))
+	}
+}
+
+// spanTree indexes the spans of the trace by id, and their children sorted by start time by parent id;
+// the spans without a parent in the trace are the roots.
+func spanTree(trace *ui.Trace) (map[ui.SpanID]*ui.Span, map[ui.SpanID][]*ui.Span, []*ui.Span) {
+	spans := make(map[ui.SpanID]*ui.Span, len(trace.Spans))
+	for i := range trace.Spans {
+		spans[trace.Spans[i].SpanID] = &trace.Spans[i]
+	}
+
+	children := make(map[ui.SpanID][]*ui.Span, len(trace.Spans))
+	roots := make([]*ui.Span, 0, 1)
+	for i := range trace.Spans {
+		span := &trace.Spans[i]
+		parentID := uiParentSpanID(span)
+		if _, ok := spans[parentID]; ok && parentID != span.SpanID {
+			children[parentID] = append(children[parentID], span)
+		} else {
+			roots = append(roots, span)
+		}
+	}
+	for _, c := range children {
+		sort.Slice(c, func(i, j int) bool { return c[i].StartTime < c[j].StartTime })
+	}
+	return spans, children, roots
+}
+
+func uiParentSpanID(span *ui.Span) ui.SpanID {
+	for _, ref := range span.References {
+		if ref.RefType == ui.ChildOf && ref.TraceID == span.TraceID {
+			return ref.SpanID
+		}
+	}
+	return span.ParentSpanID
+}
+
+// slowestOnCriticalPath walks from the longest root down to the last finishing child,
+// and returns the span of the path with the largest self time.
+func slowestOnCriticalPath(roots []*ui.Span, children map[ui.SpanID][]*ui.Span) (*ui.Span, uint64) {
+	var current *ui.Span
+	for _, root := range roots {
+		if current == nil || root.Duration > current.Duration {
+			current = root
+		}
+	}
+
+	var slowest *ui.Span
+	var slowestSelf uint64
+	visited := make(map[ui.SpanID]bool)
+	for current != nil && !visited[current.SpanID] {
+		visited[current.SpanID] = true
+		if self := selfTime(current, children[current.SpanID]); slowest == nil || self > slowestSelf {
+			slowest, slowestSelf = current, self
+		}
+
+		var next *ui.Span
+		for _, child := range children[current.SpanID] {
+			if next == nil || child.StartTime+child.Duration > next.StartTime+next.Duration {
+				next = child
+			}
+		}
+		current = next
+	}
+
+	return slowest, slowestSelf
+}
+
+// selfTime is the span duration not covered by any of its children, children are sorted by start time
+func selfTime(span *ui.Span, children []*ui.Span) uint64 {
+	start, end := span.StartTime, span.StartTime+span.Duration
+	var covered uint64
+	cursor := start
+	for _, child := range children {
+		cs, ce := child.StartTime, child.StartTime+child.Duration
+		if cs < cursor {
+			cs = cursor
+		}
+		if ce > end {
+			ce = end
+		}
+		if ce > cs {
+			covered += ce - cs
+			cursor = ce
+		}
+	}
+	if covered > span.Duration {
+		return 0
+	}
+	return span.Duration - covered
+}
+
+func firstErrorSpan(spans []ui.Span) *ui.Span {
+	var first *ui.Span
+	for i := range spans {
+		span := &spans[i]
+		if !uiSpanHasError(span) {
+			continue
+		}
+		if first == nil || span.StartTime < first.StartTime {
+			first = span
+		}
+	}
+	return first
+}
+
+func uiSpanHasError(span *ui.Span) bool {
+	for _, kv := range span.Tags {
+		if kv.Key == "error" && fmt.Sprint(kv.Value) == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// largestGap finds the longest time a parent had no child running before one of its children started
+func largestGap(spans map[ui.SpanID]*ui.Span, children map[ui.SpanID][]*ui.Span) (*ui.Span, *ui.Span, uint64) {
+	var gapParent, gapChild *ui.Span
+	var maxGap uint64
+	for parentID, c := range children {
+		parent := spans[parentID]
+		cursor := parent.StartTime
+		for _, child := range c {
+			if child.StartTime > cursor && child.StartTime-cursor > maxGap {
+				gapParent, gapChild, maxGap = parent, child, child.StartTime-cursor
+			}
+			if end := child.StartTime + child.Duration; end > cursor {
+				cursor = end
+			}
+		}
+	}
+	return gapParent, gapChild, maxGap
+}
+
+func addHint(span *ui.Span, hint string) {
+	span.Warnings = append(span.Warnings, hintPrefix+hint)
+}
+
+func usDuration(us uint64) time.Duration {
+	return time.Duration(us) * time.Microsecond
+}
@@ -0,0 +1,73 @@
+package jaeger_service
+
+import (
+	"encoding/json"
+	"github.com/jaegertracing/jaeger/plugin/storage/es/spanstore/dbmodel"
+	"strconv"
+	"strings"
+)
+
+const (
+	ArrayTagsJSON    = "json"    // one string tag holding the compact JSON array
+	ArrayTagsIndexed = "indexed" // one typed tag per element, key.0, key.1, ...
+)
+
+// attributeTags maps a span or resource attribute to its tags. Array values, decoded ones or JSON arrays
+// in a string column, follow openobserve.array_tags; the other values are one string tag as stored.
+func attributeTags(k string, v interface{}, mode string) []dbmodel.KeyValue {
+	if _, isString := v.(string); isString && mode != ArrayTagsIndexed {
+		// a JSON array string is the json form already
+		return []dbmodel.KeyValue{{Key: k, Type: dbmodel.StringType, Value: v}}
+	}
+
+	elements, ok := arrayValue(v)
+	if !ok {
+		return []dbmodel.KeyValue{{Key: k, Type: dbmodel.StringType, Value: v}}
+	}
+	if mode != ArrayTagsIndexed {
+		b, _ := json.Marshal(elements)
+		return []dbmodel.KeyValue{{Key: k, Type: dbmodel.StringType, Value: string(b)}}
+	}
+
+	kvs := make([]dbmodel.KeyValue, 0, len(elements))
+	for i, element := range elements {
+		kv := dbmodel.KeyValue{Key: k + "." + strconv.Itoa(i), Type: dbmodel.StringType}
+		switch e := element.(type) {
+		case string:
+			kv.Value = e
+		case bool:
+			kv.Type, kv.Value = dbmodel.BoolType, strconv.FormatBool(e)
+		case float64:
+			if e == float64(int64(e)) {
+				kv.Type, kv.Value = dbmodel.Int64Type, strconv.FormatInt(int64(e), 10)
+			} else {
+				kv.Type, kv.Value = dbmodel.Float64Type, strconv.FormatFloat(e, 'g', -1, 64)
+			}
+		case nil:
+			kv.Value = "null"
+		default:
+			b, _ := json.Marshal(e)
+			kv.Value = string(b)
+		}
+		kvs = append(kvs, kv)
+	}
+	return kvs
+}
+
+// arrayValue returns the elements of v if it is an array, decoded or a JSON array string.
+func arrayValue(v interface{}) ([]interface{}, bool) {
+	switch a := v.(type) {
+	case []interface{}:
+		return a, true
+	case string:
+		if !strings.HasPrefix(a, "[") || !strings.HasSuffix(a, "]") {
+			return nil, false
+		}
+		var elements []interface{}
+		if err := json.Unmarshal([]byte(a), &elements); err != nil {
+			return nil, false
+		}
+		return elements, true
+	}
+	return nil, false
+}
@@ -0,0 +1,89 @@
+package jaeger_service
+
+import (
+	"github.com/gin-gonic/gin"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"net/http"
+	"openobserve-jaeger/internal/openobserve_service"
+	"sync"
+	"time"
+)
+
+// BatchTrace is a trace of a batch fetch, StartTime and EndTime are optional hints of its time range.
+type BatchTrace struct {
+	TraceID   string
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// BatchTraceResult is the trace of a batch fetch or the error fetching it. A trace with skipped spans
+// comes with a 206 error.
+type BatchTraceResult struct {
+	Trace *ui.Trace              `json:"trace,omitempty"`
+	Error *JaegerStructuredError `json:"error,omitempty"`
+}
+
+// BatchTraces fetches the traces with at most concurrency queries at a time. The data maps every trace id
+// to its result, a trace failing doesn't fail the others; Total is the number of traces found.
+func (s *JaegerService) BatchTraces(ctx *gin.Context, traces []BatchTrace, concurrency int) JaegerStructuredResponse {
+	resp := JaegerStructuredResponse{
+		Errors: make([]JaegerStructuredError, 0),
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	unique := make([]BatchTrace, 0, len(traces))
+	seen := make(map[string]struct{}, len(traces))
+	for _, t := range traces {
+		if _, ok := seen[t.TraceID]; !ok {
+			seen[t.TraceID] = struct{}{}
+			unique = append(unique, t)
+		}
+	}
+
+	fetched := make([]*BatchTraceResult, len(unique))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i, t := range unique {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, t BatchTrace) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fetched[i] = s.batchTrace(ctx, t)
+		}(i, t)
+	}
+	wg.Wait()
+
+	results := make(map[string]*BatchTraceResult, len(unique))
+	for i, r := range fetched {
+		results[unique[i].TraceID] = r
+		if r.Trace != nil {
+			resp.Total++
+		}
+	}
+	resp.Data = results
+	return resp
+}
+
+func (s *JaegerService) batchTrace(ctx *gin.Context, t BatchTrace) *BatchTraceResult {
+	q := &openobserve_service.OOQuery{
+		TraceID:   t.TraceID,
+		StartTime: t.StartTime,
+		EndTime:   t.EndTime,
+	}
+	ooresp, jaegerErr := s.searchTraceSpans(ctx, q)
+	if jaegerErr != nil {
+		return &BatchTraceResult{Error: jaegerErr}
+	}
+
+	trace, jaegerErr := s.transOOToJaegerUI(ctx, ooresp, t.TraceID)
+	if jaegerErr != nil && jaegerErr.Code == 0 {
+		// skipped spans are a warning, the trace is still returned
+		jaegerErr.Code = http.StatusPartialContent
+	}
+	return &BatchTraceResult{Trace: trace, Error: jaegerErr}
+}
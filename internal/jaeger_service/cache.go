@@ -0,0 +1,131 @@
+package jaeger_service
+
+import (
+	"fmt"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/metrics"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultCacheMaxEntries = 1000
+
+var searchCacheRequests = metrics.NewCounter("search_cache_requests_total", "FindTraces cache lookups by result (hit or miss).", "result")
+
+// searchCache is a short TTL cache of FindTraces responses, keyed on the normalized query
+// with the time range bucketed by the TTL, so UI auto-refreshes hit the same entry.
+type searchCache struct {
+	mu      sync.Mutex
+	entries map[string]searchCacheEntry
+}
+
+type searchCacheEntry struct {
+	resp    JaegerStructuredResponse
+	expires time.Time
+}
+
+func newSearchCache() *searchCache {
+	return &searchCache{
+		entries: make(map[string]searchCacheEntry),
+	}
+}
+
+func (c *searchCache) get(key string) (JaegerStructuredResponse, bool) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if !ok || time.Now().After(entry.expires) {
+		searchCacheRequests.Inc("miss")
+		return JaegerStructuredResponse{}, false
+	}
+
+	searchCacheRequests.Inc("hit")
+	return entry.resp, true
+}
+
+func (c *searchCache) set(key string, resp JaegerStructuredResponse, ttl time.Duration, maxEntries int) {
+	if maxEntries <= 0 {
+		maxEntries = defaultCacheMaxEntries
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= maxEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	// still full with live entries, drop the ones expiring first
+	for len(c.entries) >= maxEntries {
+		var oldestKey string
+		var oldest time.Time
+		for k, entry := range c.entries {
+			if oldestKey == "" || entry.expires.Before(oldest) {
+				oldestKey, oldest = k, entry.expires
+			}
+		}
+		delete(c.entries, oldestKey)
+	}
+
+	c.entries[key] = searchCacheEntry{
+		resp:    resp,
+		expires: now.Add(ttl),
+	}
+}
+
+// Flush drops all the cached responses.
+func (c *searchCache) Flush() {
+	c.mu.Lock()
+	c.entries = make(map[string]searchCacheEntry)
+	c.mu.Unlock()
+}
+
+// searchCacheKey normalizes q, the order of services, operations and tags doesn't matter
+// and the time range is rounded down to the ttl.
+func searchCacheKey(q *TraceQueryParameters, ttl time.Duration) string {
+	sorted := func(values []string) string {
+		v := append([]string(nil), values...)
+		sort.Strings(v)
+		return strings.Join(v, ",")
+	}
+
+	tags := make([]string, 0, len(q.Tags)+len(q.TagGroups))
+	for k, v := range q.Tags {
+		tags = append(tags, k+"="+v)
+	}
+	for _, group := range q.TagGroups {
+		terms := make([]string, 0, len(group))
+		for _, t := range group {
+			terms = append(terms, t.Key+"="+t.Value)
+		}
+		sort.Strings(terms)
+		tags = append(tags, strings.Join(terms, "|"))
+	}
+	sort.Strings(tags)
+
+	bucket := func(t time.Time) int64 {
+		return t.Truncate(ttl).Unix()
+	}
+
+	tz := ""
+	if q.Location != nil {
+		tz = q.Location.String()
+	}
+
+	return fmt.Sprintf("ids=%s|svc=%s|down=%s|op=%s|tags=%s|start=%d|end=%d|dmin=%d|dmax=%d|limit=%d|default=%t|strategy=%s|sample=%g|tz=%s|strict=%t",
+		sorted(q.TraceIDs), sorted(q.ServiceName), sorted(q.DownstreamOf), sorted(q.OperationName), strings.Join(tags, ","),
+		bucket(q.StartTimeMin), bucket(q.StartTimeMax), q.DurationMin, q.DurationMax, q.NumTraces, q.DefaultLimit, searchStrategyName(q),
+		q.SampleRatio, tz, q.Strict)
+}
+
+func cacheConfig() (time.Duration, int, bool) {
+	cfg := config.Get().Cache
+	return time.Duration(cfg.TTL) * time.Second, cfg.MaxEntries, cfg.Enabled && cfg.TTL > 0
+}
@@ -0,0 +1,129 @@
+package jaeger_service
+
+import (
+	"github.com/gin-gonic/gin"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"net/http"
+	"openobserve-jaeger/internal/openobserve_service"
+	"sort"
+)
+
+const (
+	DiffAdded     = "added"     // only in trace b
+	DiffRemoved   = "removed"   // only in trace a
+	DiffChanged   = "changed"   // in both with another span or error count
+	DiffUnchanged = "unchanged" // in both with the same span and error counts
+)
+
+// TraceDiff is the /api/traces/compare response, the traces themselves and the diff of their spans
+// grouped by service and operation
+type TraceDiff struct {
+	A     *ui.Trace   `json:"a"`
+	B     *ui.Trace   `json:"b"`
+	Nodes []*DiffNode `json:"nodes"`
+}
+
+// DiffNode compares the spans of a service and operation in the two traces, durations in microseconds
+type DiffNode struct {
+	Service       string `json:"service"`
+	Operation     string `json:"operation"`
+	Status        string `json:"status"`
+	CountA        int    `json:"countA"`
+	CountB        int    `json:"countB"`
+	ErrorsA       int    `json:"errorsA"`
+	ErrorsB       int    `json:"errorsB"`
+	DurationA     uint64 `json:"durationA"` // sum of the span durations
+	DurationB     uint64 `json:"durationB"`
+	DurationDelta int64  `json:"durationDelta"` // DurationB - DurationA
+}
+
+// CompareTraces fetches the traces a and b in the time range of q and diffs their spans by service and operation.
+func (s *JaegerService) CompareTraces(ctx *gin.Context, q *openobserve_service.OOQuery, a, b string) JaegerStructuredResponse {
+	resp := JaegerStructuredResponse{
+		Errors: make([]JaegerStructuredError, 0),
+	}
+
+	traces := make([]*ui.Trace, 0, 2)
+	for _, traceID := range []string{a, b} {
+		tq := *q
+		tq.TraceID = traceID
+		ooresp, jaegerErr := s.searchTraceSpans(ctx, &tq)
+		if jaegerErr != nil {
+			resp.Errors = append(resp.Errors, *jaegerErr)
+			return resp
+		}
+
+		trace, jaegerErr := s.transOOToJaegerUI(ctx, ooresp, traceID)
+		if jaegerErr != nil {
+			// skipped spans are a warning, the diff of the others is still useful
+			if jaegerErr.Code == 0 {
+				jaegerErr.Code = http.StatusPartialContent
+			}
+			resp.Errors = append(resp.Errors, *jaegerErr)
+		}
+		traces = append(traces, trace)
+	}
+
+	resp.Data = TraceDiff{
+		A:     traces[0],
+		B:     traces[1],
+		Nodes: diffTraces(traces[0], traces[1]),
+	}
+	return resp
+}
+
+func diffTraces(a, b *ui.Trace) []*DiffNode {
+	nodes := make(map[[2]string]*DiffNode)
+	add := func(trace *ui.Trace, inB bool) {
+		for i := range trace.Spans {
+			span := &trace.Spans[i]
+			service := trace.Processes[span.ProcessID].ServiceName
+			key := [2]string{service, span.OperationName}
+			node, ok := nodes[key]
+			if !ok {
+				node = &DiffNode{Service: service, Operation: span.OperationName}
+				nodes[key] = node
+			}
+
+			failed := uiSpanHasError(span)
+			if inB {
+				node.CountB++
+				node.DurationB += span.Duration
+				if failed {
+					node.ErrorsB++
+				}
+			} else {
+				node.CountA++
+				node.DurationA += span.Duration
+				if failed {
+					node.ErrorsA++
+				}
+			}
+		}
+	}
+	add(a, false)
+	add(b, true)
+
+	res := make([]*DiffNode, 0, len(nodes))
+	for _, node := range nodes {
+		node.DurationDelta = int64(node.DurationB) - int64(node.DurationA)
+		switch {
+		case node.CountA == 0:
+			node.Status = DiffAdded
+		case node.CountB == 0:
+			node.Status = DiffRemoved
+		case node.CountA != node.CountB || node.ErrorsA != node.ErrorsB:
+			node.Status = DiffChanged
+		default:
+			node.Status = DiffUnchanged
+		}
+		res = append(res, node)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Service != res[j].Service {
+			return res[i].Service < res[j].Service
+		}
+		return res[i].Operation < res[j].Operation
+	})
+	return res
+}
@@ -0,0 +1,101 @@
+package jaeger_service
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"net/http"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/errors"
+	"openobserve-jaeger/internal/openobserve_service"
+	"sync"
+)
+
+// OrgTag is the process tag labeling the traces of a cross org lookup with their org
+const OrgTag = "openobserve.org"
+
+// FindTraceAcrossOrgs looks q.TraceID up in every org of cross_org.orgs concurrently, for tracking a trace
+// when the owning team is unknown. Every org it's found in returns its own trace labeled with OrgTag,
+// the failed orgs become 206 warnings; 404 only if no org has the trace.
+func (s *JaegerService) FindTraceAcrossOrgs(ctx *gin.Context, q *openobserve_service.OOQuery) JaegerStructuredResponse {
+	resp := JaegerStructuredResponse{
+		Data:   make([]*ui.Trace, 0),
+		Errors: make([]JaegerStructuredError, 0),
+	}
+
+	type result struct {
+		trace *ui.Trace
+		err   *JaegerStructuredError
+	}
+	orgs := config.Get().CrossOrg.Orgs
+	results := make([]result, len(orgs))
+
+	var wg sync.WaitGroup
+	for i, org := range orgs {
+		// the copy carries the org to the OpenObserve queries without racing the other orgs
+		orgCtx := ctx.Copy()
+		orgCtx.Set(openobserve_service.OrgContextKey, org)
+
+		wg.Add(1)
+		go func(i int, org string, orgCtx *gin.Context) {
+			defer wg.Done()
+			ooresp, jaegerErr := s.searchTraceSpans(orgCtx, q)
+			if jaegerErr != nil {
+				results[i].err = jaegerErr
+				return
+			}
+			trace, jaegerErr := s.transOOToJaegerUI(orgCtx, ooresp, q.TraceID)
+			labelTraceOrg(trace, org)
+			results[i] = result{trace: trace, err: jaegerErr}
+		}(i, org, orgCtx)
+	}
+	wg.Wait()
+
+	traces := make([]*ui.Trace, 0, len(orgs))
+	var failure *JaegerStructuredError
+	for i, r := range results {
+		if r.trace != nil {
+			traces = append(traces, r.trace)
+		}
+		if r.err == nil || r.err.Code == http.StatusNotFound {
+			continue
+		}
+		msg := fmt.Sprintf("org %s: %s", orgs[i], r.err.Msg)
+		if r.trace == nil && failure == nil {
+			failure = &JaegerStructuredError{Code: r.err.Code, Msg: msg, TraceID: ui.TraceID(q.TraceID), err: r.err.err}
+		}
+		resp.Errors = append(resp.Errors, JaegerStructuredError{
+			Code:    http.StatusPartialContent,
+			Msg:     msg,
+			TraceID: ui.TraceID(q.TraceID),
+		})
+	}
+
+	if len(traces) == 0 {
+		if failure == nil {
+			e := structuredError(errors.NotFound("trace not found in any org"))
+			e.TraceID = ui.TraceID(q.TraceID)
+			failure = &e
+		}
+		resp.Errors = []JaegerStructuredError{*failure}
+		return resp
+	}
+
+	resp.Data = traces
+	resp.Total = len(traces)
+	return resp
+}
+
+func labelTraceOrg(trace *ui.Trace, org string) {
+	if trace == nil {
+		return
+	}
+	for id, process := range trace.Processes {
+		process.Tags = append(process.Tags, ui.KeyValue{
+			Key:   OrgTag,
+			Type:  ui.StringType,
+			Value: org,
+		})
+		trace.Processes[id] = process
+	}
+}
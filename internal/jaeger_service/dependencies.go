@@ -0,0 +1,86 @@
+package jaeger_service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"openobserve-jaeger/internal/openobserve_service"
+	"time"
+)
+
+// DependencyLink is jaeger's DependencyLink with the error count of the calls
+type DependencyLink struct {
+	Parent     string `json:"parent"`
+	Child      string `json:"child"`
+	CallCount  uint64 `json:"callCount"`
+	ErrorCount uint64 `json:"errorCount"`
+}
+
+// dependenciesSQL joins every span to its parent span and counts the cross-service calls,
+// a call is an error when the child span status is ERROR.
+const dependenciesSQL = "SELECT p.%[1]s AS parent, c.%[1]s AS child, COUNT(*) AS call_count, " +
+	"SUM(CASE WHEN c.%[2]s = 'ERROR' THEN 1 ELSE 0 END) AS error_count " +
+	"FROM default AS c JOIN default AS p ON c.%[3]s = p.%[3]s AND c.%[4]s = p.%[5]s " +
+	"WHERE p.%[1]s != c.%[1]s " +
+	"GROUP BY p.%[1]s, c.%[1]s"
+
+// GetDependencies returns the service call graph of the spans started in [endTs-lookback, endTs].
+func (s *JaegerService) GetDependencies(ctx *gin.Context, endTs time.Time, lookback time.Duration) JaegerStructuredResponse {
+	jaegerResp := JaegerStructuredResponse{
+		Data:   make([]DependencyLink, 0),
+		Errors: make([]JaegerStructuredError, 0),
+	}
+
+	links, err := s.findDependencies(ctx, endTs, lookback)
+	if err != nil {
+		jaegerResp.Errors = append(jaegerResp.Errors, structuredError(err))
+
+		return jaegerResp
+	}
+
+	jaegerResp.Data = links
+	jaegerResp.Total = len(links)
+	return jaegerResp
+}
+
+func (s *JaegerService) findDependencies(ctx *gin.Context, endTs time.Time, lookback time.Duration) ([]DependencyLink, error) {
+	sql := fmt.Sprintf(dependenciesSQL, fieldColumn(OOSpanFixedKey.ServiceName), fieldColumn(OOSpanFixedKey.SpanStatus),
+		fieldColumn(OOSpanFixedKey.TraceID), fieldColumn(OOSpanFixedKey.ReferenceParentSpanId), fieldColumn(OOSpanFixedKey.SpanID))
+	qq := openobserve_service.OOSearchQuery{
+		Stream: openobserve_service.SearchTraceDefaultStream,
+		Query: openobserve_service.OOSearchQueryQuery{
+			StartTime: endTs.Add(-lookback).UnixMicro(),
+			EndTime:   endTs.UnixMicro(),
+			Sql:       base64.StdEncoding.EncodeToString([]byte(sql)),
+			Size:      -1,
+		},
+	}
+
+	ooresp, err := s.ooservice.SearchTraces(ctx, qq)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]DependencyLink, 0, len(ooresp.Hits))
+	index := make(map[[2]string]int, len(ooresp.Hits))
+	for _, hit := range ooresp.Hits {
+		link := DependencyLink{
+			Parent:     cast.ToString(hit["parent"]),
+			Child:      cast.ToString(hit["child"]),
+			CallCount:  cast.ToUint64(hit["call_count"]),
+			ErrorCount: cast.ToUint64(hit["error_count"]),
+		}
+		// a pair is found once per cluster of openobserve.clusters
+		key := [2]string{link.Parent, link.Child}
+		if i, ok := index[key]; ok {
+			links[i].CallCount += link.CallCount
+			links[i].ErrorCount += link.ErrorCount
+			continue
+		}
+		index[key] = len(links)
+		links = append(links, link)
+	}
+
+	return links, nil
+}
@@ -0,0 +1,196 @@
+package jaeger_service
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/jaegertracing/jaeger/model"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"github.com/jaegertracing/jaeger/plugin/storage/es/spanstore/dbmodel"
+	"openobserve-jaeger/internal/openobserve_service"
+	"sort"
+	"strings"
+)
+
+// SpanDiagnostics describes how one OpenObserve span was converted to the Jaeger model.
+type SpanDiagnostics struct {
+	SpanID        string            `json:"spanID"`
+	MappedFields  map[string]string `json:"mappedFields"`  // OpenObserve column -> Jaeger field
+	DroppedFields []string          `json:"droppedFields"` // OpenObserve columns missing in the Jaeger span
+	Adjustments   []string          `json:"adjustments"`   // modifications made by the adjusters
+	Error         string            `json:"error,omitempty"`
+}
+
+// fixedFieldTargets are the Jaeger fields of the OpenObserve columns which are not converted to tags
+var fixedFieldTargets = map[string]string{
+	OOSpanFixedKey.ServiceName:           "process.serviceName",
+	OOSpanFixedKey.StartTime:             "startTime",
+	OOSpanFixedKey.TraceID:               "traceID",
+	OOSpanFixedKey.SpanID:                "spanID",
+	OOSpanFixedKey.Duration:              "duration",
+	OOSpanFixedKey.Flags:                 "flags",
+	OOSpanFixedKey.OperationName:         "operationName",
+	OOSpanFixedKey.SpanKind:              "tags.span.kind",
+	OOSpanFixedKey.SpanStatus:            "tags.otel.status_code",
+	OOSpanFixedKey.Events:                "logs",
+	OOSpanFixedKey.ReferenceParentSpanId: "references",
+	OOSpanFixedKey.Links:                 "references",
+	OOSpanFixedKey.TraceState:            "tags.w3c.tracestate",
+}
+
+// DiagnoseConversion re-fetches a trace and reports per span which columns were mapped or dropped,
+// whether the span failed to convert, and what the adjusters changed.
+func (s *JaegerService) DiagnoseConversion(ctx *gin.Context, q *openobserve_service.OOQuery) JaegerStructuredResponse {
+	resp := JaegerStructuredResponse{
+		Errors: make([]JaegerStructuredError, 0),
+	}
+
+	ooresp, jaegerErr := s.searchTraceSpans(ctx, q)
+	if jaegerErr != nil {
+		resp.Errors = append(resp.Errors, *jaegerErr)
+		return resp
+	}
+
+	spanConverter := NewToDomain("@")
+	diags := make([]*SpanDiagnostics, 0, len(ooresp.Hits))
+	converted := make([]*SpanDiagnostics, 0, len(ooresp.Hits))
+	trace := &model.Trace{Spans: make([]*model.Span, 0, len(ooresp.Hits))}
+	for _, oospan := range ooresp.Hits {
+		dbSpan, warnings := s.transOOSpanToDbModelSpan(ctx, oospan)
+		if dbSpan == nil {
+			continue
+		}
+
+		diag := diagnoseFields(oospan, dbSpan)
+		diags = append(diags, diag)
+
+		span, err := spanConverter.SpanToDomain(dbSpan)
+		if err != nil {
+			diag.Error = err.Error()
+			continue
+		}
+		span.Warnings = append(span.Warnings, warnings...)
+		trace.Spans = append(trace.Spans, span)
+		converted = append(converted, diag)
+	}
+
+	before := make([]spanSnapshot, len(trace.Spans))
+	for i, span := range trace.Spans {
+		before[i] = snapshotSpan(span)
+	}
+
+	adjusted, err := s.adjuster.Adjust(trace)
+	if err != nil {
+		resp.Errors = append(resp.Errors, JaegerStructuredError{
+			Msg:     err.Error(),
+			TraceID: ui.TraceID(q.TraceID),
+		})
+	}
+	if adjusted != nil && len(adjusted.Spans) == len(before) {
+		for i, span := range adjusted.Spans {
+			converted[i].Adjustments = before[i].diff(snapshotSpan(span))
+		}
+	}
+
+	resp.Data = diags
+	resp.Total = len(diags)
+	return resp
+}
+
+func diagnoseFields(oospan map[string]interface{}, dbSpan *dbmodel.Span) *SpanDiagnostics {
+	diag := &SpanDiagnostics{
+		SpanID:        string(dbSpan.SpanID),
+		MappedFields:  make(map[string]string),
+		DroppedFields: make([]string, 0),
+		Adjustments:   make([]string, 0),
+	}
+
+	tags := make(map[string]struct{}, len(dbSpan.Tags))
+	for _, kv := range dbSpan.Tags {
+		tags[kv.Key] = struct{}{}
+	}
+	processTags := make(map[string]struct{}, len(dbSpan.Process.Tags))
+	for _, kv := range dbSpan.Process.Tags {
+		processTags[kv.Key] = struct{}{}
+	}
+
+	for k := range oospan {
+		if target, ok := fixedFieldTargets[k]; ok {
+			diag.MappedFields[k] = target
+			continue
+		}
+		if _, ok := tags[k]; ok {
+			diag.MappedFields[k] = "tags." + k
+			continue
+		}
+		if _, ok := processTags[k]; ok {
+			diag.MappedFields[k] = "process.tags." + k
+			continue
+		}
+		diag.DroppedFields = append(diag.DroppedFields, k)
+	}
+	sort.Strings(diag.DroppedFields)
+
+	return diag
+}
+
+// spanSnapshot keeps the span parts the adjusters may modify
+type spanSnapshot struct {
+	spanID     string
+	tags       map[string]string
+	references int
+	logFields  []string
+	warnings   int
+}
+
+func snapshotSpan(span *model.Span) spanSnapshot {
+	snap := spanSnapshot{
+		spanID:     span.SpanID.String(),
+		tags:       make(map[string]string, len(span.Tags)),
+		references: len(span.References),
+		logFields:  make([]string, 0, len(span.Logs)),
+		warnings:   len(span.Warnings),
+	}
+	for _, kv := range span.Tags {
+		snap.tags[kv.Key] = kv.VType.String() + ":" + kv.AsString()
+	}
+	for _, l := range span.Logs {
+		keys := make([]string, 0, len(l.Fields))
+		for _, f := range l.Fields {
+			keys = append(keys, f.Key)
+		}
+		snap.logFields = append(snap.logFields, strings.Join(keys, ","))
+	}
+	return snap
+}
+
+func (snap spanSnapshot) diff(after spanSnapshot) []string {
+	changes := make([]string, 0)
+	if snap.spanID != after.spanID {
+		changes = append(changes, fmt.Sprintf("spanID changed from %s to %s", snap.spanID, after.spanID))
+	}
+	for k, v := range snap.tags {
+		if av, ok := after.tags[k]; !ok {
+			changes = append(changes, fmt.Sprintf("tag %s removed", k))
+		} else if av != v {
+			changes = append(changes, fmt.Sprintf("tag %s changed from %s to %s", k, v, av))
+		}
+	}
+	for k := range after.tags {
+		if _, ok := snap.tags[k]; !ok {
+			changes = append(changes, fmt.Sprintf("tag %s added", k))
+		}
+	}
+	if snap.references != after.references {
+		changes = append(changes, fmt.Sprintf("references changed from %d to %d", snap.references, after.references))
+	}
+	for i := range snap.logFields {
+		if i < len(after.logFields) && snap.logFields[i] != after.logFields[i] {
+			changes = append(changes, fmt.Sprintf("log %d fields reordered", i))
+		}
+	}
+	if after.warnings > snap.warnings {
+		changes = append(changes, fmt.Sprintf("%d warnings added", after.warnings-snap.warnings))
+	}
+	sort.Strings(changes)
+	return changes
+}
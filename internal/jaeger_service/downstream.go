@@ -0,0 +1,47 @@
+package jaeger_service
+
+import (
+	"github.com/gin-gonic/gin"
+	"openobserve-jaeger/internal/errors"
+	"sort"
+)
+
+// expandDownstream adds q.DownstreamOf and every service they call, directly or through other services,
+// to the services of q. The call graph is the dependency graph of the q time range.
+func (s *JaegerService) expandDownstream(ctx *gin.Context, q *TraceQueryParameters) (*TraceQueryParameters, *JaegerStructuredError) {
+	links, err := s.findDependencies(ctx, q.StartTimeMax, q.StartTimeMax.Sub(q.StartTimeMin))
+	if err != nil {
+		e := structuredError(errors.Wrap(err, "downstreamOf dependency lookup failed"))
+		return nil, &e
+	}
+
+	calls := make(map[string][]string)
+	for _, link := range links {
+		calls[link.Parent] = append(calls[link.Parent], link.Child)
+	}
+
+	services := make(map[string]struct{})
+	for _, service := range q.ServiceName {
+		services[service] = struct{}{}
+	}
+	visited := make(map[string]struct{})
+	queue := append([]string(nil), q.DownstreamOf...)
+	for len(queue) > 0 {
+		service := queue[0]
+		queue = queue[1:]
+		if _, ok := visited[service]; ok {
+			continue
+		}
+		visited[service] = struct{}{}
+		services[service] = struct{}{}
+		queue = append(queue, calls[service]...)
+	}
+
+	qq := *q
+	qq.ServiceName = make([]string, 0, len(services))
+	for service := range services {
+		qq.ServiceName = append(qq.ServiceName, service)
+	}
+	sort.Strings(qq.ServiceName)
+	return &qq, nil
+}
@@ -0,0 +1,74 @@
+package jaeger_service
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/openobserve_service"
+	"strconv"
+	"time"
+)
+
+// spanStream is the stream holding the spans of the request, the trace stream of the service override or default.
+func spanStream(ctx *gin.Context) string {
+	if stream := traceStreamOverride(ctx); stream != "" {
+		return stream
+	}
+	return openobserve_service.SearchTraceDefaultStream
+}
+
+// spanDurationUnit is the openobserve.streams duration_unit of the span stream of ctx, microseconds by default.
+func spanDurationUnit(ctx *gin.Context) string {
+	if unit := config.Get().OpenObserve.Streams[spanStream(ctx)].DurationUnit; unit != "" {
+		return unit
+	}
+	return config.StreamDurationMicroseconds
+}
+
+// durationCondition compares the span durations to d with op, d in the unit of the duration column. The
+// auto unit compares end_time - start_time, both unix nanoseconds, whatever the duration column holds.
+func durationCondition(ctx *gin.Context, column func(string) string, op string, d time.Duration) string {
+	switch spanDurationUnit(ctx) {
+	case config.StreamDurationNanoseconds:
+		return fmt.Sprintf("%s %s %d", column(OOSpanFixedKey.Duration), op, d.Nanoseconds())
+	case config.StreamDurationMilliseconds:
+		// minDuration=1.5ms stays 1.5, the truncated 1 would let the 1ms spans through
+		ms := strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64)
+		return fmt.Sprintf("%s %s %s", column(OOSpanFixedKey.Duration), op, ms)
+	case config.StreamDurationAuto:
+		return fmt.Sprintf("(%s - %s) %s %d", column(OOSpanFixedKey.EndTime), column(OOSpanFixedKey.StartTime), op, d.Nanoseconds())
+	}
+	return fmt.Sprintf("%s %s %d", column(OOSpanFixedKey.Duration), op, d.Microseconds())
+}
+
+// durationMicrosSQL is the SQL expression of the span durations in microseconds, for the aggregations
+// reading the duration column in the unit of the span stream of ctx.
+func durationMicrosSQL(ctx *gin.Context, column func(string) string) string {
+	switch spanDurationUnit(ctx) {
+	case config.StreamDurationNanoseconds:
+		return fmt.Sprintf("(%s / 1000.0)", column(OOSpanFixedKey.Duration))
+	case config.StreamDurationMilliseconds:
+		return fmt.Sprintf("(%s * 1000)", column(OOSpanFixedKey.Duration))
+	case config.StreamDurationAuto:
+		return fmt.Sprintf("((%s - %s) / 1000.0)", column(OOSpanFixedKey.EndTime), column(OOSpanFixedKey.StartTime))
+	}
+	return column(OOSpanFixedKey.Duration)
+}
+
+// spanDurationMicros is the duration of the OpenObserve span oo in microseconds, the unit of dbmodel.Span.
+func spanDurationMicros(ctx *gin.Context, oo map[string]interface{}) uint64 {
+	switch spanDurationUnit(ctx) {
+	case config.StreamDurationNanoseconds:
+		return cast.ToUint64(oo[OOSpanFixedKey.Duration]) / 1e3
+	case config.StreamDurationMilliseconds:
+		return uint64(cast.ToFloat64(oo[OOSpanFixedKey.Duration]) * 1e3)
+	case config.StreamDurationAuto:
+		// the spans missing a timestamp keep the duration column, read as microseconds
+		start, end := cast.ToInt64(oo[OOSpanFixedKey.StartTime]), cast.ToInt64(oo[OOSpanFixedKey.EndTime])
+		if start > 0 && end >= start {
+			return uint64(end-start) / 1e3
+		}
+	}
+	return cast.ToUint64(oo[OOSpanFixedKey.Duration])
+}
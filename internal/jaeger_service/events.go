@@ -0,0 +1,350 @@
+package jaeger_service
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jaegertracing/jaeger/plugin/storage/es/spanstore/dbmodel"
+	"github.com/spf13/cast"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// nonFiniteTokens are the number literals some exporters write but JSON doesn't have
+var nonFiniteTokens = []string{"-Infinity", "+Infinity", "Infinity", "NaN"}
+
+const (
+	eventNameField    = "event"                               // jaeger log field of the OTLP event name
+	eventDroppedField = "otel.event.dropped_attributes_count" // jaeger log field of the OTLP dropped attributes count
+)
+
+// eventTimestampKeys hold the unix nanoseconds of an event, OpenObserve writes _timestamp, OTLP JSON time_unix_nano
+var eventTimestampKeys = []string{"_timestamp", "time_unix_nano", "timeUnixNano"}
+
+// parseOOEvents decodes the events column in the forms of the ingestion paths: a JSON string of an array
+// (or of one event), a native array of objects or a single object. See parseOOEventsJSON for the repair of
+// a malformed string. warning says what was repaired or dropped, it is empty if the column decoded as is.
+func parseOOEvents(events interface{}) ([]map[string]interface{}, string) {
+	switch v := events.(type) {
+	case nil:
+		return make([]map[string]interface{}, 0), ""
+	case string:
+		return parseOOEventsJSON(v)
+	case []byte:
+		return parseOOEventsJSON(string(v))
+	case map[string]interface{}:
+		return []map[string]interface{}{v}, ""
+	case []map[string]interface{}:
+		return v, ""
+	case []interface{}:
+		evs := make([]map[string]interface{}, 0, len(v))
+		for _, element := range v {
+			if ev, ok := element.(map[string]interface{}); ok {
+				evs = append(evs, ev)
+			}
+		}
+		if dropped := len(v) - len(evs); dropped > 0 {
+			return evs, fmt.Sprintf("events column has %d of %d elements which are no objects, dropped", dropped, len(v))
+		}
+		return evs, ""
+	}
+	return make([]map[string]interface{}, 0), fmt.Sprintf("events column of type %T is no array, all events dropped", events)
+}
+
+// parseOOEventsJSON decodes the JSON array of the events column, numbers as json.Number to keep their type.
+// A malformed array is repaired, trailing commas are dropped and NaN/Infinity become null, then decoded event
+// by event so one broken event doesn't cost the others.
+func parseOOEventsJSON(raw string) ([]map[string]interface{}, string) {
+	evs := make([]map[string]interface{}, 0)
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" || trimmed == "null" {
+		return evs, ""
+	}
+	if strings.HasPrefix(trimmed, "{") {
+		ev := make(map[string]interface{})
+		if err := decodeJSON(trimmed, &ev); err != nil {
+			return evs, "events column is a malformed JSON object, the event is dropped"
+		}
+		return append(evs, ev), ""
+	}
+	if err := decodeJSON(raw, &evs); err == nil {
+		return evs, ""
+	}
+
+	repaired := repairJSON(raw)
+	elements, ok := splitJSONArray(repaired)
+	if !ok {
+		return evs, "events column is no JSON array, all events dropped"
+	}
+
+	dropped := 0
+	for _, element := range elements {
+		ev := make(map[string]interface{})
+		if err := decodeJSON(element, &ev); err != nil {
+			dropped++
+			continue
+		}
+		evs = append(evs, ev)
+	}
+
+	if dropped > 0 {
+		return evs, fmt.Sprintf("events column is malformed JSON, %d of %d events dropped", dropped, len(elements))
+	}
+	return evs, "events column is malformed JSON, repaired"
+}
+
+func decodeJSON(raw string, v interface{}) error {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// eventLog maps an OTLP event to a span log: the name is the event field, the attributes (flattened by
+// OpenObserve, or nested in attributes as an object or OTLP JSON key/values) are typed fields, and a
+// dropped_attributes_count > 0 becomes otel.event.dropped_attributes_count.
+func eventLog(ev map[string]interface{}) dbmodel.Log {
+	log := dbmodel.Log{
+		Fields: make([]dbmodel.KeyValue, 0, len(ev)),
+	}
+
+	fields := make(map[string]interface{}, len(ev))
+	for k, v := range ev {
+		switch k {
+		case "_timestamp", "time_unix_nano", "timeUnixNano":
+		case "name":
+			fields[eventNameField] = v
+		case "dropped_attributes_count", "droppedAttributesCount":
+			if n, ok := intValue(v); ok && n > 0 {
+				fields[eventDroppedField] = json.Number(strconv.FormatInt(n, 10))
+			}
+		case "attributes":
+			if !flattenEventAttributes(fields, v) {
+				fields[k] = v
+			}
+		default:
+			fields[k] = v
+		}
+	}
+	for _, k := range eventTimestampKeys {
+		if n, ok := intValue(ev[k]); ok {
+			log.Timestamp = uint64(n / 1e3)
+			break
+		}
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		log.Fields = append(log.Fields, typedField(k, fields[k]))
+	}
+	return log
+}
+
+// flattenEventAttributes adds the nested attributes to fields, ok is false if v is no attributes form.
+func flattenEventAttributes(fields map[string]interface{}, v interface{}) bool {
+	switch attrs := v.(type) {
+	case map[string]interface{}:
+		for k, vv := range attrs {
+			fields[k] = vv
+		}
+		return true
+	case []interface{}:
+		// OTLP JSON: [{"key": "k", "value": {"stringValue": "v"}}]
+		for _, element := range attrs {
+			kv, ok := element.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			key, ok := kv["key"].(string)
+			if !ok {
+				return false
+			}
+			fields[key] = otlpAnyValue(kv["value"])
+		}
+		return true
+	}
+	return false
+}
+
+// otlpAnyValue unwraps an OTLP JSON AnyValue, e.g. {"intValue": "3"}.
+func otlpAnyValue(v interface{}) interface{} {
+	value, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	for k, vv := range value {
+		switch k {
+		case "stringValue", "boolValue", "doubleValue":
+			return vv
+		case "intValue":
+			// int64 values are JSON strings in OTLP JSON
+			if s, ok := vv.(string); ok {
+				return json.Number(s)
+			}
+			return vv
+		case "arrayValue", "kvlistValue", "bytesValue":
+			return vv
+		}
+	}
+	return v
+}
+
+// typedField keeps the type of a decoded event value, numbers are int64 if they are integral.
+func typedField(k string, v interface{}) dbmodel.KeyValue {
+	kv := dbmodel.KeyValue{Key: k, Type: dbmodel.StringType}
+	switch value := v.(type) {
+	case string:
+		kv.Value = value
+	case bool:
+		kv.Type, kv.Value = dbmodel.BoolType, strconv.FormatBool(value)
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			kv.Type, kv.Value = dbmodel.Int64Type, strconv.FormatInt(n, 10)
+		} else if f, err := value.Float64(); err == nil {
+			kv.Type, kv.Value = dbmodel.Float64Type, strconv.FormatFloat(f, 'g', -1, 64)
+		} else {
+			kv.Value = value.String()
+		}
+	case float64:
+		if value == float64(int64(value)) {
+			kv.Type, kv.Value = dbmodel.Int64Type, strconv.FormatInt(int64(value), 10)
+		} else {
+			kv.Type, kv.Value = dbmodel.Float64Type, strconv.FormatFloat(value, 'g', -1, 64)
+		}
+	case int, int32, int64, uint32, uint64:
+		kv.Type, kv.Value = dbmodel.Int64Type, cast.ToString(value)
+	case nil:
+		kv.Value = ""
+	default:
+		b, _ := json.Marshal(value)
+		kv.Value = string(b)
+	}
+	return kv
+}
+
+// intValue reads the decoded integer v, a json.Number, a float64 or a numeric string.
+func intValue(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return i, true
+		}
+		f, err := n.Float64()
+		return int64(f), err == nil
+	case nil:
+		return 0, false
+	}
+	i, err := cast.ToInt64E(v)
+	return i, err == nil
+}
+
+// repairJSON drops the trailing commas and replaces the NaN and Infinity literals with null,
+// the string contents are left alone.
+func repairJSON(raw string) string {
+	var b strings.Builder
+	b.Grow(len(raw))
+
+	inString, escaped := false, false
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if inString {
+			b.WriteByte(c)
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case ',':
+			j := i + 1
+			for j < len(raw) && strings.IndexByte(" \t\r\n", raw[j]) >= 0 {
+				j++
+			}
+			if j < len(raw) && (raw[j] == '}' || raw[j] == ']') {
+				continue
+			}
+		default:
+			replaced := false
+			for _, token := range nonFiniteTokens {
+				if strings.HasPrefix(raw[i:], token) {
+					b.WriteString("null")
+					i += len(token) - 1
+					replaced = true
+					break
+				}
+			}
+			if replaced {
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+// splitJSONArray returns the top level elements of a JSON array without decoding them,
+// ok is false if raw is no array.
+func splitJSONArray(raw string) ([]string, bool) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) < 2 || raw[0] != '[' {
+		return nil, false
+	}
+
+	elements := make([]string, 0)
+	depth, start := 0, 1
+	inString, escaped := false, false
+	add := func(end int) {
+		if element := strings.TrimSpace(raw[start:end]); element != "" {
+			elements = append(elements, element)
+		}
+		start = end + 1
+	}
+	for i := 1; i < len(raw); i++ {
+		c := raw[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth == 0 {
+				// the closing bracket of the array, anything after it is ignored
+				add(i)
+				return elements, true
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				add(i)
+			}
+		}
+	}
+
+	// a truncated array, keep the complete elements
+	add(len(raw))
+	return elements, true
+}
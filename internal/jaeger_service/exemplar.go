@@ -0,0 +1,114 @@
+package jaeger_service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"openobserve-jaeger/internal/errors"
+	"openobserve-jaeger/internal/openobserve_service"
+	"strings"
+	"time"
+)
+
+// Exemplar is the /api/exemplars response, a span near the latency percentile of a service (and operation),
+// durations in microseconds
+type Exemplar struct {
+	Percentile float64 `json:"percentile"`
+	Latency    int64   `json:"latency"` // the approximate percentile duration of the spans
+	TraceID    string  `json:"traceID"`
+	SpanID     string  `json:"spanID"`
+	Duration   int64   `json:"duration"`  // of the exemplar span, the shortest one >= latency
+	StartTime  int64   `json:"startTime"` // unix microseconds
+	Path       string  `json:"path"`      // of the trace in the jaeger ui
+}
+
+// FindExemplar finds a span of the service (and operation if not empty) started in [start, end] near the
+// latency percentile: OpenObserve aggregates the percentile duration, then the shortest span at or above it is
+// fetched, so alert runbooks can link straight to a representative trace.
+func (s *JaegerService) FindExemplar(ctx *gin.Context, service, operation string, percentile float64, start, end time.Time) JaegerStructuredResponse {
+	jaegerResp := JaegerStructuredResponse{
+		Errors: make([]JaegerStructuredError, 0),
+	}
+
+	duration := durationMicrosSQL(ctx, fieldColumn)
+	cond := []string{fieldColumn(OOSpanFixedKey.ServiceName) + " = " + sqlString(service)}
+	if operation != "" {
+		cond = append(cond, fieldColumn(OOSpanFixedKey.OperationName)+" = "+sqlString(operation))
+	}
+
+	sql := fmt.Sprintf("SELECT approx_percentile_cont(%s, %g) AS latency FROM default WHERE %s",
+		duration, percentile/100, strings.Join(cond, " AND "))
+	ooresp, err := s.searchExemplar(ctx, sql, start, end)
+	if err != nil {
+		jaegerResp.Errors = append(jaegerResp.Errors, *err)
+		return jaegerResp
+	}
+	// one row per cluster of openobserve.clusters, the highest one is the conservative estimate
+	var latency int64
+	found := false
+	for _, hit := range ooresp.Hits {
+		if hit["latency"] == nil {
+			continue
+		}
+		if v := cast.ToInt64(hit["latency"]); !found || v > latency {
+			latency, found = v, true
+		}
+	}
+	if !found {
+		jaegerResp.Errors = append(jaegerResp.Errors, structuredError(errors.NotFound("no spans of the service in the time range")))
+		return jaegerResp
+	}
+
+	sql = fmt.Sprintf("SELECT %s AS trace_id, %s AS span_id, %s AS duration, %s AS start_time FROM default WHERE %s AND %s ORDER BY %s LIMIT 1",
+		fieldColumn(OOSpanFixedKey.TraceID), fieldColumn(OOSpanFixedKey.SpanID), duration, fieldColumn(OOSpanFixedKey.StartTime),
+		strings.Join(cond, " AND "), durationCondition(ctx, fieldColumn, ">=", time.Duration(latency)*time.Microsecond), duration)
+	ooresp, err = s.searchExemplar(ctx, sql, start, end)
+	if err != nil {
+		jaegerResp.Errors = append(jaegerResp.Errors, *err)
+		return jaegerResp
+	}
+
+	var exemplar *Exemplar
+	for _, hit := range ooresp.Hits {
+		e := &Exemplar{
+			Percentile: percentile,
+			Latency:    latency,
+			TraceID:    cast.ToString(hit[OOSpanFixedKey.TraceID]),
+			SpanID:     cast.ToString(hit[OOSpanFixedKey.SpanID]),
+			Duration:   cast.ToInt64(hit[OOSpanFixedKey.Duration]),
+			StartTime:  cast.ToInt64(hit[OOSpanFixedKey.StartTime]) / 1e3, // start_time is unix nanoseconds
+		}
+		if exemplar == nil || e.Duration < exemplar.Duration {
+			exemplar = e
+		}
+	}
+	if exemplar == nil {
+		jaegerResp.Errors = append(jaegerResp.Errors, structuredError(errors.NotFound(fmt.Sprintf("no span at or above the p%g latency of %d us", percentile, latency))))
+		return jaegerResp
+	}
+	exemplar.Path = "/trace/" + exemplar.TraceID
+
+	jaegerResp.Data = exemplar
+	jaegerResp.Total = 1
+	return jaegerResp
+}
+
+func (s *JaegerService) searchExemplar(ctx *gin.Context, sql string, start, end time.Time) (*openobserve_service.OpenObserveResp, *JaegerStructuredError) {
+	qq := openobserve_service.OOSearchQuery{
+		Stream: openobserve_service.SearchTraceDefaultStream,
+		Query: openobserve_service.OOSearchQueryQuery{
+			StartTime: start.UnixMicro(),
+			EndTime:   end.UnixMicro(),
+			Sql:       base64.StdEncoding.EncodeToString([]byte(sql)),
+			Size:      -1,
+		},
+	}
+
+	ooresp, err := s.ooservice.SearchTraces(ctx, qq)
+	if err != nil {
+		e := structuredError(err)
+		return nil, &e
+	}
+	return ooresp, nil
+}
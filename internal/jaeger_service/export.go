@@ -0,0 +1,160 @@
+package jaeger_service
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/jaegertracing/jaeger/model"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"github.com/spf13/cast"
+	"io"
+	"openobserve-jaeger/internal/otlp"
+	"sort"
+	"strconv"
+)
+
+// The formats of /api/traces/:id/export
+const (
+	ExportJaeger = "jaeger" // {"data": [trace]}, the file the jaeger UI uploads
+	ExportOTLP   = "otlp"   // the OTLP/HTTP JSON export request
+	ExportCSV    = "csv"    // one row per span
+)
+
+var exportCSVHeader = []string{
+	"traceID", "spanID", "parentSpanID", "serviceName", "operationName", "startTime", "duration",
+	"spanKind", "error", "tags", "processTags", "logs",
+}
+
+// TraceToOTLP maps the converted trace to an OTLP export request, see ToOTLP.
+func TraceToOTLP(trace *ui.Trace) otlp.Request {
+	spans := make([]*model.Span, 0, len(trace.Spans))
+	for i := range trace.Spans {
+		if span, ok := uiSpanToDomain(trace, &trace.Spans[i]); ok {
+			spans = append(spans, span)
+		}
+	}
+	return ToOTLP(spans)
+}
+
+// WriteTraceCSV writes the spans of trace as CSV rows in start time order: startTime in unix microseconds,
+// duration in microseconds, the tags and process tags as JSON objects and logs as the count of the span logs.
+func WriteTraceCSV(w io.Writer, trace *ui.Trace) error {
+	spans := make([]*ui.Span, 0, len(trace.Spans))
+	for i := range trace.Spans {
+		spans = append(spans, &trace.Spans[i])
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].StartTime < spans[j].StartTime })
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportCSVHeader); err != nil {
+		return err
+	}
+	for _, span := range spans {
+		process := uiSpanProcess(trace, span)
+		kind := ""
+		for _, kv := range span.Tags {
+			if kv.Key == "span.kind" {
+				kind = cast.ToString(kv.Value)
+			}
+		}
+		row := []string{
+			string(span.TraceID), string(span.SpanID), string(uiParentSpanID(span)), process.ServiceName,
+			span.OperationName, strconv.FormatUint(span.StartTime, 10), strconv.FormatUint(span.Duration, 10),
+			kind, strconv.FormatBool(uiSpanHasError(span)), csvTags(span.Tags), csvTags(process.Tags),
+			strconv.Itoa(len(span.Logs)),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvTags renders the tags as a JSON object, the last of repeated keys wins.
+func csvTags(kvs []ui.KeyValue) string {
+	tags := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		tags[kv.Key] = kv.Value
+	}
+	b, _ := json.Marshal(tags)
+	return string(b)
+}
+
+func uiSpanProcess(trace *ui.Trace, span *ui.Span) ui.Process {
+	if span.Process != nil {
+		return *span.Process
+	}
+	return trace.Processes[span.ProcessID]
+}
+
+// uiSpanToDomain is the reverse of the FromDomain conversion of the span, ok is false for malformed ids.
+func uiSpanToDomain(trace *ui.Trace, span *ui.Span) (*model.Span, bool) {
+	traceID, err := model.TraceIDFromString(string(span.TraceID))
+	if err != nil {
+		return nil, false
+	}
+	spanID, err := model.SpanIDFromString(string(span.SpanID))
+	if err != nil {
+		return nil, false
+	}
+
+	process := uiSpanProcess(trace, span)
+	res := &model.Span{
+		TraceID:       traceID,
+		SpanID:        spanID,
+		OperationName: span.OperationName,
+		Flags:         model.Flags(span.Flags),
+		StartTime:     model.EpochMicrosecondsAsTime(span.StartTime),
+		Duration:      model.MicrosecondsAsDuration(span.Duration),
+		Tags:          uiKeyValuesToDomain(span.Tags),
+		Process:       model.NewProcess(process.ServiceName, uiKeyValuesToDomain(process.Tags)),
+		Warnings:      span.Warnings,
+	}
+	for _, ref := range span.References {
+		refTraceID, err := model.TraceIDFromString(string(ref.TraceID))
+		if err != nil {
+			continue
+		}
+		refSpanID, err := model.SpanIDFromString(string(ref.SpanID))
+		if err != nil {
+			continue
+		}
+		refType := model.ChildOf
+		if ref.RefType == ui.FollowsFrom {
+			refType = model.FollowsFrom
+		}
+		res.References = append(res.References, model.SpanRef{TraceID: refTraceID, SpanID: refSpanID, RefType: refType})
+	}
+	for _, l := range span.Logs {
+		res.Logs = append(res.Logs, model.Log{
+			Timestamp: model.EpochMicrosecondsAsTime(l.Timestamp),
+			Fields:    uiKeyValuesToDomain(l.Fields),
+		})
+	}
+	return res, true
+}
+
+func uiKeyValuesToDomain(kvs []ui.KeyValue) model.KeyValues {
+	res := make(model.KeyValues, 0, len(kvs))
+	for _, kv := range kvs {
+		switch kv.Type {
+		case ui.BoolType:
+			res = append(res, model.Bool(kv.Key, cast.ToBool(kv.Value)))
+		case ui.Int64Type:
+			res = append(res, model.Int64(kv.Key, cast.ToInt64(kv.Value)))
+		case ui.Float64Type:
+			res = append(res, model.Float64(kv.Key, cast.ToFloat64(kv.Value)))
+		case ui.BinaryType:
+			b, ok := kv.Value.([]byte)
+			if !ok {
+				// decoded from JSON, e.g. a cached trace
+				b, _ = base64.StdEncoding.DecodeString(cast.ToString(kv.Value))
+			}
+			res = append(res, model.Binary(kv.Key, b))
+		default:
+			res = append(res, model.String(kv.Key, cast.ToString(kv.Value)))
+		}
+	}
+	return res
+}
@@ -0,0 +1,66 @@
+package jaeger_service
+
+import (
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/openobserve_service"
+)
+
+// fieldColumn returns the column of the default stream holding the OOSpanFixedKey field, see openobserve.field_mapping.
+func fieldColumn(field string) string {
+	if column := config.Get().OpenObserve.FieldMapping[field]; column != "" {
+		return column
+	}
+	return field
+}
+
+// fieldColumns returns the column func of the stream, the field mapping only applies to the default stream,
+// the other streams (trace_list_index, distinct_values) are written by OpenObserve with its own names.
+func fieldColumns(stream string) func(string) string {
+	if stream == openobserve_service.SearchTraceDefaultStream {
+		return fieldColumn
+	}
+	return func(field string) string {
+		return field
+	}
+}
+
+// mapSpanHits renames the mapped columns of the span hits of the default stream to their OOSpanFixedKey fields,
+// before they are deduplicated and converted by transOOSpanToDbModelSpan.
+func mapSpanHits(hits []map[string]interface{}) []map[string]interface{} {
+	if len(config.Get().OpenObserve.FieldMapping) == 0 {
+		return hits
+	}
+	for i, hit := range hits {
+		hits[i] = mapOOSpanFields(hit)
+	}
+	return hits
+}
+
+// mapOOSpanFields returns a copy of the span hit with the mapped columns renamed to their OOSpanFixedKey fields,
+// the hit itself without a field mapping. A mapped column wins over a column with the field name.
+func mapOOSpanFields(oo map[string]interface{}) map[string]interface{} {
+	mapping := config.Get().OpenObserve.FieldMapping
+	if len(mapping) == 0 || oo == nil {
+		return oo
+	}
+
+	fields := make(map[string]string, len(mapping)) // column -> field
+	for field, column := range mapping {
+		if column != "" && column != field {
+			fields[column] = field
+		}
+	}
+
+	mapped := make(map[string]interface{}, len(oo))
+	for k, v := range oo {
+		if _, ok := fields[k]; !ok {
+			mapped[k] = v
+		}
+	}
+	for column, field := range fields {
+		if v, ok := oo[column]; ok {
+			mapped[field] = v
+		}
+	}
+	return mapped
+}
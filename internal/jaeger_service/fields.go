@@ -0,0 +1,115 @@
+package jaeger_service
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"sort"
+	"strings"
+)
+
+// The span parts the fields and exclude parameters select, the ids, names and timings are always returned.
+const (
+	FieldLogs        = "logs"
+	FieldTags        = "tags"
+	FieldProcessTags = "processTags"
+	FieldReferences  = "references"
+	FieldWarnings    = "warnings"
+)
+
+// TraceFieldsKey is the gin context key of the TraceFields of the request.
+const TraceFieldsKey = "trace_fields"
+
+var traceFieldNames = []string{FieldLogs, FieldTags, FieldProcessTags, FieldReferences, FieldWarnings}
+
+// TraceFields are the span parts dropped from the converted traces, sorted; empty keeps them all.
+type TraceFields []string
+
+// ParseTraceFields parses the comma separated fields to keep and the parts to exclude, either may be empty.
+// With fields the parts it doesn't list are dropped, exclude drops its parts in any case.
+func ParseTraceFields(fields, exclude string) (TraceFields, error) {
+	dropped := make(map[string]bool)
+	parse := func(list string, drop bool) error {
+		listed := make(map[string]bool)
+		for _, name := range strings.Split(list, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if !containsString(traceFieldNames, name) {
+				return fmt.Errorf("unknown trace field %q, expecting %s", name, strings.Join(traceFieldNames, ", "))
+			}
+			listed[name] = true
+		}
+		for _, name := range traceFieldNames {
+			if listed[name] == drop {
+				dropped[name] = true
+			}
+		}
+		return nil
+	}
+
+	if fields != "" {
+		if err := parse(fields, false); err != nil {
+			return nil, err
+		}
+	}
+	if exclude != "" {
+		if err := parse(exclude, true); err != nil {
+			return nil, err
+		}
+	}
+
+	res := make(TraceFields, 0, len(dropped))
+	for name := range dropped {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+func traceFieldsFromContext(ctx *gin.Context) TraceFields {
+	if ctx == nil {
+		return nil
+	}
+	f, _ := ctx.Value(TraceFieldsKey).(TraceFields)
+	return f
+}
+
+// key is the cache key part of the fields.
+func (f TraceFields) key() string {
+	return strings.Join(f, ",")
+}
+
+// apply empties the dropped parts of the spans and processes of trace.
+func (f TraceFields) apply(trace *ui.Trace) {
+	if len(f) == 0 || trace == nil {
+		return
+	}
+	for _, name := range f {
+		switch name {
+		case FieldProcessTags:
+			for id, p := range trace.Processes {
+				p.Tags = []ui.KeyValue{}
+				trace.Processes[id] = p
+			}
+		case FieldWarnings:
+			trace.Warnings = nil
+		}
+	}
+	for i := range trace.Spans {
+		span := &trace.Spans[i]
+		for _, name := range f {
+			switch name {
+			case FieldLogs:
+				span.Logs = []ui.Log{}
+			case FieldTags:
+				span.Tags = []ui.KeyValue{}
+			case FieldReferences:
+				span.References = []ui.Reference{}
+			case FieldWarnings:
+				span.Warnings = nil
+			}
+		}
+	}
+}
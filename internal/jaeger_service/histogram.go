@@ -0,0 +1,80 @@
+package jaeger_service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"openobserve-jaeger/internal/openobserve_service"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DurationBucket is a bucket of the /api/traces/histogram response, durations in microseconds
+type DurationBucket struct {
+	Start int64  `json:"start"` // inclusive
+	End   int64  `json:"end"`   // exclusive
+	Count uint64 `json:"count"`
+}
+
+// durationBucketSQL puts a duration of 0 in bucket 0 and [2^(b-1), 2^b) microseconds in bucket b.
+const durationBucketSQL = "CASE WHEN %[1]s < 1 THEN 0 ELSE CAST(FLOOR(LOG2(%[1]s)) AS BIGINT) + 1 END"
+
+// GetDurationHistogram counts the span durations of the service (and operation if not empty) started in
+// [start, end] in power of two buckets, aggregated by OpenObserve instead of from a limited search result.
+func (s *JaegerService) GetDurationHistogram(ctx *gin.Context, service, operation string, start, end time.Time) JaegerStructuredResponse {
+	jaegerResp := JaegerStructuredResponse{
+		Data:   make([]DurationBucket, 0),
+		Errors: make([]JaegerStructuredError, 0),
+	}
+
+	cond := []string{fieldColumn(OOSpanFixedKey.ServiceName) + " = " + sqlString(service)}
+	if operation != "" {
+		cond = append(cond, fieldColumn(OOSpanFixedKey.OperationName)+" = "+sqlString(operation))
+	}
+	sql := fmt.Sprintf("SELECT %s AS bucket, COUNT(*) AS count FROM default WHERE %s GROUP BY bucket ORDER BY bucket",
+		fmt.Sprintf(durationBucketSQL, durationMicrosSQL(ctx, fieldColumn)), strings.Join(cond, " AND "))
+
+	qq := openobserve_service.OOSearchQuery{
+		Stream: openobserve_service.SearchTraceDefaultStream,
+		Query: openobserve_service.OOSearchQueryQuery{
+			StartTime: start.UnixMicro(),
+			EndTime:   end.UnixMicro(),
+			Sql:       base64.StdEncoding.EncodeToString([]byte(sql)),
+			Size:      -1,
+		},
+	}
+
+	ooresp, err := s.ooservice.SearchTraces(ctx, qq)
+	if err != nil {
+		jaegerResp.Errors = append(jaegerResp.Errors, structuredError(err))
+
+		return jaegerResp
+	}
+
+	// a bucket is found once per cluster of openobserve.clusters
+	counts := make(map[int64]uint64, len(ooresp.Hits))
+	for _, hit := range ooresp.Hits {
+		counts[cast.ToInt64(hit["bucket"])] += cast.ToUint64(hit["count"])
+	}
+
+	buckets := make([]DurationBucket, 0, len(counts))
+	var total uint64
+	for bucket, count := range counts {
+		b := DurationBucket{Count: count}
+		if bucket > 0 {
+			b.Start = int64(1) << (bucket - 1)
+		}
+		b.End = int64(1) << bucket
+		buckets = append(buckets, b)
+		total += b.Count
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Start < buckets[j].Start
+	})
+
+	jaegerResp.Data = buckets
+	jaegerResp.Total = int(total)
+	return jaegerResp
+}
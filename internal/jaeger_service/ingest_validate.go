@@ -0,0 +1,187 @@
+package jaeger_service
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jaegertracing/jaeger/model"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"strings"
+)
+
+// IngestValidation is the dry-run conversion of one submitted span to an OpenObserve record.
+type IngestValidation struct {
+	TraceID ui.TraceID             `json:"traceID"`
+	SpanID  ui.SpanID              `json:"spanID"`
+	Record  map[string]interface{} `json:"record"`
+	Issues  []string               `json:"issues"`
+}
+
+// ValidateIngest converts the spans of Jaeger JSON traces to the OpenObserve schema read by this
+// proxy and reports the field mapping issues, nothing is written.
+func (s *JaegerService) ValidateIngest(traces []*ui.Trace) JaegerStructuredResponse {
+	results := make([]IngestValidation, 0)
+	for _, trace := range traces {
+		if trace == nil {
+			continue
+		}
+		for i := range trace.Spans {
+			span := &trace.Spans[i]
+			process := span.Process
+			if p, ok := trace.Processes[span.ProcessID]; ok {
+				process = &p
+			}
+			record, issues := ToOORecord(span, process)
+			results = append(results, IngestValidation{
+				TraceID: span.TraceID,
+				SpanID:  span.SpanID,
+				Record:  record,
+				Issues:  issues,
+			})
+		}
+	}
+
+	return JaegerStructuredResponse{
+		Data:   results,
+		Total:  len(results),
+		Errors: make([]JaegerStructuredError, 0),
+	}
+}
+
+// ToOORecord maps a Jaeger span to the OpenObserve record layout of OOSpanFixedKey,
+// tags and process tags become columns with dots replaced by underscores like OpenObserve does.
+func ToOORecord(span *ui.Span, process *ui.Process) (map[string]interface{}, []string) {
+	record := make(map[string]interface{})
+	issues := make([]string, 0)
+
+	if _, err := model.TraceIDFromString(string(span.TraceID)); err != nil {
+		issues = append(issues, fmt.Sprintf("traceID %q is not a hex trace id: %v", span.TraceID, err))
+	}
+	if _, err := model.SpanIDFromString(string(span.SpanID)); err != nil {
+		issues = append(issues, fmt.Sprintf("spanID %q is not a hex span id: %v", span.SpanID, err))
+	}
+	if span.OperationName == "" {
+		issues = append(issues, "operationName is empty")
+	}
+	if span.StartTime == 0 {
+		issues = append(issues, "startTime is 0")
+	}
+	if span.Duration == 0 {
+		issues = append(issues, "duration is 0")
+	}
+
+	record[OOSpanFixedKey.TraceID] = string(span.TraceID)
+	record[OOSpanFixedKey.SpanID] = string(span.SpanID)
+	record[OOSpanFixedKey.OperationName] = span.OperationName
+	record[OOSpanFixedKey.StartTime] = span.StartTime * 1e3
+	record[OOSpanFixedKey.EndTime] = (span.StartTime + span.Duration) * 1e3
+	record[OOSpanFixedKey.Duration] = span.Duration
+	record[OOSpanFixedKey.Flags] = span.Flags
+	record[OOSpanFixedKey.SpanStatus] = "UNSET"
+
+	if process == nil || process.ServiceName == "" {
+		issues = append(issues, "process serviceName is missing")
+	} else {
+		record[OOSpanFixedKey.ServiceName] = process.ServiceName
+	}
+
+	refs := 0
+	links := make([]map[string]string, 0)
+	for _, ref := range span.References {
+		refs++
+		if refs > 1 {
+			links = append(links, map[string]string{
+				"trace_id": string(ref.TraceID),
+				"span_id":  string(ref.SpanID),
+				"ref_type": string(ref.RefType),
+			})
+			continue
+		}
+		record[OOSpanFixedKey.ReferenceParentSpanId] = string(ref.SpanID)
+		record[OOSpanFixedKey.ReferenceParentTraceId] = string(ref.TraceID)
+		record[OOSpanFixedKey.ReferenceRefType] = strings.ReplaceAll(string(ref.RefType), "_", "")
+	}
+	if len(links) > 0 {
+		b, _ := json.Marshal(links)
+		record[OOSpanFixedKey.Links] = string(b)
+	}
+	if refs == 0 && span.ParentSpanID != "" {
+		record[OOSpanFixedKey.ReferenceParentSpanId] = string(span.ParentSpanID)
+		record[OOSpanFixedKey.ReferenceParentTraceId] = string(span.TraceID)
+	}
+
+	columns := func(kvs []ui.KeyValue, source string) {
+		for _, kv := range kvs {
+			switch kv.Key {
+			case "span.kind":
+				kind, ok := SpanKindValue(fmt.Sprint(kv.Value))
+				if !ok {
+					issues = append(issues, fmt.Sprintf("%s span.kind %q is not a known span kind", source, kv.Value))
+					continue
+				}
+				record[OOSpanFixedKey.SpanKind] = kind
+				continue
+			case "otel.status_code":
+				record[OOSpanFixedKey.SpanStatus] = strings.ToUpper(fmt.Sprint(kv.Value))
+				continue
+			case "error":
+				if fmt.Sprint(kv.Value) == "true" {
+					record[OOSpanFixedKey.SpanStatus] = "ERROR"
+				}
+				continue
+			case TraceStateTag:
+				record[OOSpanFixedKey.TraceState] = fmt.Sprint(kv.Value)
+				continue
+			}
+
+			column := strings.ReplaceAll(kv.Key, ".", "_")
+			if isFixedKey(column) {
+				issues = append(issues, fmt.Sprintf("%s %q collides with the %s column and is dropped", source, kv.Key, column))
+				continue
+			}
+			if _, ok := record[column]; ok {
+				issues = append(issues, fmt.Sprintf("%s %q collides with another attribute on column %s", source, kv.Key, column))
+			}
+			if kv.Value == nil {
+				issues = append(issues, fmt.Sprintf("%s %q has a null value, the span would fail to convert on read", source, kv.Key))
+			}
+			record[column] = kv.Value
+		}
+	}
+	if process != nil {
+		columns(process.Tags, "process tag")
+	}
+	columns(span.Tags, "tag")
+
+	if len(span.Logs) > 0 {
+		events := make([]map[string]interface{}, 0, len(span.Logs))
+		for _, l := range span.Logs {
+			event := map[string]interface{}{
+				OOSpanFixedKey.Timestamp: l.Timestamp * 1e3,
+			}
+			for _, f := range l.Fields {
+				event[f.Key] = f.Value
+			}
+			events = append(events, event)
+		}
+		data, err := json.Marshal(events)
+		if err != nil {
+			issues = append(issues, fmt.Sprintf("logs cannot be encoded as events: %v", err))
+		} else {
+			record[OOSpanFixedKey.Events] = string(data)
+		}
+	}
+
+	return record, issues
+}
+
+func isFixedKey(column string) bool {
+	switch column {
+	case OOSpanFixedKey.ServiceName, OOSpanFixedKey.StartTime, OOSpanFixedKey.EndTime, OOSpanFixedKey.Timestamp,
+		OOSpanFixedKey.TraceID, OOSpanFixedKey.SpanID, OOSpanFixedKey.Duration, OOSpanFixedKey.Flags,
+		OOSpanFixedKey.OperationName, OOSpanFixedKey.SpanKind, OOSpanFixedKey.SpanStatus,
+		OOSpanFixedKey.ReferenceParentSpanId, OOSpanFixedKey.ReferenceParentTraceId, OOSpanFixedKey.ReferenceRefType,
+		OOSpanFixedKey.Links, OOSpanFixedKey.Events, OOSpanFixedKey.TraceState:
+		return true
+	}
+	return false
+}
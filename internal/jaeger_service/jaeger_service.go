@@ -2,7 +2,6 @@ package jaeger_service
 
 import (
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-resty/resty/v2"
@@ -19,7 +18,10 @@ import (
 	"openobserve-jaeger/internal/config"
 	"openobserve-jaeger/internal/errors"
 	"openobserve-jaeger/internal/openobserve_service"
+	"openobserve-jaeger/internal/requestid"
+	"openobserve-jaeger/internal/timing"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -27,17 +29,24 @@ import (
 
 // TraceQueryParameters contains parameters of a trace query.
 type TraceQueryParameters struct {
+	TraceIDs      []string
 	ServiceName   []string
+	DownstreamOf  []string // expanded to these services and the services they call via the dependency graph
 	OperationName []string
 	Tags          map[string]string
+	TagGroups     [][]TagTerm // a group matches when one of its terms does, the groups and Tags must all match
 	StartTimeMin  time.Time
 	StartTimeMax  time.Time
 	DurationMin   time.Duration
 	DurationMax   time.Duration
 	NumTraces     int
-	Version       string
+	Strategy      string // name of the search strategy, empty means search_strategy, see searchStrategy
 	SkipWal       bool
 	SearchType    string
+	Strict        bool           // all-or-nothing, a failed backend query fails the request instead of a partial result
+	SampleRatio   float64        // keep about this ratio of the matched traces, 0 means openobserve.search_sample_ratio
+	Location      *time.Location // tz of the human-readable timestamps, nil means none are rendered
+	DefaultLimit  bool           // NumTraces is the default, the search set no limit
 }
 
 type DbmodelSpanFixedKey struct {
@@ -56,7 +65,9 @@ type DbmodelSpanFixedKey struct {
 	ReferenceParentSpanId  string
 	ReferenceParentTraceId string
 	ReferenceRefType       string
+	Links                  string
 	Events                 string
+	TraceState             string
 }
 
 var (
@@ -76,10 +87,12 @@ var (
 		ReferenceParentSpanId:  "reference_parent_span_id",
 		ReferenceParentTraceId: "reference_parent_trace_id",
 		ReferenceRefType:       "reference_ref_type",
+		Links:                  "links",
 		Events:                 "events",
+		TraceState:             "trace_state",
 	}
 
-	// 所有不是ProcessTags的都转换为Tags
+	// 所有不是ProcessTags的都转换为Tags, openobserve.process_tags_pattern overrides it
 	DbModelProcessTagsRulesReg = regexp.MustCompile("" +
 		"")
 	ServiceCacheKey                  = "jaegerServiceName"
@@ -92,29 +105,102 @@ type JaegerService struct {
 	adjuster   adjuster.Adjuster
 	once       sync.Once
 	httpclient *resty.Client
+	cache      *searchCache
+	schema     *schemaCache
+	metadata   *metadataCache // nil unless the warm-up worker runs
 }
 
 type JaegerStructuredResponse struct {
-	Data   interface{}             `json:"data"`
-	Total  int                     `json:"total"`
-	Limit  int                     `json:"limit"`
-	Offset int                     `json:"offset"`
-	Errors []JaegerStructuredError `json:"errors"`
+	Data      interface{}              `json:"data"`
+	Total     int                      `json:"total"`
+	Limit     int                      `json:"limit"`
+	Offset    int                      `json:"offset"`
+	Errors    []JaegerStructuredError  `json:"errors"`
+	Summaries map[string]*TraceSummary `json:"summaries,omitempty"`
+	HasMore   bool                     `json:"hasMore,omitempty"`  // more traces than the limit matched the search
+	Timings   map[string]float64       `json:"timings,omitempty"`  // debug only, phase -> milliseconds
+	Backends  []string                 `json:"backends,omitempty"` // debug only, the openobserve base urls queried
+	// SampleRatio is set when the traces are a sample of the Total matched ones
+	SampleRatio float64 `json:"sampleRatio,omitempty"`
+	// Size is the span count and estimated size of the traces, set on the trace detail responses
+	Size *TraceSize `json:"size,omitempty"`
+	// Job is the background search of the /api/search-jobs responses
+	Job *SearchJob `json:"job,omitempty"`
+	// Warnings are the corrections made to the request, e.g. a time range clamped to now
+	Warnings []string `json:"warnings,omitempty"`
 }
 
+// TraceSummary is the trace level info from trace_list_index, keyed by trace id in JaegerStructuredResponse.
+type TraceSummary struct {
+	Services  []string `json:"services"`
+	StartTime int64    `json:"startTime"` // unix microseconds
+	Duration  int64    `json:"duration"`  // microseconds
+	// StartTimeText is StartTime rendered in the tz of the request, only set if tz is given
+	StartTimeText string `json:"startTimeText,omitempty"`
+}
+
+// displayTimeLayout renders the human-readable timestamps of the tz aware responses.
+const displayTimeLayout = "2006-01-02 15:04:05.000 MST"
+
+// FormatTimestamp renders unix microseconds in loc, the raw epoch values stay in the responses.
+func FormatTimestamp(us int64, loc *time.Location) string {
+	return time.UnixMicro(us).In(loc).Format(displayTimeLayout)
+}
+
+// StatusCode is the HTTP status of the response: 200 without errors, else the status errors.HTTPStatus maps
+// the first error to, 206 for a partial result.
 func (j JaegerStructuredResponse) StatusCode() int {
-	code := http.StatusOK
-	if len(j.Errors) > 0 {
-		code = j.Errors[0].Code
+	if len(j.Errors) == 0 {
+		return http.StatusOK
 	}
+	e := j.Errors[0]
+	if e.err != nil {
+		return errors.HTTPStatus(e.err)
+	}
+	if e.Code == http.StatusPartialContent {
+		return e.Code
+	}
+	return errors.HTTPStatus(errors.New(int32(e.Code), e.Msg))
+}
 
-	return code
+// Partial reports whether all the errors are http.StatusPartialContent warnings, the data is usable then.
+func (j JaegerStructuredResponse) Partial() bool {
+	if len(j.Errors) == 0 {
+		return false
+	}
+	for _, e := range j.Errors {
+		if e.Code != http.StatusPartialContent {
+			return false
+		}
+	}
+	return true
 }
 
 type JaegerStructuredError struct {
 	Code    int        `json:"code,omitempty"`
 	Msg     string     `json:"msg"`
 	TraceID ui.TraceID `json:"traceID,omitempty"`
+
+	RequestID string   `json:"requestID,omitempty"`           // X-Request-ID of the request
+	Sessions  []string `json:"openobserveSessions,omitempty"` // trace_id of the OpenObserve searches of the request
+
+	err error // the error of structuredError, StatusCode maps it to the status
+}
+
+// structuredError is the response error of err, with the status errors.HTTPStatus maps it to.
+func structuredError(err error) JaegerStructuredError {
+	return JaegerStructuredError{
+		Code: errors.HTTPStatus(err),
+		Msg:  errors.FromError(err).GetMessage(),
+		err:  err,
+	}
+}
+
+// ErrorResponse is the response of a request failed with err, e.g. for invalid parameters.
+func ErrorResponse(err error) JaegerStructuredResponse {
+	return JaegerStructuredResponse{
+		Errors: []JaegerStructuredError{structuredError(err)},
+	}
 }
 
 const (
@@ -127,6 +213,8 @@ func NewJaegerService() *JaegerService {
 		ooservice:  openobserve_service.NewOpenObserveService(),
 		adjuster:   adjuster.Sequence(StandardAdjusters(time.Second)...),
 		httpclient: resty.New(),
+		cache:      newSearchCache(),
+		schema:     &schemaCache{},
 	}
 }
 
@@ -168,8 +256,13 @@ func (s *JaegerService) ooFieldValueApiToJaegerRespData(data *openobserve_servic
 	}
 
 	if len(data.Hits) > 0 {
+		seen := make(map[string]struct{}, len(data.Hits))
 		for _, hit := range data.Hits {
 			if v, ok := hit[key]; ok {
+				if _, dup := seen[cast.ToString(v)]; dup {
+					continue
+				}
+				seen[cast.ToString(v)] = struct{}{}
 				res = append(res, v)
 			}
 		}
@@ -187,19 +280,14 @@ func (s *JaegerService) getService(ctx *gin.Context, q *openobserve_service.OOQu
 		Errors: make([]JaegerStructuredError, 0),
 	}
 
+	if services, ok := s.metadata.getServices(); ok {
+		jaegerResp.Data, jaegerResp.Total = services, len(services)
+		return jaegerResp
+	}
+
 	ooresp, err := s.ooservice.GetService(ctx)
 	if err != nil {
-		if e, ok := err.(*errors.Error); ok {
-			jaegerResp.Errors = append(jaegerResp.Errors, JaegerStructuredError{
-				Code: int(e.GetCode()),
-				Msg:  e.GetMessage(),
-			})
-		} else {
-			jaegerResp.Errors = append(jaegerResp.Errors, JaegerStructuredError{
-				Code: int(500),
-				Msg:  err.Error(),
-			})
-		}
+		jaegerResp.Errors = append(jaegerResp.Errors, structuredError(err))
 
 		return jaegerResp
 	}
@@ -213,19 +301,14 @@ func (s *JaegerService) GetOperations(ctx *gin.Context, q *openobserve_service.O
 		Errors: make([]JaegerStructuredError, 0),
 	}
 
+	if operations, ok := s.metadata.getOperations(q.ServiceName); ok {
+		jaegerResp.Data, jaegerResp.Total = operations, len(operations)
+		return jaegerResp
+	}
+
 	ooresp, err := s.ooservice.GetServiceOperation(ctx, q.ServiceName, q.SearchType)
 	if err != nil {
-		if e, ok := err.(*errors.Error); ok {
-			jaegerResp.Errors = append(jaegerResp.Errors, JaegerStructuredError{
-				Code: int(e.GetCode()),
-				Msg:  e.GetMessage(),
-			})
-		} else {
-			jaegerResp.Errors = append(jaegerResp.Errors, JaegerStructuredError{
-				Code: int(500),
-				Msg:  err.Error(),
-			})
-		}
+		jaegerResp.Errors = append(jaegerResp.Errors, structuredError(err))
 
 		return jaegerResp
 	}
@@ -234,74 +317,271 @@ func (s *JaegerService) GetOperations(ctx *gin.Context, q *openobserve_service.O
 	return jaegerResp
 }
 
+// Operation is an operation of the /api/operations response
+type Operation struct {
+	Name     string `json:"name"`
+	SpanKind string `json:"spanKind"`
+}
+
+// GetOperationsWithSpanKind returns the {name, spanKind} operations of q.ServiceName, filtered by spanKind if not empty.
+func (s *JaegerService) GetOperationsWithSpanKind(ctx *gin.Context, q *openobserve_service.OOQuery, spanKind string) JaegerStructuredResponse {
+	jaegerResp := JaegerStructuredResponse{
+		Data:   make([]Operation, 0),
+		Errors: make([]JaegerStructuredError, 0),
+	}
+
+	kind := -1
+	if spanKind != "" {
+		v, ok := SpanKindValue(spanKind)
+		if !ok {
+			jaegerResp.Errors = append(jaegerResp.Errors, structuredError(errors.BadRequest(fmt.Sprintf("unknown spanKind: %s", spanKind))))
+			return jaegerResp
+		}
+		kind = v
+	}
+
+	start, end := detailTimeRange(q)
+
+	ooresp, err := s.ooservice.GetServiceOperationSpanKind(ctx, fieldColumn, q.ServiceName, kind, start, end, q.SearchType)
+	if err != nil {
+		jaegerResp.Errors = append(jaegerResp.Errors, structuredError(err))
+
+		return jaegerResp
+	}
+
+	operations := make([]Operation, 0, len(ooresp.Hits))
+	seen := make(map[Operation]struct{}, len(ooresp.Hits))
+	for _, hit := range ooresp.Hits {
+		op := Operation{
+			Name:     cast.ToString(hit[OOSpanFixedKey.OperationName]),
+			SpanKind: SpanKindName(cast.ToInt(hit[OOSpanFixedKey.SpanKind])),
+		}
+		if _, ok := seen[op]; ok {
+			continue
+		}
+		seen[op] = struct{}{}
+		operations = append(operations, op)
+	}
+
+	jaegerResp.Data = operations
+	jaegerResp.Total = len(operations)
+	return jaegerResp
+}
+
+// FindTraces searches the traces, the responses without errors are cached for a short TTL if enabled.
 func (s *JaegerService) FindTraces(ctx *gin.Context, q *TraceQueryParameters) JaegerStructuredResponse {
+	q, warnings := applyServiceOverride(ctx, q)
+	resp := s.findTracesCached(ctx, q)
+	if len(warnings) > 0 {
+		resp.Warnings = append(append([]string(nil), resp.Warnings...), warnings...)
+	}
+	return resp
+}
+
+func (s *JaegerService) findTracesCached(ctx *gin.Context, q *TraceQueryParameters) JaegerStructuredResponse {
+	ttl, maxEntries, enabled := cacheConfig()
+	if !enabled {
+		return s.findTraces(ctx, q)
+	}
+
+	// the traces are cached as slimmed by the fields of the request
+	key := searchCacheKey(q, ttl) + "|exclude=" + traceFieldsFromContext(ctx).key()
+	if resp, ok := s.cache.get(key); ok {
+		return resp
+	}
+
+	resp := s.findTraces(ctx, q)
+	if len(resp.Errors) == 0 {
+		s.cache.set(key, resp, ttl, maxEntries)
+	}
+	return resp
+}
+
+// FlushCache drops all the cached search responses.
+func (s *JaegerService) FlushCache() {
+	s.cache.Flush()
+}
+
+func (s *JaegerService) findTraces(ctx *gin.Context, q *TraceQueryParameters) JaegerStructuredResponse {
 	jaegerResp := JaegerStructuredResponse{
 		Data:   make([]string, 0),
 		Errors: make([]JaegerStructuredError, 0),
 	}
 
 	// uiErrors := make([]JaegerStructuredError, 0)
-	traceIds, structErrors := s.findTracesIds(ctx, q)
-	if len(structErrors) > 0 {
-		if structErrors[0].Code == 404 {
+	var structErrors []JaegerStructuredError
+	// traces asked by id skip the trace list search
+	traceIds := q.TraceIDs
+	found := 0
+	var items []traceListItem
+	var postFilters []tagFilter
+	if len(traceIds) == 0 && len(q.DownstreamOf) > 0 {
+		expanded, jaegerErr := s.expandDownstream(ctx, q)
+		if jaegerErr != nil {
+			jaegerResp.Errors = append(jaegerResp.Errors, *jaegerErr)
 			return jaegerResp
-		} else {
-			jaegerResp.Errors = structErrors
+		}
+		q = expanded
+	}
+	if len(traceIds) == 0 {
+		searchQ, filters, filterErr := s.splitPostFilterTags(ctx, q)
+		if filterErr != nil {
+			jaegerResp.Errors = append(jaegerResp.Errors, *filterErr)
 			return jaegerResp
 		}
+		postFilters = filters
+		// the post filtered searches keep all the candidates until the spans are matched
+		keep := q.NumTraces
+		if len(postFilters) > 0 {
+			keep = searchQ.NumTraces
+		}
+
+		ratio := searchSampleRatio(q)
+		if ratio > 0 {
+			wide := *searchQ
+			wide.NumTraces = sampledSearchLimit(searchQ.NumTraces, ratio)
+			searchQ = &wide
+		}
+
+		items, structErrors = s.findTracesIds(ctx, searchQ)
+		if len(structErrors) > 0 {
+			if structErrors[0].Code == 404 {
+				return jaegerResp
+			} else {
+				jaegerResp.Errors = structErrors
+				// the traces of the sub-range queries which succeeded are still returned
+				if !jaegerResp.Partial() {
+					return jaegerResp
+				}
+			}
+
+		}
+
+		if config.Get().OpenObserve.ConfirmTruncatedSearch {
+			jaegerResp.HasMore = s.hasMoreTraces(ctx, searchQ, items)
+		}
+
+		found = len(items)
+		if ratio > 0 {
+			items = sampleTraces(items, ratio, keep)
+			jaegerResp.SampleRatio = ratio
+		}
 
+		traceIds = make([]string, 0, len(items))
+		for _, item := range items {
+			traceIds = append(traceIds, item.TraceID)
+		}
+		if len(traceIds) == 0 {
+			return jaegerResp
+		}
+		if len(postFilters) > 0 && len(items) >= searchQ.NumTraces {
+			jaegerResp.Errors = append(jaegerResp.Errors, JaegerStructuredError{
+				Code: http.StatusPartialContent,
+				Msg:  fmt.Sprintf("only the latest %d traces were filtered by the tags which are not columns, older traces may match too", len(items)),
+			})
+		}
 	}
 
 	// todo: search all the time for the whole traceid
 	// use default_queryui_max_search_range_time for performence temporary
 	// rangeTime, _ := config.Get("openobserve.default_queryui_max_search_range_time").Int()
-	spanSize := config.Cfg.OpenObserve.DefaultSpanSize
+	spanSize := config.Get().OpenObserve.DefaultSpanSize
 	qq := &TraceQueryParameters{
-		StartTimeMin: q.StartTimeMin,
+		StartTimeMin: spanFetchStart(q.StartTimeMin, items),
 		StartTimeMax: q.StartTimeMax,
 		NumTraces:    int(spanSize),
-		SearchType:   openobserve_service.UiSearchType,
 	}
 
-	uiTraces := make([]*ui.Trace, int(spanSize))
-	uiTraces, structErrors = s.findTracesByIds(ctx, qq, traceIds)
+	jaegerResp.Summaries = s.findTraceSummaries(ctx, qq, traceIds)
+	if q.Location != nil {
+		for _, summary := range jaegerResp.Summaries {
+			summary.StartTimeText = FormatTimestamp(summary.StartTime, q.Location)
+		}
+	}
+
+	uiTraces, structErrors := s.findTracesByIds(ctx, qq, traceIds)
 
 	if len(structErrors) > 0 {
 		if structErrors[0].Code == 404 {
 			return jaegerResp
-		} else {
-			jaegerResp.Errors = structErrors
+		}
+		jaegerResp.Errors = append(jaegerResp.Errors, structErrors...)
+		// the traces with skipped spans are still returned
+		if !jaegerResp.Partial() {
 			return jaegerResp
 		}
 	}
 
+	if len(postFilters) > 0 {
+		uiTraces = postFilterTraces(uiTraces, postFilters, q.NumTraces)
+		kept := make(map[string]*TraceSummary, len(uiTraces))
+		for _, trace := range uiTraces {
+			if summary, ok := jaegerResp.Summaries[string(trace.TraceID)]; ok {
+				kept[string(trace.TraceID)] = summary
+			}
+		}
+		jaegerResp.Summaries = kept
+	}
+
 	jaegerResp.Data = uiTraces
 	jaegerResp.Total = len(uiTraces)
+	if jaegerResp.SampleRatio > 0 {
+		// the number of traces the search matched, not only the sampled ones
+		jaegerResp.Total = found
+	}
 
 	return jaegerResp
 }
 
-func (s *JaegerService) findTracesIds(ctx *gin.Context, q *TraceQueryParameters) ([]string, []JaegerStructuredError) {
+// traceListItem is a trace found by the trace list search, Timestamp is the MIN(_timestamp) in unix microseconds
+type traceListItem struct {
+	TraceID   string
+	Timestamp int64
+}
+
+// findTracesIds searches the trace ids of q, split into concurrent sub-range queries if the parallelism of the
+// search strategy, or else openobserve.search_parallelism, is enabled.
+func (s *JaegerService) findTracesIds(ctx *gin.Context, q *TraceQueryParameters) ([]traceListItem, []JaegerStructuredError) {
+	parallelism := config.Get().OpenObserve.SearchParallelism
+	if p := searchStrategy(q).Parallelism; p > 0 {
+		parallelism = p
+	}
+	if parallelism <= 1 {
+		return s.searchTracesIds(ctx, q)
+	}
+	return s.searchTracesIdsParallel(ctx, q, parallelism)
+}
+
+// applySearchDefaults applies openobserve.skip_wal and openobserve.background_search to a trace search.
+func applySearchDefaults(qq *openobserve_service.OOSearchQuery) {
+	cfg := config.Get().OpenObserve
+	if cfg.SkipWal {
+		qq.Query.SkipWal = true
+	}
+	if cfg.BackgroundSearch && qq.SearchType == "" {
+		qq.SearchType = openobserve_service.BackgroundSearchType
+	}
+}
+
+func (s *JaegerService) searchTracesIds(ctx *gin.Context, q *TraceQueryParameters) ([]traceListItem, []JaegerStructuredError) {
 	sql, stream_api := s.buildSQL(ctx, "trace_id, MIN(_timestamp) AS _timestamp", q, openobserve_service.SearchTraceListStream)
-	log.Printf("findTracesIds sql: %s", sql)
+	requestid.Debugf(ctx, "findTracesIds sql: %s", sql)
 
+	stream := openobserve_service.SearchTraceListStream
+	if stream_api == TraceAPI {
+		stream = openobserve_service.SearchTraceDefaultStream
+	}
 	qq := openobserve_service.OOSearchQuery{
+		Stream: stream,
 		Query: openobserve_service.OOSearchQueryQuery{
-			SqlMode:   "full",
 			StartTime: q.StartTimeMin.UnixMicro(),
 			EndTime:   q.StartTimeMax.UnixMicro(),
 			Sql:       base64.StdEncoding.EncodeToString([]byte(sql)),
 		},
 	}
 
-	if q.Version == "v3" {
-		qq.Query.SkipWal = true
-		qq.SearchType = openobserve_service.BackgroundSearchType
-	}
-
-	if q.Version == "v4" {
-		qq.SearchType = openobserve_service.BackgroundSearchType
-	}
+	applySearchStrategy(&qq, searchStrategy(q))
+	applySearchDefaults(&qq)
 
 	var ooresp *openobserve_service.OpenObserveResp
 	var err error
@@ -312,40 +592,125 @@ func (s *JaegerService) findTracesIds(ctx *gin.Context, q *TraceQueryParameters)
 	}
 
 	if err != nil {
-		if e, ok := err.(*errors.Error); ok {
-			return nil, []JaegerStructuredError{
-				{
-					Code: int(e.GetCode()),
-					Msg:  e.GetMessage(),
-				},
-			}
-		} else {
-			return nil, []JaegerStructuredError{
-				{
-					Code: int(500),
-					Msg:  err.Error(),
-				},
-			}
-		}
+		return nil, []JaegerStructuredError{structuredError(err)}
 	}
 
 	if len(ooresp.Hits) == 0 {
-		return nil, []JaegerStructuredError{
-			{
-				Code: 404,
-				Msg:  "trace not found",
-			},
-		}
+		return nil, []JaegerStructuredError{structuredError(errors.NotFound("trace not found"))}
 	}
 
-	traceid := make([]string, 0, len(ooresp.Hits))
+	traceid := make([]traceListItem, 0, len(ooresp.Hits))
 	for _, trace := range ooresp.Hits {
 		if id, ok := trace["trace_id"]; ok {
-			traceid = append(traceid, cast.ToString(id))
+			ts := cast.ToInt64(trace[OOSpanFixedKey.Timestamp])
+			if stream_api == TraceAPI {
+				// the trace api aliases start_time in nanoseconds as _timestamp
+				ts = ts / 1e3
+			}
+			traceid = append(traceid, traceListItem{
+				TraceID:   cast.ToString(id),
+				Timestamp: ts,
+			})
+		}
+	}
+
+	return mergeTraceListItems(traceid, q.NumTraces), nil
+}
+
+// hasMoreTraces checks whether a search which returned exactly the limit was truncated,
+// by looking for one more trace older than the oldest returned trace.
+func (s *JaegerService) hasMoreTraces(ctx *gin.Context, q *TraceQueryParameters, items []traceListItem) bool {
+	if q.NumTraces <= 0 || len(items) < q.NumTraces {
+		return false
+	}
+
+	oldest := items[0].Timestamp
+	for _, item := range items {
+		if item.Timestamp < oldest {
+			oldest = item.Timestamp
+		}
+	}
+	if oldest <= q.StartTimeMin.UnixMicro() {
+		return false
+	}
+
+	found := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		found[item.TraceID] = struct{}{}
+	}
+
+	// the oldest traces may span the sub-range boundary, so ask for one more than them
+	qq := *q
+	qq.StartTimeMax = time.UnixMicro(oldest)
+	qq.NumTraces = len(items) + 1
+	more, structErrors := s.findTracesIds(ctx, &qq)
+	if len(structErrors) > 0 {
+		if structErrors[0].Code != 404 {
+			requestid.Logf(ctx, "hasMoreTraces err: %s", structErrors[0].Msg)
+		}
+		return false
+	}
+
+	for _, item := range more {
+		if _, ok := found[item.TraceID]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// findTraceSummaries fetches services and durations of all the traces in one query,
+// the summaries are optional so a failure only gets logged.
+func (s *JaegerService) findTraceSummaries(ctx *gin.Context, q *TraceQueryParameters, traceids []string) map[string]*TraceSummary {
+	if len(traceids) <= 0 {
+		return nil
+	}
+
+	ooresp, err := s.ooservice.GetTraceServiceIndex(ctx, traceids, q.StartTimeMin.UnixMicro(), q.StartTimeMax.UnixMicro())
+	if err != nil {
+		requestid.Logf(ctx, "findTraceSummaries err: %v", err)
+		return nil
+	}
+
+	summaries := make(map[string]*TraceSummary, len(traceids))
+	ends := make(map[string]int64, len(traceids))
+	for _, hit := range ooresp.Hits {
+		traceid := cast.ToString(hit[OOSpanFixedKey.TraceID])
+		if traceid == "" {
+			continue
+		}
+
+		// start_time and end_time are unix nanoseconds
+		start := cast.ToInt64(hit[OOSpanFixedKey.StartTime]) / 1e3
+		end := cast.ToInt64(hit[OOSpanFixedKey.EndTime]) / 1e3
+		summary, ok := summaries[traceid]
+		if !ok {
+			summary = &TraceSummary{
+				Services:  make([]string, 0, 1),
+				StartTime: start,
+			}
+			summaries[traceid] = summary
+		}
+
+		if service := cast.ToString(hit[OOSpanFixedKey.ServiceName]); service != "" && !containsString(summary.Services, service) {
+			summary.Services = append(summary.Services, service)
 		}
+		if start < summary.StartTime {
+			summary.StartTime = start
+		}
+		if end > ends[traceid] {
+			ends[traceid] = end
+		}
+	}
+
+	for traceid, summary := range summaries {
+		if ends[traceid] > summary.StartTime {
+			summary.Duration = ends[traceid] - summary.StartTime
+		}
+		sort.Strings(summary.Services)
 	}
 
-	return traceid, nil
+	return summaries
 }
 
 func (s *JaegerService) findTracesByIds(ctx *gin.Context, q *TraceQueryParameters, traceids []string) ([]*ui.Trace, []JaegerStructuredError) {
@@ -353,17 +718,41 @@ func (s *JaegerService) findTracesByIds(ctx *gin.Context, q *TraceQueryParameter
 		return nil, nil
 	}
 
-	traceidsql := "trace_id IN('" + strings.Join(traceids, "','") + "')"
-	sql := fmt.Sprintf("SELECT * FROM default WHERE %s ORDER BY start_time DESC", traceidsql)
-	return s.searchTracesByIds(ctx, q, sql, traceids)
+	traceIDColumn, startTimeColumn := fieldColumn(OOSpanFixedKey.TraceID), fieldColumn(OOSpanFixedKey.StartTime)
+	chunks := openobserve_service.TraceIDChunks(traceids, len(fmt.Sprintf(tracesByIdsSQL, openobserve_service.TraceIDsIn(traceIDColumn, nil), startTimeColumn)))
+	if len(chunks) == 1 {
+		return s.searchTracesByIds(ctx, q, fmt.Sprintf(tracesByIdsSQL, openobserve_service.TraceIDsIn(traceIDColumn, traceids), startTimeColumn), traceids)
+	}
+
+	// the IN() list would exceed openobserve.max_sql_length, query the ids in chunks
+	requestid.Logf(ctx, "findTracesByIds: %d trace ids split into %d queries by openobserve.max_sql_length", len(traceids), len(chunks))
+	res := make([]*ui.Trace, 0, len(traceids))
+	structErrors := make([]JaegerStructuredError, 0)
+	for _, chunk := range chunks {
+		traces, errs := s.searchTracesByIds(ctx, q, fmt.Sprintf(tracesByIdsSQL, openobserve_service.TraceIDsIn(traceIDColumn, chunk), startTimeColumn), chunk)
+		res = append(res, traces...)
+		for _, e := range errs {
+			// the traces of the chunk are missing, not all of them
+			if e.Code != http.StatusNotFound {
+				structErrors = append(structErrors, e)
+			}
+		}
+	}
+
+	if len(res) == 0 && len(structErrors) == 0 {
+		return nil, []JaegerStructuredError{structuredError(errors.NotFound("trace not found"))}
+	}
+	return res, structErrors
 }
 
+const tracesByIdsSQL = "SELECT * FROM default WHERE %s ORDER BY %s DESC"
+
 func (s *JaegerService) searchTracesByIds(ctx *gin.Context, q *TraceQueryParameters, sql string, traceids []string) ([]*ui.Trace, []JaegerStructuredError) {
-	log.Printf("findTracesByIds sql: %s", sql)
+	requestid.Debugf(ctx, "findTracesByIds sql: %s", sql)
 
 	qq := openobserve_service.OOSearchQuery{
+		Stream: openobserve_service.SearchTraceDefaultStream,
 		Query: openobserve_service.OOSearchQueryQuery{
-			SqlMode:   "full",
 			StartTime: q.StartTimeMin.UnixMicro(),
 			EndTime:   q.StartTimeMax.UnixMicro(),
 			Sql:       base64.StdEncoding.EncodeToString([]byte(sql)),
@@ -372,44 +761,19 @@ func (s *JaegerService) searchTracesByIds(ctx *gin.Context, q *TraceQueryParamet
 		},
 		SearchType: q.SearchType,
 	}
+	applySearchStrategy(&qq, searchStrategy(q))
+	applySearchDefaults(&qq)
 
 	ooresp, err := s.ooservice.SearchTraces(ctx, qq)
 	if err != nil {
-		return nil, []JaegerStructuredError{
-			{
-				Code: 500,
-				Msg:  err.Error(),
-				// TraceID: ui.TraceID(q.TraceID),
-			},
-		}
+		return nil, []JaegerStructuredError{structuredError(err)}
 	}
 
 	if len(ooresp.Hits) == 0 {
-		return nil, []JaegerStructuredError{
-			{
-				Code: 404,
-				Msg:  "trace not found",
-				// TraceID: ui.TraceID(q.TraceID),
-			},
-		}
+		return nil, []JaegerStructuredError{structuredError(errors.NotFound("trace not found"))}
 	}
 
-	// format to openobserve_service.OpenObserveResp
-	splitOOResp := make(map[string]*openobserve_service.OpenObserveResp)
-	for _, span := range ooresp.Hits {
-		traceid := cast.ToString(span["trace_id"])
-		if traceid != "" {
-			if _, ok := splitOOResp[traceid]; ok {
-				splitOOResp[traceid].Hits = append(splitOOResp[traceid].Hits, span)
-			} else {
-				splitOOResp[traceid] = &openobserve_service.OpenObserveResp{
-					Hits: []map[string]interface{}{
-						span,
-					},
-				}
-			}
-		}
-	}
+	splitOOResp := splitTraceHits(ooresp.Hits)
 
 	// build ui trace slice
 	res := make([]*ui.Trace, 0, len(traceids))
@@ -427,24 +791,46 @@ func (s *JaegerService) searchTracesByIds(ctx *gin.Context, q *TraceQueryParamet
 	return res, structErrors
 }
 
+// splitTraceHits maps and dedupes the spans of several traces, and groups them by trace id.
+func splitTraceHits(hits []map[string]interface{}) map[string]*openobserve_service.OpenObserveResp {
+	splitOOResp := make(map[string]*openobserve_service.OpenObserveResp)
+	for _, span := range dedupeSpanHits(mapSpanHits(hits)) {
+		traceid := cast.ToString(span["trace_id"])
+		if traceid != "" {
+			if _, ok := splitOOResp[traceid]; ok {
+				splitOOResp[traceid].Hits = append(splitOOResp[traceid].Hits, span)
+			} else {
+				splitOOResp[traceid] = &openobserve_service.OpenObserveResp{
+					Hits: []map[string]interface{}{
+						span,
+					},
+				}
+			}
+		}
+	}
+	return splitOOResp
+}
+
 func (s *JaegerService) buildSQL(ctx *gin.Context, fileds string, q *TraceQueryParameters, stream string) (string, string) {
+	defer timing.Track(ctx, timing.PhaseSQLBuild)()
+
 	var sql, stream_api string
-	if len(stream) == 0 || len(q.Tags) > 0 || len(q.OperationName) > 0 || q.DurationMax > 0 || q.DurationMin > 0 {
+	if len(stream) == 0 || traceStreamOverride(ctx) != "" || searchStrategy(q).Stream == config.SearchStreamSpans || len(q.Tags) > 0 || len(q.TagGroups) > 0 || len(q.OperationName) > 0 || q.DurationMax > 0 || q.DurationMin > 0 {
 		stream = openobserve_service.SearchTraceDefaultStream
-		sql = "SELECT trace_id, MIN(start_time) AS _timestamp FROM " + stream
+		sql = "SELECT " + fieldColumn(OOSpanFixedKey.TraceID) + " AS trace_id, MIN(" + fieldColumn(OOSpanFixedKey.StartTime) + ") AS _timestamp FROM " + stream
 		stream_api = TraceAPI
 	} else {
 		sql = "SELECT " + fileds + " FROM " + stream
 		stream_api = MetadataAPI
 	}
 
-	cond := s.buildSQLCond(ctx, q)
+	cond := s.buildSQLCond(ctx, q, fieldColumns(stream))
 
 	if len(cond) > 0 {
 		sql = sql + " WHERE " + strings.Join(cond, " AND ")
 	}
 
-	sql = sql + " GROUP BY trace_id ORDER BY _timestamp DESC "
+	sql = sql + " GROUP BY " + fieldColumns(stream)(OOSpanFixedKey.TraceID) + " ORDER BY _timestamp DESC "
 
 	if q.NumTraces > 0 {
 		sql = sql + fmt.Sprintf(" LIMIT %d", q.NumTraces)
@@ -453,40 +839,34 @@ func (s *JaegerService) buildSQL(ctx *gin.Context, fileds string, q *TraceQueryP
 	return sql, stream_api
 }
 
-func (s *JaegerService) buildSQLCond(ctx *gin.Context, q *TraceQueryParameters) []string {
+// buildSQLCond returns the conditions of q, column maps the span fields to the columns of the queried stream.
+func (s *JaegerService) buildSQLCond(ctx *gin.Context, q *TraceQueryParameters, column func(string) string) []string {
 	cond := make([]string, 0, 10)
 
 	if len(q.ServiceName) == 1 {
-		cond = append(cond, "service_name ='"+q.ServiceName[0]+"'")
+		cond = append(cond, column(OOSpanFixedKey.ServiceName)+" ="+sqlString(q.ServiceName[0]))
 	} else if len(q.ServiceName) > 1 {
-		cond = append(cond, "service_name IN('"+strings.Join(q.ServiceName, "','")+"')")
+		cond = append(cond, column(OOSpanFixedKey.ServiceName)+" IN("+sqlStrings(q.ServiceName)+")")
 	}
 
 	if len(q.OperationName) > 0 {
-		cond = append(cond, "operation_name IN('"+strings.Join(q.OperationName, "','")+"')")
+		cond = append(cond, column(OOSpanFixedKey.OperationName)+" IN("+sqlStrings(q.OperationName)+")")
 	}
 
 	if q.DurationMin > 0 {
-		cond = append(cond, fmt.Sprintf("duration >= %d", q.DurationMin.Microseconds()))
+		cond = append(cond, durationCondition(ctx, column, ">=", q.DurationMin))
 	}
 
 	if q.DurationMax > 0 {
-		cond = append(cond, fmt.Sprintf("duration <= %d", q.DurationMax.Microseconds()))
+		cond = append(cond, durationCondition(ctx, column, "<=", q.DurationMax))
 	}
 
 	if len(q.Tags) > 0 {
 		tags := make([]string, 0, len(q.Tags))
 		for k, v := range q.Tags {
-			if k == OOSpanFixedKey.Error {
-				vv := cast.ToString(v)
-				if vv == "true" {
-					tags = append(tags, "span_status='ERROR'")
-				}
-
-			} else {
-				tags = append(tags, fmt.Sprintf("%s='%s'", k, cast.ToString(v)))
+			if c := tagTermCondition(TagTerm{Key: k, Value: v}, column); c != "" {
+				tags = append(tags, c)
 			}
-
 		}
 
 		if len(tags) > 0 {
@@ -494,6 +874,12 @@ func (s *JaegerService) buildSQLCond(ctx *gin.Context, q *TraceQueryParameters)
 		}
 	}
 
+	for _, group := range q.TagGroups {
+		if c := tagGroupCondition(group, column); c != "" {
+			cond = append(cond, c)
+		}
+	}
+
 	return cond
 }
 
@@ -502,73 +888,91 @@ func (s *JaegerService) GetTrace(ctx *gin.Context, q *openobserve_service.OOQuer
 		Errors: make([]JaegerStructuredError, 0),
 	}
 
-	uiErrors := make([]JaegerStructuredError, 0)
+	ooresp, jaegerErr := s.searchTraceSpans(ctx, q)
+	if jaegerErr != nil {
+		resp.Errors = append(resp.Errors, *jaegerErr)
+		return resp
+	}
 
-	var sql string
-	sql = fmt.Sprintf("SELECT * FROM default WHERE trace_id = '%s' ORDER BY start_time", q.TraceID)
-	var start, end int64
+	traces, jaegerErr := s.transOOToJaegerUI(ctx, ooresp, q.TraceID)
+	data := []*ui.Trace{traces}
+	resp.Data = data
+
+	if jaegerErr != nil {
+		resp.Errors = append(resp.Errors, *jaegerErr)
+	}
+
+	return resp
+}
+
+// detailTimeRange returns the q time range in unix microseconds, or the last
+// DefaultTraceDetailSearchRange hours if q has none.
+func detailTimeRange(q *openobserve_service.OOQuery) (int64, int64) {
 	if q.StartTime.IsZero() && q.EndTime.IsZero() {
-		start = time.Now().Add(-time.Hour * time.Duration(config.Cfg.OpenObserve.DefaultTraceDetailSearchRange)).UnixMicro()
-		end = time.Now().UnixMicro()
-	} else {
-		start = q.StartTime.UnixMicro()
-		end = q.EndTime.UnixMicro()
+		return time.Now().Add(-time.Hour * time.Duration(config.Get().OpenObserve.DefaultTraceDetailSearchRange)).UnixMicro(), time.Now().UnixMicro()
 	}
+	return q.StartTime.UnixMicro(), q.EndTime.UnixMicro()
+}
+
+// searchTraceSpans fetches the spans of q.TraceID, at most max_trace_spans + 1. Without a time range in q, the range is looked up
+// in trace_list_index, falling back to the default trace detail range.
+func (s *JaegerService) searchTraceSpans(ctx *gin.Context, q *openobserve_service.OOQuery) (*openobserve_service.OpenObserveResp, *JaegerStructuredError) {
+	var sql string
+	sql = fmt.Sprintf("SELECT * FROM default WHERE %s ORDER BY %s", traceIDCond(fieldColumn(OOSpanFixedKey.TraceID), q.TraceID), fieldColumn(OOSpanFixedKey.StartTime))
+	start, end := s.traceDetailTimeRange(ctx, q)
 
 	qq := openobserve_service.OOSearchQuery{
+		Stream: openobserve_service.SearchTraceDefaultStream,
 		Query: openobserve_service.OOSearchQueryQuery{
-			SqlMode:   "full",
 			StartTime: start,
 			EndTime:   end,
 			Sql:       base64.StdEncoding.EncodeToString([]byte(sql)),
 			Size:      -1, // get all trace id
 		},
 	}
+	if maxSpans := config.Get().OpenObserve.MaxTraceSpans; maxSpans > 0 {
+		// the first spans by start time, one more tells transOOToJaegerUI the trace is truncated
+		qq.Query.Size = int64(maxSpans) + 1
+	}
 
-	ooresp, err := s.ooservice.SearchTraces(ctx, qq)
+	ooresp, err := s.ooservice.SearchTraces(openobserve_service.WithAffinity(ctx, q.TraceID), qq)
 	if err != nil {
-		resp.Errors = append(uiErrors, JaegerStructuredError{
-			Code:    500,
-			Msg:     err.Error(),
-			TraceID: ui.TraceID(q.TraceID),
-		})
-
-		return resp
+		e := structuredError(err)
+		e.TraceID = ui.TraceID(q.TraceID)
+		return nil, &e
 	}
 
 	if len(ooresp.Hits) == 0 {
-		resp.Errors = append(uiErrors, JaegerStructuredError{
-			Code:    404,
-			Msg:     "trace not found",
-			TraceID: ui.TraceID(q.TraceID),
-		})
-
-		return resp
-	}
-
-	traces, jaegerErr := s.transOOToJaegerUI(ctx, ooresp, q.TraceID)
-	data := []*ui.Trace{traces}
-	resp.Data = data
-
-	if jaegerErr != nil {
-		resp.Errors = append(resp.Errors, *jaegerErr)
+		e := structuredError(errors.NotFound("trace not found"))
+		e.TraceID = ui.TraceID(q.TraceID)
+		return nil, &e
 	}
 
-	return resp
+	ooresp.Hits = dedupeSpanHits(mapSpanHits(ooresp.Hits))
+	return ooresp, nil
 }
 
 func (s *JaegerService) transOOToJaegerUI(ctx *gin.Context, oo *openobserve_service.OpenObserveResp, traceStrID string) (*ui.Trace, *JaegerStructuredError) {
 	if oo == nil {
 		return nil, nil
 	}
+	defer timing.Track(ctx, timing.PhaseConversion)()
+
+	// searchTraceSpans fetches one span more than max_trace_spans to tell a truncated trace
+	maxSpans := config.Get().OpenObserve.MaxTraceSpans
+	truncated := maxSpans > 0 && len(oo.Hits) > maxSpans
+	if truncated {
+		limited := *oo
+		limited.Hits = oo.Hits[:maxSpans]
+		oo = &limited
+	}
+
 	// traceID, err := model.TraceIDFromString(traceStrID)
-	trace, err := s.transOOToJaegerModelTrace(ctx, oo)
+	trace, skipped, err := s.transOOToJaegerModelTrace(ctx, oo)
 	if err != nil {
-		return nil, &JaegerStructuredError{
-			Code:    400,
-			Msg:     "400",
-			TraceID: ui.TraceID(traceStrID),
-		}
+		e := structuredError(errors.Wrap(err, "converting trace"))
+		e.TraceID = ui.TraceID(traceStrID)
+		return nil, &e
 	}
 	var errors []error
 	trace, err = s.adjuster.Adjust(trace)
@@ -577,27 +981,56 @@ func (s *JaegerService) transOOToJaegerUI(ctx *gin.Context, oo *openobserve_serv
 	}
 
 	uiTrace := uiconv.FromDomain(trace)
+	putSpanSlice(trace.Spans)
+	normalizeSpanTraceIDs(uiTrace, traceStrID)
+	qualityMetrics.record(uiTrace, oo.Hits, truncated)
+	if config.Get().RootCauseHints {
+		annotateRootCauseHints(uiTrace)
+	}
+
 	var uiError *JaegerStructuredError
-	if err := multierror.Wrap(errors); err != nil {
+	warnings := make([]string, 0, 2)
+	if truncated {
+		warnings = append(warnings, fmt.Sprintf("trace truncated to its first %d spans by start time, it has more than openobserve.max_trace_spans", maxSpans))
+	}
+	if len(skipped) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d of %d spans skipped, they could not be converted: %s", len(skipped), len(oo.Hits), strings.Join(skipped, "; ")))
+	}
+	if len(warnings) > 0 {
+		uiTrace.Warnings = append(uiTrace.Warnings, warnings...)
+		warning := strings.Join(warnings, "; ")
+		if err := multierror.Wrap(errors); err != nil {
+			warning += "; " + err.Error()
+		}
+		uiError = &JaegerStructuredError{
+			Code:    http.StatusPartialContent,
+			Msg:     warning,
+			TraceID: uiTrace.TraceID,
+		}
+	} else if err := multierror.Wrap(errors); err != nil {
 		uiError = &JaegerStructuredError{
 			Msg:     err.Error(),
 			TraceID: uiTrace.TraceID,
 		}
 	}
 
+	traceFieldsFromContext(ctx).apply(uiTrace)
 	return uiTrace, uiError
 }
 
-func (s *JaegerService) transOOToJaegerModelTrace(ctx *gin.Context, oo *openobserve_service.OpenObserveResp) (*model.Trace, error) {
+// transOOToJaegerModelTrace converts the spans, the ones failing the conversion are skipped and
+// reported as "spanID: reason" in the returned list.
+func (s *JaegerService) transOOToJaegerModelTrace(ctx *gin.Context, oo *openobserve_service.OpenObserveResp) (*model.Trace, []string, error) {
 	if oo == nil {
-		return nil, nil
+		return nil, nil, nil
 	}
+	skipped := make([]string, 0)
 
 	spanConverter := NewToDomain("@")
 
-	spans := make([]*model.Span, 0, len(oo.Hits))
+	spans := getSpanSlice()
 	for _, oospan := range oo.Hits {
-		jsonSpan := s.transOOSpanToDbModelSpan(ctx, oospan)
+		jsonSpan, warnings := s.transOOSpanToDbModelSpan(ctx, oospan)
 
 		if jsonSpan == nil {
 			continue
@@ -605,22 +1038,26 @@ func (s *JaegerService) transOOToJaegerModelTrace(ctx *gin.Context, oo *openobse
 
 		span, err := spanConverter.SpanToDomain(jsonSpan)
 		if err != nil {
-			log.Printf("spanid: %s, spanConverter.SpanToDomain err : %v\n", jsonSpan.SpanID, err)
+			requestid.Logf(ctx, "spanid: %s, spanConverter.SpanToDomain err : %v\n", jsonSpan.SpanID, err)
+			skipped = append(skipped, fmt.Sprintf("%s: %v", jsonSpan.SpanID, err))
 			continue
 		}
 
 		if span != nil {
+			span.Warnings = append(span.Warnings, warnings...)
 			spans = append(spans, span)
 		}
 
 	}
 
-	return &model.Trace{Spans: spans}, nil
+	return &model.Trace{Spans: spans}, skipped, nil
 }
 
-func (s *JaegerService) transOOSpanToDbModelSpan(ctx *gin.Context, oo map[string]interface{}) *dbmodel.Span {
+// transOOSpanToDbModelSpan converts an OpenObserve span, the returned warnings belong to the span,
+// dbmodel.Span has no field for them.
+func (s *JaegerService) transOOSpanToDbModelSpan(ctx *gin.Context, oo map[string]interface{}) (*dbmodel.Span, []string) {
 	if oo == nil {
-		return nil
+		return nil, nil
 	}
 
 	startTime := cast.ToInt64(oo[OOSpanFixedKey.StartTime])
@@ -633,92 +1070,81 @@ func (s *JaegerService) transOOSpanToDbModelSpan(ctx *gin.Context, oo map[string
 			ServiceName: cast.ToString(oo[OOSpanFixedKey.ServiceName]),
 			Tags:        make([]dbmodel.KeyValue, 0),
 		},
-		Flags:           cast.ToUint32(oo[OOSpanFixedKey.Flags]),
+		// OTLP keeps the W3C trace flags (bit 0 sampled) in the low byte, the higher bits are not jaeger flags
+		Flags:           cast.ToUint32(oo[OOSpanFixedKey.Flags]) & 0xff,
 		ParentSpanID:    dbmodel.SpanID(cast.ToString(oo[OOSpanFixedKey.ReferenceParentSpanId])),
 		StartTime:       cast.ToUint64(st.UnixMicro()),
 		StartTimeMillis: cast.ToUint64(st.UnixMilli()),
-		Duration:        cast.ToUint64(oo[OOSpanFixedKey.Duration]),
+		Duration:        spanDurationMicros(ctx, oo),
 		Logs:            make([]dbmodel.Log, 0),
 		Tags:            make([]dbmodel.KeyValue, 0),
 		References:      make([]dbmodel.Reference, 0),
 	}
 
+	var warnings []string
 	newoo := s.trimSpanFixedKey(oo)
-	dbSpan.Logs = s.collectOOLogs(newoo)
+	logs, warning := s.collectOOLogs(newoo)
+	if warning != "" {
+		warnings = append(warnings, warning)
+	}
+	dbSpan.Logs = logs
 	dbSpan.Tags = s.collectOOTags(newoo)
 	dbSpan.Process.Tags = s.collectOOProcessTags(newoo)
 	dbSpan.References = s.collectOOReferences(newoo)
 
-	return dbSpan
+	return dbSpan, warnings
 }
 
+// collectOOReferences maps the parent reference columns and every entry of the JSON links column,
+// the parent defaults to CHILD_OF and the links to FOLLOWS_FROM.
 func (s *JaegerService) collectOOReferences(oo map[string]interface{}) []dbmodel.Reference {
 	ref := make([]dbmodel.Reference, 0)
-	if len(cast.ToString(oo[OOSpanFixedKey.ReferenceParentSpanId])) == 0 {
-		return ref
-	}
-
-	// default CHILD_OF
-	ReferenceRefType := strings.ToUpper(cast.ToString(oo[OOSpanFixedKey.ReferenceRefType]))
-	if ReferenceRefType == "CHILDOF" {
-		ReferenceRefType = "CHILD_OF"
-	} else if ReferenceRefType == "FOLLOWS_FROM" {
-		ReferenceRefType = "FOLLOWS_FROM"
-	} else {
-		ReferenceRefType = "CHILD_OF"
+	if parentSpanID := cast.ToString(oo[OOSpanFixedKey.ReferenceParentSpanId]); len(parentSpanID) > 0 {
+		ref = append(ref, dbmodel.Reference{
+			RefType: refType(cast.ToString(oo[OOSpanFixedKey.ReferenceRefType]), dbmodel.ChildOf),
+			TraceID: dbmodel.TraceID(cast.ToString(oo[OOSpanFixedKey.ReferenceParentTraceId])),
+			SpanID:  dbmodel.SpanID(parentSpanID),
+		})
 	}
 
-	r := dbmodel.Reference{
-		RefType: dbmodel.ReferenceType(ReferenceRefType),
-		TraceID: dbmodel.TraceID(cast.ToString(oo[OOSpanFixedKey.ReferenceParentTraceId])),
-		SpanID:  dbmodel.SpanID(cast.ToString(oo[OOSpanFixedKey.ReferenceParentSpanId])),
+	for _, link := range parseOOLinks(oo[OOSpanFixedKey.Links]) {
+		duplicate := false
+		for _, r := range ref {
+			if r.TraceID == link.TraceID && r.SpanID == link.SpanID {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			ref = append(ref, link)
+		}
 	}
 
-	ref = append(ref, r)
-
 	return ref
 }
 
-func (s *JaegerService) collectOOLogs(oo map[string]interface{}) []dbmodel.Log {
+// collectOOLogs maps the events column to the span logs, warning is set if it was malformed,
+// see parseOOEvents.
+func (s *JaegerService) collectOOLogs(oo map[string]interface{}) ([]dbmodel.Log, string) {
 	logs := make([]dbmodel.Log, 0)
+	var warning string
 	if len(oo) == 0 {
-		return logs
+		return logs, warning
 	}
 
 	if events, ok := oo[OOSpanFixedKey.Events]; ok {
-		evs := make([]map[string]interface{}, 1)
-		err := json.Unmarshal([]byte(cast.ToString(events)), &evs)
-		if err != nil {
-			log.Printf("%#v", err)
-			return logs
+		var evs []map[string]interface{}
+		evs, warning = parseOOEvents(events)
+		if warning != "" {
+			log.Printf("span_id: %s, %s", cast.ToString(oo[OOSpanFixedKey.SpanID]), warning)
 		}
 
-		for _, v := range evs {
-			log := dbmodel.Log{
-				Timestamp: 0,
-				Fields:    make([]dbmodel.KeyValue, 0),
-			}
-
-			startTime := cast.ToInt64(v[OOSpanFixedKey.Timestamp])
-			st := time.Unix(startTime/1e9, (startTime % 1e9))
-			log.Timestamp = cast.ToUint64(st.UnixMicro())
-			for k, vvv := range v {
-				if k == OOSpanFixedKey.Timestamp {
-					continue
-				}
-				log.Fields = append(log.Fields, dbmodel.KeyValue{
-					Key:   k,
-					Type:  dbmodel.ValueType("string"),
-					Value: cast.ToString(vvv),
-				})
-			}
-
-			logs = append(logs, log)
+		for _, ev := range evs {
+			logs = append(logs, eventLog(ev))
 		}
-
 	}
 
-	return logs
+	return logs, warning
 }
 
 func (s *JaegerService) collectOOTags(oo map[string]interface{}) []dbmodel.KeyValue {
@@ -727,29 +1153,14 @@ func (s *JaegerService) collectOOTags(oo map[string]interface{}) []dbmodel.KeyVa
 		return kvs
 	}
 
+	processTags := processTagsReg()
+	arrayTags := config.Get().OpenObserve.ArrayTags
 	for k, v := range oo {
 		if k == OOSpanFixedKey.SpanKind {
-			kind := cast.ToInt(v)
-			value := ""
-			switch trace.SpanKind(kind) {
-			case trace.SpanKindUnspecified:
-				value = "unspecified"
-			case trace.SpanKindInternal:
-				value = "internal"
-			case trace.SpanKindServer:
-				value = "server"
-			case trace.SpanKindClient:
-				value = "client"
-			case trace.SpanKindProducer:
-				value = "producer"
-			case trace.SpanKindConsumer:
-				value = "consumer"
-			}
-
 			kv := dbmodel.KeyValue{
 				Key:   "span.kind",
 				Type:  dbmodel.ValueType("string"),
-				Value: value,
+				Value: SpanKindName(cast.ToInt(v)),
 			}
 
 			kvs = append(kvs, kv)
@@ -779,42 +1190,80 @@ func (s *JaegerService) collectOOTags(oo map[string]interface{}) []dbmodel.KeyVa
 			continue
 		}
 
-		if k == OOSpanFixedKey.Events {
+		if k == OOSpanFixedKey.TraceState {
+			if value := cast.ToString(v); value != "" {
+				kvs = append(kvs, dbmodel.KeyValue{Key: TraceStateTag, Type: dbmodel.StringType, Value: value})
+			}
 			continue
 		}
 
-		if !DbModelProcessTagsRulesReg.MatchString(k) {
-			kv := dbmodel.KeyValue{
-				Key:   k,
-				Type:  dbmodel.ValueType("string"),
-				Value: v,
-			}
+		if k == OOSpanFixedKey.Links {
+			kvs = append(kvs, parseOOLinkAttributes(v, arrayTags)...)
+			continue
+		}
 
-			kvs = append(kvs, kv)
+		if isSpanLevelKey(k) {
+			continue
+		}
+
+		if !processTags.MatchString(k) {
+			kvs = append(kvs, attributeTags(k, v, arrayTags)...)
 		}
 	}
 
 	return kvs
 }
 
+// SpanKindName maps the OTLP span kind stored by OpenObserve to the jaeger span.kind tag value
+func SpanKindName(kind int) string {
+	switch trace.SpanKind(kind) {
+	case trace.SpanKindUnspecified:
+		return "unspecified"
+	case trace.SpanKindInternal:
+		return "internal"
+	case trace.SpanKindServer:
+		return "server"
+	case trace.SpanKindClient:
+		return "client"
+	case trace.SpanKindProducer:
+		return "producer"
+	case trace.SpanKindConsumer:
+		return "consumer"
+	}
+	return ""
+}
+
+// SpanKindValue is the reverse of SpanKindName
+func SpanKindValue(name string) (int, bool) {
+	for kind := trace.SpanKindUnspecified; kind <= trace.SpanKindConsumer; kind++ {
+		if SpanKindName(int(kind)) == strings.ToLower(name) {
+			return int(kind), true
+		}
+	}
+	return 0, false
+}
+
+// collectOOProcessTags returns the resource attributes sorted by key.
 func (s *JaegerService) collectOOProcessTags(oo map[string]interface{}) []dbmodel.KeyValue {
 	kvs := make([]dbmodel.KeyValue, 0)
 	if len(oo) == 0 {
 		return kvs
 	}
 
+	processTags := processTagsReg()
+	arrayTags := config.Get().OpenObserve.ArrayTags
 	for k, v := range oo {
-		if DbModelProcessTagsRulesReg.MatchString(k) {
-			kv := dbmodel.KeyValue{
-				Key:   k,
-				Type:  dbmodel.ValueType("string"),
-				Value: v,
-			}
-
-			kvs = append(kvs, kv)
+		if !isSpanLevelKey(k) && processTags.MatchString(k) {
+			kvs = append(kvs, attributeTags(k, v, arrayTags)...)
 		}
 	}
 
+	// FromDomain shares one process between the spans of the same service and resource,
+	// it compares the tags in order so they must not follow the map iteration order
+	sort.Slice(kvs, func(i, j int) bool {
+		return kvs[i].Key < kvs[j].Key
+	})
+
 	return kvs
 }
 
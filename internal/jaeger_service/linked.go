@@ -0,0 +1,89 @@
+package jaeger_service
+
+import (
+	"github.com/gin-gonic/gin"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"github.com/spf13/cast"
+	"net/http"
+	"openobserve-jaeger/internal/openobserve_service"
+	"sort"
+)
+
+const (
+	LinkOutgoing = "outgoing" // spans of the trace reference the linked trace
+	LinkIncoming = "incoming" // spans of the linked trace reference the trace
+)
+
+// LinkedTrace is a trace of the /api/traces/:id/linked response
+type LinkedTrace struct {
+	TraceID   string   `json:"traceID"`
+	Direction string   `json:"direction"`
+	SpanIDs   []string `json:"spanIDs"` // the referencing spans
+}
+
+// GetLinkedTraces returns the traces referenced by the spans of q.TraceID, and the traces whose spans
+// reference it. A failed reverse lookup only drops the incoming links with a 206 warning.
+func (s *JaegerService) GetLinkedTraces(ctx *gin.Context, q *openobserve_service.OOQuery) JaegerStructuredResponse {
+	resp := JaegerStructuredResponse{
+		Data:   make([]LinkedTrace, 0),
+		Errors: make([]JaegerStructuredError, 0),
+	}
+
+	ooresp, jaegerErr := s.searchTraceSpans(ctx, q)
+	if jaegerErr != nil {
+		resp.Errors = append(resp.Errors, *jaegerErr)
+		return resp
+	}
+
+	links := make(map[string]*LinkedTrace)
+	addLink := func(traceID, direction, spanID string) {
+		key := direction + "/" + traceID
+		link, ok := links[key]
+		if !ok {
+			link = &LinkedTrace{TraceID: traceID, Direction: direction, SpanIDs: make([]string, 0, 1)}
+			links[key] = link
+		}
+		if !containsString(link.SpanIDs, spanID) {
+			link.SpanIDs = append(link.SpanIDs, spanID)
+		}
+	}
+
+	for _, hit := range ooresp.Hits {
+		for _, ref := range s.collectOOReferences(hit) {
+			if id, err := NormalizeTraceID(string(ref.TraceID)); err == nil && id != q.TraceID {
+				addLink(string(ref.TraceID), LinkOutgoing, cast.ToString(hit[OOSpanFixedKey.SpanID]))
+			}
+		}
+	}
+
+	// the linked traces usually start after the trace, e.g. the consumers of a message
+	start, end := detailTimeRange(q)
+	incoming, err := s.ooservice.GetReferencingSpans(ctx, fieldColumn, traceIDForms(q.TraceID), start, end)
+	if err != nil {
+		resp.Errors = append(resp.Errors, JaegerStructuredError{
+			Code:    http.StatusPartialContent,
+			Msg:     "incoming links lookup failed: " + err.Error(),
+			TraceID: ui.TraceID(q.TraceID),
+		})
+	} else {
+		for _, hit := range incoming.Hits {
+			addLink(cast.ToString(hit[OOSpanFixedKey.TraceID]), LinkIncoming, cast.ToString(hit[OOSpanFixedKey.SpanID]))
+		}
+	}
+
+	data := make([]LinkedTrace, 0, len(links))
+	for _, link := range links {
+		sort.Strings(link.SpanIDs)
+		data = append(data, *link)
+	}
+	sort.Slice(data, func(i, j int) bool {
+		if data[i].Direction != data[j].Direction {
+			return data[i].Direction > data[j].Direction
+		}
+		return data[i].TraceID < data[j].TraceID
+	})
+
+	resp.Data = data
+	resp.Total = len(data)
+	return resp
+}
@@ -0,0 +1,75 @@
+package jaeger_service
+
+import (
+	"github.com/spf13/cast"
+	"sort"
+)
+
+// The searches of openobserve.clusters return the hits of every cluster owning a part of the time range,
+// a trace or a group spanning the ownership boundary, or found in two clusters while the data moves,
+// comes back more than once. The helpers below merge them.
+
+// mergeTraceListItems keeps every trace once with its earliest timestamp, the newest limit traces first.
+func mergeTraceListItems(found []traceListItem, limit int) []traceListItem {
+	earliest := make(map[string]int64, len(found))
+	for _, item := range found {
+		if ts, ok := earliest[item.TraceID]; !ok || item.Timestamp < ts {
+			earliest[item.TraceID] = item.Timestamp
+		}
+	}
+
+	items := make([]traceListItem, 0, len(earliest))
+	for id, ts := range earliest {
+		items = append(items, traceListItem{TraceID: id, Timestamp: ts})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Timestamp != items[j].Timestamp {
+			return items[i].Timestamp > items[j].Timestamp
+		}
+		return items[i].TraceID < items[j].TraceID
+	})
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+// dedupeSpanHits keeps the first hit of every trace and span id.
+func dedupeSpanHits(hits []map[string]interface{}) []map[string]interface{} {
+	seen := make(map[string]struct{}, len(hits))
+	res := hits[:0]
+	for _, hit := range hits {
+		key := cast.ToString(hit[OOSpanFixedKey.TraceID]) + "/" + cast.ToString(hit[OOSpanFixedKey.SpanID])
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		res = append(res, hit)
+	}
+	return res
+}
+
+// sumCountHits adds up the columns of the one row count queries.
+func sumCountHits(hits []map[string]interface{}) map[string]interface{} {
+	sums := make(map[string]uint64)
+	for _, hit := range hits {
+		for k, v := range hit {
+			sums[k] += cast.ToUint64(v)
+		}
+	}
+
+	res := make(map[string]interface{}, len(sums))
+	for k, v := range sums {
+		res[k] = v
+	}
+	return res
+}
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package jaeger_service
+
+import (
+	"fmt"
+	"github.com/jaegertracing/jaeger/model"
+	"openobserve-jaeger/internal/otlp"
+	"strings"
+)
+
+const otlpScopeName = "openobserve-jaeger/import"
+
+// ToOTLP maps Jaeger spans to an OTLP export request, the reverse of the OpenObserve span layout read by this
+// proxy: the process becomes the resource, span.kind and the status tags become the span kind and status,
+// the first CHILD_OF reference the parent and the other references links, the logs events named by their
+// event field.
+func ToOTLP(spans []*model.Span) otlp.Request {
+	resources := make(map[string]*otlp.ResourceSpans)
+	order := make([]string, 0)
+	for _, span := range spans {
+		key := processKey(span.Process)
+		rs, ok := resources[key]
+		if !ok {
+			rs = &otlp.ResourceSpans{
+				Resource:   otlp.Resource{Attributes: otlpResourceAttributes(span.Process)},
+				ScopeSpans: []otlp.ScopeSpans{{Scope: otlp.Scope{Name: otlpScopeName}}},
+			}
+			resources[key] = rs
+			order = append(order, key)
+		}
+		rs.ScopeSpans[0].Spans = append(rs.ScopeSpans[0].Spans, otlpSpan(span))
+	}
+
+	req := otlp.Request{ResourceSpans: make([]otlp.ResourceSpans, 0, len(order))}
+	for _, key := range order {
+		req.ResourceSpans = append(req.ResourceSpans, *resources[key])
+	}
+	return req
+}
+
+func processKey(p *model.Process) string {
+	if p == nil {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(p.ServiceName)
+	for _, tag := range p.Tags {
+		b.WriteString("\x00" + tag.Key + "=" + tag.AsString())
+	}
+	return b.String()
+}
+
+func otlpResourceAttributes(p *model.Process) []otlp.KeyValue {
+	if p == nil {
+		return []otlp.KeyValue{}
+	}
+	attrs := []otlp.KeyValue{otlp.String("service.name", p.ServiceName)}
+	for _, tag := range p.Tags {
+		attrs = append(attrs, otlpKeyValue(tag))
+	}
+	return attrs
+}
+
+func otlpSpan(span *model.Span) otlp.Span {
+	start := span.StartTime.UnixNano()
+	os := otlp.Span{
+		TraceID:           otlpTraceID(span.TraceID),
+		SpanID:            fmt.Sprintf("%016x", uint64(span.SpanID)),
+		Name:              span.OperationName,
+		StartTimeUnixNano: otlp.UnixNano(start),
+		EndTimeUnixNano:   otlp.UnixNano(start + span.Duration.Nanoseconds()),
+	}
+
+	parentFound := false
+	for _, ref := range span.References {
+		if !parentFound && ref.RefType == model.ChildOf && ref.TraceID == span.TraceID {
+			os.ParentSpanID = fmt.Sprintf("%016x", uint64(ref.SpanID))
+			parentFound = true
+			continue
+		}
+		os.Links = append(os.Links, otlp.Link{
+			TraceID:    otlpTraceID(ref.TraceID),
+			SpanID:     fmt.Sprintf("%016x", uint64(ref.SpanID)),
+			Attributes: []otlp.KeyValue{otlp.String("opentracing.ref_type", strings.ToLower(ref.RefType.String()))},
+		})
+	}
+
+	for _, tag := range span.Tags {
+		switch tag.Key {
+		case "span.kind":
+			if kind, ok := SpanKindValue(tag.AsString()); ok {
+				os.Kind = kind
+			}
+			continue
+		case "otel.status_code":
+			switch strings.ToUpper(tag.AsString()) {
+			case "OK":
+				os.Status.Code = otlp.StatusOk
+			case "ERROR":
+				os.Status.Code = otlp.StatusError
+			}
+			continue
+		case "otel.status_description":
+			os.Status.Message = tag.AsString()
+			continue
+		case "error":
+			if tag.AsString() == "true" {
+				os.Status.Code = otlp.StatusError
+			}
+			continue
+		case TraceStateTag:
+			os.TraceState = tag.AsString()
+			continue
+		}
+		os.Attributes = append(os.Attributes, otlpKeyValue(tag))
+	}
+
+	for _, l := range span.Logs {
+		ev := otlp.Event{TimeUnixNano: otlp.UnixNano(l.Timestamp.UnixNano())}
+		for _, f := range l.Fields {
+			if f.Key == eventNameField && ev.Name == "" {
+				ev.Name = f.AsString()
+				continue
+			}
+			ev.Attributes = append(ev.Attributes, otlpKeyValue(f))
+		}
+		os.Events = append(os.Events, ev)
+	}
+	return os
+}
+
+// otlpTraceID is the 32 hex digits trace id, model.TraceID.String drops the zero high bits.
+func otlpTraceID(id model.TraceID) string {
+	return fmt.Sprintf("%016x%016x", id.High, id.Low)
+}
+
+func otlpKeyValue(kv model.KeyValue) otlp.KeyValue {
+	switch kv.VType {
+	case model.BoolType:
+		return otlp.Bool(kv.Key, kv.Bool())
+	case model.Int64Type:
+		return otlp.Int64(kv.Key, kv.Int64())
+	case model.Float64Type:
+		return otlp.Float64(kv.Key, kv.Float64())
+	}
+	return otlp.String(kv.Key, kv.AsString())
+}
@@ -0,0 +1,85 @@
+package jaeger_service
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"openobserve-jaeger/internal/errors"
+	"sync"
+	"time"
+)
+
+// searchTracesIdsParallel splits [StartTimeMin, StartTimeMax] into n sub-ranges searched concurrently,
+// every sub-range keeps the limit since the traces are not evenly spread. The ids are merged by their
+// earliest timestamp, the newest NumTraces are returned like the single query does.
+//
+// A failed sub-range turns into a http.StatusPartialContent warning next to the traces of the others,
+// unless q.Strict is set or all of them failed.
+func (s *JaegerService) searchTracesIdsParallel(ctx *gin.Context, q *TraceQueryParameters, n int) ([]traceListItem, []JaegerStructuredError) {
+	window := q.StartTimeMax.Sub(q.StartTimeMin)
+	step := window / time.Duration(n)
+	if step < time.Second {
+		return s.searchTracesIds(ctx, q)
+	}
+
+	type result struct {
+		items  []traceListItem
+		errors []JaegerStructuredError
+	}
+	results := make([]result, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		sub := *q
+		sub.StartTimeMin = q.StartTimeMin.Add(step * time.Duration(i))
+		if i < n-1 {
+			sub.StartTimeMax = sub.StartTimeMin.Add(step)
+		}
+
+		wg.Add(1)
+		go func(i int, sub *TraceQueryParameters) {
+			defer wg.Done()
+			items, structErrors := s.searchTracesIds(ctx, sub)
+			results[i] = result{items: items, errors: structErrors}
+		}(i, &sub)
+	}
+	wg.Wait()
+
+	found := make([]traceListItem, 0)
+	warnings := make([]JaegerStructuredError, 0)
+	failed := 0
+	for i, r := range results {
+		if len(r.errors) > 0 {
+			if r.errors[0].Code == 404 {
+				continue
+			}
+			if q.Strict {
+				return nil, r.errors
+			}
+			failed++
+			warnings = append(warnings, JaegerStructuredError{
+				Code: http.StatusPartialContent,
+				Msg:  fmt.Sprintf("sub-range %d/%d of the search failed, its traces are missing: %s", i+1, n, r.errors[0].Msg),
+			})
+			continue
+		}
+		found = append(found, r.items...)
+	}
+
+	if failed == n {
+		return nil, results[0].errors
+	}
+
+	if len(found) == 0 {
+		if len(warnings) > 0 {
+			return nil, warnings
+		}
+		return nil, []JaegerStructuredError{structuredError(errors.NotFound("trace not found"))}
+	}
+
+	items := mergeTraceListItems(found, q.NumTraces)
+	if len(warnings) > 0 {
+		return items, warnings
+	}
+	return items, nil
+}
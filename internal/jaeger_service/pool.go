@@ -0,0 +1,35 @@
+package jaeger_service
+
+import (
+	"github.com/jaegertracing/jaeger/model"
+	"sync"
+)
+
+// maxPooledSpans is the largest span slice kept in the pool, the slice of a pathological trace is
+// left to the garbage collector instead of being pinned in the pool.
+const maxPooledSpans = 10000
+
+// spanSlicePool recycles the model span slices of the conversions, nothing references them once
+// the trace is converted to the ui model. The []*ui.Trace slices are not pooled, the responses hand
+// them on to the search cache, the audit log and the encoders after the handler returns.
+var spanSlicePool = sync.Pool{
+	New: func() interface{} {
+		spans := make([]*model.Span, 0, 256)
+		return &spans
+	},
+}
+
+func getSpanSlice() []*model.Span {
+	return (*spanSlicePool.Get().(*[]*model.Span))[:0]
+}
+
+func putSpanSlice(spans []*model.Span) {
+	if cap(spans) > maxPooledSpans {
+		return
+	}
+	for i := range spans {
+		spans[i] = nil
+	}
+	spans = spans[:0]
+	spanSlicePool.Put(&spans)
+}
@@ -0,0 +1,74 @@
+package jaeger_service
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http/httptest"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/openobserve_service"
+	"testing"
+)
+
+// benchHits is an OpenObserve result of a trace of n spans.
+func benchHits(n int) *openobserve_service.OpenObserveResp {
+	oo := &openobserve_service.OpenObserveResp{Hits: make([]map[string]interface{}, n)}
+	for i := range oo.Hits {
+		oo.Hits[i] = map[string]interface{}{
+			OOSpanFixedKey.TraceID:                "4bf92f3577b34da6a3ce929d0e0e4736",
+			OOSpanFixedKey.SpanID:                 fmt.Sprintf("%016x", i+1),
+			OOSpanFixedKey.ReferenceParentSpanId:  fmt.Sprintf("%016x", i/2+1),
+			OOSpanFixedKey.ReferenceParentTraceId: "4bf92f3577b34da6a3ce929d0e0e4736",
+			OOSpanFixedKey.ReferenceRefType:       "ChildOf",
+			OOSpanFixedKey.ServiceName:            "checkout",
+			OOSpanFixedKey.OperationName:          "GET /api/orders/:id",
+			OOSpanFixedKey.StartTime:              int64(1700000000000000000 + i*10000),
+			OOSpanFixedKey.EndTime:                int64(1700000000001500000 + i*10000),
+			OOSpanFixedKey.Duration:               1500,
+			OOSpanFixedKey.SpanKind:               2,
+			OOSpanFixedKey.SpanStatus:             "UNSET",
+			"http_method":                         "GET",
+			"http_status_code":                    200,
+		}
+	}
+	return oo
+}
+
+func benchmarkConvertTrace(b *testing.B, spans int, pooled bool) {
+	gin.SetMode(gin.TestMode)
+	config.Set(config.Config{})
+	s := &JaegerService{}
+	oo := benchHits(spans)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if _, skipped, _ := s.transOOToJaegerModelTrace(ctx, oo); len(skipped) > 0 {
+		b.Fatalf("spans skipped: %v", skipped[0])
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trace, _, err := s.transOOToJaegerModelTrace(ctx, oo)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if pooled {
+			putSpanSlice(trace.Spans)
+		}
+	}
+}
+
+// BenchmarkConvertTracePooled returns the span slices to the pool like transOOToJaegerUI,
+// BenchmarkConvertTraceUnpooled leaves them to the garbage collector.
+func BenchmarkConvertTracePooled(b *testing.B)   { benchmarkConvertTrace(b, 2000, true) }
+func BenchmarkConvertTraceUnpooled(b *testing.B) { benchmarkConvertTrace(b, 2000, false) }
+
+func TestPutSpanSliceDropsLargeSlices(t *testing.T) {
+	spans := getSpanSlice()
+	for i := 0; i <= maxPooledSpans; i++ {
+		spans = append(spans, nil)
+	}
+	putSpanSlice(spans)
+	for i := 0; i < 10; i++ {
+		if c := cap(getSpanSlice()); c > maxPooledSpans {
+			t.Fatalf("the pool kept a slice of cap %d, more than %d", c, maxPooledSpans)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package jaeger_service
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/errors"
+	"openobserve-jaeger/internal/openobserve_service"
+	"openobserve-jaeger/internal/requestid"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const schemaCacheTTL = 5 * time.Minute
+
+// schemaCache keeps the columns of the spans stream, the tag filters on other keys can't be SQL conditions.
+type schemaCache struct {
+	mu      sync.Mutex
+	columns map[string]struct{}
+	expires time.Time
+}
+
+func (s *JaegerService) streamColumns(ctx *gin.Context) (map[string]struct{}, error) {
+	s.schema.mu.Lock()
+	defer s.schema.mu.Unlock()
+
+	if s.schema.columns != nil && time.Now().Before(s.schema.expires) {
+		return s.schema.columns, nil
+	}
+
+	names, err := s.ooservice.GetStreamColumns(ctx, openobserve_service.SearchTraceDefaultStream, "traces")
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		columns[name] = struct{}{}
+	}
+	s.schema.columns = columns
+	s.schema.expires = time.Now().Add(schemaCacheTTL)
+	return columns, nil
+}
+
+// splitPostFilterTags moves the tag filters on keys which are no columns of the stream out of q, they are
+// matched against the spans of up to openobserve.post_filter_max_traces candidate traces instead.
+// The returned query is q itself if all the keys are columns or the schema is unknown. The keys of the
+// OR groups must be columns, a group can't be split between the query and the post filter.
+func (s *JaegerService) splitPostFilterTags(ctx *gin.Context, q *TraceQueryParameters) (*TraceQueryParameters, []tagFilter, *JaegerStructuredError) {
+	if len(q.Tags) == 0 && len(q.TagGroups) == 0 {
+		return q, nil, nil
+	}
+
+	columns, err := s.streamColumns(ctx)
+	if err != nil {
+		requestid.Logf(ctx, "splitPostFilterTags schema err: %v", err)
+		return q, nil, nil
+	}
+
+	for _, group := range q.TagGroups {
+		for _, t := range group {
+			key := parseTagFilter(t.Key, t.Value).key
+			if _, ok := columns[tagColumn(key)]; !ok && t.Key != OOSpanFixedKey.Error {
+				e := structuredError(errors.BadRequest(fmt.Sprintf("tag %s of an OR group is not a column of the %s stream", key, openobserve_service.SearchTraceDefaultStream)))
+				return nil, nil, &e
+			}
+		}
+	}
+
+	tags := make(map[string]string, len(q.Tags))
+	filters := make([]tagFilter, 0)
+	missing := make([]string, 0)
+	for k, v := range q.Tags {
+		f := parseTagFilter(k, v)
+		if _, ok := columns[tagColumn(f.key)]; ok || k == OOSpanFixedKey.Error {
+			tags[k] = v
+			continue
+		}
+		filters = append(filters, f)
+		missing = append(missing, f.key)
+	}
+	if len(filters) == 0 {
+		return q, nil, nil
+	}
+
+	sort.Strings(missing)
+	maxTraces := config.Get().OpenObserve.PostFilterMaxTraces
+	if maxTraces <= 0 {
+		e := structuredError(errors.BadRequest(fmt.Sprintf("tags %s are not columns of the %s stream", strings.Join(missing, ", "), openobserve_service.SearchTraceDefaultStream)))
+		return nil, nil, &e
+	}
+
+	qq := *q
+	qq.Tags = tags
+	if qq.NumTraces < maxTraces {
+		qq.NumTraces = maxTraces
+	}
+	return &qq, filters, nil
+}
+
+// postFilterTraces keeps the traces with a span matching all the filters on its tags, process tags or
+// log fields, at most limit of them.
+func postFilterTraces(traces []*ui.Trace, filters []tagFilter, limit int) []*ui.Trace {
+	res := make([]*ui.Trace, 0, len(traces))
+	for _, trace := range traces {
+		if limit > 0 && len(res) >= limit {
+			break
+		}
+		if trace == nil {
+			continue
+		}
+		for i := range trace.Spans {
+			if spanMatches(&trace.Spans[i], trace.Processes, filters) {
+				res = append(res, trace)
+				break
+			}
+		}
+	}
+	return res
+}
+
+func spanMatches(span *ui.Span, processes map[ui.ProcessID]ui.Process, filters []tagFilter) bool {
+	values := make(map[string]string)
+	collect := func(kvs []ui.KeyValue) {
+		for _, kv := range kvs {
+			values[kv.Key] = fmt.Sprint(kv.Value)
+		}
+	}
+	if process, ok := processes[span.ProcessID]; ok {
+		collect(process.Tags)
+	}
+	for _, l := range span.Logs {
+		collect(l.Fields)
+	}
+	collect(span.Tags)
+
+	for _, f := range filters {
+		v, ok := values[f.key]
+		if !ok || !f.match(v) {
+			return false
+		}
+	}
+	return true
+}
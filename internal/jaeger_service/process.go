@@ -0,0 +1,47 @@
+package jaeger_service
+
+import (
+	"log"
+	"openobserve-jaeger/internal/config"
+	"regexp"
+	"sync"
+)
+
+var processTagsRegCache struct {
+	mu      sync.Mutex
+	pattern string
+	reg     *regexp.Regexp
+}
+
+// processTagsReg returns the pattern of the resource attribute columns emitted as process tags,
+// openobserve.process_tags_pattern if set, otherwise DbModelProcessTagsRulesReg.
+func processTagsReg() *regexp.Regexp {
+	pattern := config.Get().OpenObserve.ProcessTagsPattern
+	if pattern == "" {
+		return DbModelProcessTagsRulesReg
+	}
+
+	processTagsRegCache.mu.Lock()
+	defer processTagsRegCache.mu.Unlock()
+	if processTagsRegCache.reg == nil || processTagsRegCache.pattern != pattern {
+		reg, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("invalid openobserve.process_tags_pattern %q: %v", pattern, err)
+			return DbModelProcessTagsRulesReg
+		}
+		processTagsRegCache.pattern = pattern
+		processTagsRegCache.reg = reg
+	}
+	return processTagsRegCache.reg
+}
+
+// isSpanLevelKey reports whether the column belongs to the span itself and never to its process.
+func isSpanLevelKey(k string) bool {
+	switch k {
+	case OOSpanFixedKey.SpanKind, OOSpanFixedKey.SpanStatus, OOSpanFixedKey.Events,
+		OOSpanFixedKey.ReferenceParentSpanId, OOSpanFixedKey.ReferenceParentTraceId, OOSpanFixedKey.ReferenceRefType,
+		OOSpanFixedKey.Links, OOSpanFixedKey.TraceState:
+		return true
+	}
+	return false
+}
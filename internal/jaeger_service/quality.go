@@ -0,0 +1,96 @@
+package jaeger_service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"net/http"
+	"openobserve-jaeger/internal/openobserve_service"
+	"time"
+)
+
+// QualityReport counts the instrumentation problems of the spans of a service
+type QualityReport struct {
+	Service         string `json:"service"`
+	Spans           uint64 `json:"spans"`
+	ZeroDuration    uint64 `json:"zeroDuration"`    // duration <= 0
+	MissingSpanKind uint64 `json:"missingSpanKind"` // span kind unset or unspecified
+	FutureStart     uint64 `json:"futureStart"`     // started after the report time, the host clock is ahead
+	// MissingParent counts the spans whose parent span is not found in the range, e.g. the parent
+	// service doesn't propagate or export its spans
+	MissingParent uint64 `json:"missingParent"`
+	// StartBeforeParent counts the spans started before their parent span, the clocks are skewed
+	StartBeforeParent uint64 `json:"startBeforeParent"`
+}
+
+// qualitySpanSQL counts the problems visible on the spans themselves
+const qualitySpanSQL = "SELECT COUNT(*) AS spans, " +
+	"SUM(CASE WHEN %[1]s <= 0 THEN 1 ELSE 0 END) AS zero_duration, " +
+	"SUM(CASE WHEN %[2]s IS NULL OR %[2]s = '' OR %[2]s = '0' THEN 1 ELSE 0 END) AS missing_span_kind, " +
+	"SUM(CASE WHEN %[3]s > %[5]d THEN 1 ELSE 0 END) AS future_start " +
+	"FROM default WHERE %[4]s = %[6]s"
+
+// qualityParentSQL joins the spans with a parent reference to their parent span
+const qualityParentSQL = "SELECT SUM(CASE WHEN p.%[1]s IS NULL THEN 1 ELSE 0 END) AS missing_parent, " +
+	"SUM(CASE WHEN p.%[1]s IS NOT NULL AND c.%[2]s < p.%[2]s THEN 1 ELSE 0 END) AS start_before_parent " +
+	"FROM default AS c LEFT JOIN default AS p ON c.%[3]s = p.%[3]s AND c.%[4]s = p.%[1]s " +
+	"WHERE c.%[5]s = %[6]s AND c.%[4]s != ''"
+
+// GetQualityReport scans the spans of the service started in [start, end] for instrumentation problems.
+// The parent checks join the spans, if that query fails the span counts are still returned with a 206.
+func (s *JaegerService) GetQualityReport(ctx *gin.Context, service string, start, end time.Time) JaegerStructuredResponse {
+	jaegerResp := JaegerStructuredResponse{
+		Errors: make([]JaegerStructuredError, 0),
+	}
+
+	report := QualityReport{Service: service}
+	hit, err := s.qualityQuery(ctx, fmt.Sprintf(qualitySpanSQL, fieldColumn(OOSpanFixedKey.Duration),
+		fieldColumn(OOSpanFixedKey.SpanKind), fieldColumn(OOSpanFixedKey.StartTime), fieldColumn(OOSpanFixedKey.ServiceName),
+		time.Now().UnixNano(), sqlString(service)), start, end)
+	if err != nil {
+		jaegerResp.Errors = append(jaegerResp.Errors, structuredError(err))
+
+		return jaegerResp
+	}
+	report.Spans = cast.ToUint64(hit["spans"])
+	report.ZeroDuration = cast.ToUint64(hit["zero_duration"])
+	report.MissingSpanKind = cast.ToUint64(hit["missing_span_kind"])
+	report.FutureStart = cast.ToUint64(hit["future_start"])
+
+	hit, err = s.qualityQuery(ctx, fmt.Sprintf(qualityParentSQL, fieldColumn(OOSpanFixedKey.SpanID),
+		fieldColumn(OOSpanFixedKey.StartTime), fieldColumn(OOSpanFixedKey.TraceID), fieldColumn(OOSpanFixedKey.ReferenceParentSpanId),
+		fieldColumn(OOSpanFixedKey.ServiceName), sqlString(service)), start, end)
+	if err != nil {
+		jaegerResp.Errors = append(jaegerResp.Errors, JaegerStructuredError{
+			Code: http.StatusPartialContent,
+			Msg:  "parent checks failed: " + err.Error(),
+		})
+	} else {
+		report.MissingParent = cast.ToUint64(hit["missing_parent"])
+		report.StartBeforeParent = cast.ToUint64(hit["start_before_parent"])
+	}
+
+	jaegerResp.Data = report
+	jaegerResp.Total = int(report.Spans)
+	return jaegerResp
+}
+
+func (s *JaegerService) qualityQuery(ctx *gin.Context, sql string, start, end time.Time) (map[string]interface{}, error) {
+	qq := openobserve_service.OOSearchQuery{
+		Stream: openobserve_service.SearchTraceDefaultStream,
+		Query: openobserve_service.OOSearchQueryQuery{
+			StartTime: start.UnixMicro(),
+			EndTime:   end.UnixMicro(),
+			Sql:       base64.StdEncoding.EncodeToString([]byte(sql)),
+			Size:      -1,
+		},
+	}
+
+	ooresp, err := s.ooservice.SearchTraces(ctx, qq)
+	if err != nil {
+		return nil, err
+	}
+	// one row per cluster of openobserve.clusters
+	return sumCountHits(ooresp.Hits), nil
+}
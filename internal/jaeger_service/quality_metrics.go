@@ -0,0 +1,285 @@
+package jaeger_service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"github.com/spf13/cast"
+	"log"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/openobserve_service"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultQualityWindow     = 3600 // second
+	defaultQualityScanTraces = 20
+)
+
+// QualityMetrics is a service of the /api/quality-metrics response, the completeness of its spans in the
+// traces converted lately, by the searches and the background scan. The ratios are of the spans, or of
+// the traces for Truncated.
+type QualityMetrics struct {
+	Service   string `json:"service"`
+	Traces    uint64 `json:"traces"` // the traces rooted in the service
+	Spans     uint64 `json:"spans"`  // the dropped spans included
+	Truncated uint64 `json:"truncated"`
+	// MissingParent counts the spans referencing a parent span which is not in the trace
+	MissingParent uint64 `json:"missingParent"`
+	// ClockSkew counts the spans shorter than their parent but not within it, the spans the jaeger clock
+	// skew adjustment shifts into the parent
+	ClockSkew uint64 `json:"clockSkew"`
+	// Dropped counts the spans failing the conversion, missing from the returned traces
+	Dropped uint64 `json:"dropped"`
+
+	TruncatedRatio     float64 `json:"truncatedRatio"`
+	MissingParentRatio float64 `json:"missingParentRatio"`
+	ClockSkewRatio     float64 `json:"clockSkewRatio"`
+	DroppedRatio       float64 `json:"droppedRatio"`
+}
+
+// qualityRecorder counts the metrics of the converted traces in two generations of quality_metrics.window,
+// the report sums both so it always covers at least a full window.
+type qualityRecorder struct {
+	mu       sync.Mutex
+	started  time.Time // of current
+	current  map[string]*QualityMetrics
+	previous map[string]*QualityMetrics
+}
+
+var qualityMetrics = &qualityRecorder{}
+
+func qualityWindow() time.Duration {
+	window := config.Get().QualityMetrics.Window
+	if window <= 0 {
+		window = defaultQualityWindow
+	}
+	return time.Duration(window) * time.Second
+}
+
+// rotate moves to the next generation once the window passed, r.mu is held.
+func (r *qualityRecorder) rotate(now time.Time) {
+	window := qualityWindow()
+	switch {
+	case r.current == nil || now.Sub(r.started) >= 2*window:
+		r.previous = nil
+	case now.Sub(r.started) >= window:
+		r.previous = r.current
+	default:
+		return
+	}
+	r.current = make(map[string]*QualityMetrics)
+	r.started = now
+}
+
+// record counts trace, converted from hits; truncated is set when the trace had more than max_trace_spans.
+func (r *qualityRecorder) record(trace *ui.Trace, hits []map[string]interface{}, truncated bool) {
+	if trace == nil || len(hits) == 0 {
+		return
+	}
+
+	spans, _, roots := spanTree(trace)
+	counts := make(map[string]*QualityMetrics)
+	metrics := func(service string) *QualityMetrics {
+		m, ok := counts[service]
+		if !ok {
+			m = &QualityMetrics{Service: service}
+			counts[service] = m
+		}
+		return m
+	}
+
+	for _, hit := range hits {
+		metrics(cast.ToString(hit[OOSpanFixedKey.ServiceName])).Spans++
+	}
+	converted := make(map[string]uint64, len(counts))
+	for i := range trace.Spans {
+		span := &trace.Spans[i]
+		m := metrics(trace.Processes[span.ProcessID].ServiceName)
+		converted[m.Service]++
+
+		parentID := uiParentSpanID(span)
+		if parentID == "" || parentID == span.SpanID {
+			continue
+		}
+		parent, ok := spans[parentID]
+		if !ok {
+			m.MissingParent++
+			continue
+		}
+		if span.Duration <= parent.Duration &&
+			(span.StartTime < parent.StartTime || span.StartTime+span.Duration > parent.StartTime+parent.Duration) {
+			m.ClockSkew++
+		}
+	}
+	for service, m := range counts {
+		if m.Spans > converted[service] {
+			m.Dropped = m.Spans - converted[service]
+		}
+	}
+
+	// the earliest span without a parent, the spans with a missing parent only if there is none
+	var root *ui.Span
+	for _, span := range roots {
+		switch {
+		case root == nil:
+			root = span
+		case (uiParentSpanID(span) == "") != (uiParentSpanID(root) == ""):
+			if uiParentSpanID(span) == "" {
+				root = span
+			}
+		case span.StartTime < root.StartTime:
+			root = span
+		}
+	}
+	rootService := cast.ToString(hits[0][OOSpanFixedKey.ServiceName])
+	if root != nil {
+		rootService = trace.Processes[root.ProcessID].ServiceName
+	}
+	metrics(rootService).Traces++
+	if truncated {
+		metrics(rootService).Truncated++
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rotate(time.Now())
+	for service, m := range counts {
+		total, ok := r.current[service]
+		if !ok {
+			total = &QualityMetrics{Service: service}
+			r.current[service] = total
+		}
+		total.add(m)
+	}
+}
+
+// report returns the metrics of the services, of service only if not empty, sorted by service.
+func (r *qualityRecorder) report(service string) []*QualityMetrics {
+	r.mu.Lock()
+	r.rotate(time.Now())
+	merged := make(map[string]*QualityMetrics)
+	for _, generation := range []map[string]*QualityMetrics{r.previous, r.current} {
+		for name, m := range generation {
+			if service != "" && name != service {
+				continue
+			}
+			total, ok := merged[name]
+			if !ok {
+				total = &QualityMetrics{Service: name}
+				merged[name] = total
+			}
+			total.add(m)
+		}
+	}
+	r.mu.Unlock()
+
+	res := make([]*QualityMetrics, 0, len(merged))
+	for _, m := range merged {
+		m.TruncatedRatio = ratio(m.Truncated, m.Traces)
+		m.MissingParentRatio = ratio(m.MissingParent, m.Spans)
+		m.ClockSkewRatio = ratio(m.ClockSkew, m.Spans)
+		m.DroppedRatio = ratio(m.Dropped, m.Spans)
+		res = append(res, m)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Service < res[j].Service })
+	return res
+}
+
+func (m *QualityMetrics) add(o *QualityMetrics) {
+	m.Traces += o.Traces
+	m.Spans += o.Spans
+	m.Truncated += o.Truncated
+	m.MissingParent += o.MissingParent
+	m.ClockSkew += o.ClockSkew
+	m.Dropped += o.Dropped
+}
+
+func ratio(n, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// GetQualityMetrics returns the span completeness metrics of the services, or of service if not empty.
+func (s *JaegerService) GetQualityMetrics(ctx *gin.Context, service string) JaegerStructuredResponse {
+	metrics := qualityMetrics.report(service)
+	return JaegerStructuredResponse{
+		Data:   metrics,
+		Total:  len(metrics),
+		Errors: make([]JaegerStructuredError, 0),
+	}
+}
+
+// StartQualityScan fetches a sample of the traces of the last scan_interval every scan_interval, so the
+// quality metrics cover the services nobody searched lately.
+func (s *JaegerService) StartQualityScan(cfg config.QualityMetricsConfig) {
+	interval := time.Duration(cfg.ScanInterval) * time.Second
+	traces := cfg.ScanTraces
+	if traces <= 0 {
+		traces = defaultQualityScanTraces
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := s.qualityScan(interval, traces); err != nil {
+				log.Printf("quality metrics scan err: %v", err)
+			}
+		}
+	}()
+}
+
+const qualityScanSQL = "SELECT DISTINCT %s AS trace_id FROM default LIMIT %d"
+
+func (s *JaegerService) qualityScan(interval time.Duration, traces int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), interval)
+	defer cancel()
+
+	end := time.Now()
+	traceIDColumn, startTimeColumn := fieldColumn(OOSpanFixedKey.TraceID), fieldColumn(OOSpanFixedKey.StartTime)
+	qq := openobserve_service.OOSearchQuery{
+		Stream: openobserve_service.SearchTraceDefaultStream,
+		Query: openobserve_service.OOSearchQueryQuery{
+			StartTime: end.Add(-interval).UnixMicro(),
+			EndTime:   end.UnixMicro(),
+			Sql:       base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(qualityScanSQL, traceIDColumn, traces))),
+			Size:      int64(traces),
+		},
+		SearchType: openobserve_service.BackgroundSearchType,
+	}
+	ooresp, err := s.ooservice.SearchTraces(ctx, qq)
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(ooresp.Hits))
+	for _, hit := range ooresp.Hits {
+		if id := cast.ToString(hit["trace_id"]); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// the sampled traces may have started in the interval before
+	qq.Query.StartTime = end.Add(-2 * interval).UnixMicro()
+	qq.Query.Sql = base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(tracesByIdsSQL, openobserve_service.TraceIDsIn(traceIDColumn, ids), startTimeColumn)))
+	qq.Query.Size = -1
+	ooresp, err = s.ooservice.SearchTraces(ctx, qq)
+	if err != nil {
+		return err
+	}
+
+	// the conversion records the metrics, it uses the gin context for the request timings only
+	for id, resp := range splitTraceHits(ooresp.Hits) {
+		s.transOOToJaegerUI(&gin.Context{}, resp, id)
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+package jaeger_service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/errors"
+	"openobserve-jaeger/internal/openobserve_service"
+	"openobserve-jaeger/internal/requestid"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRawSearchRange = time.Hour
+	defaultRawSearchLimit = 1000
+)
+
+var (
+	rawSelectReg    = regexp.MustCompile(`(?is)^\s*SELECT\s`)
+	rawForbiddenReg = regexp.MustCompile(`(?i)\b(INSERT|UPDATE|DELETE|DROP|ALTER|CREATE|TRUNCATE|MERGE|GRANT|REVOKE|COPY|ATTACH|DETACH)\b`)
+)
+
+// RawSearch runs a SELECT statement of the power users on the raw_search.streams between start and end,
+// the hits are returned as OpenObserve returns them.
+func (s *JaegerService) RawSearch(ctx *gin.Context, sql string, start, end time.Time) JaegerStructuredResponse {
+	resp := JaegerStructuredResponse{
+		Data:   make([]map[string]interface{}, 0),
+		Errors: make([]JaegerStructuredError, 0),
+	}
+
+	cfg := config.Get().RawSearch
+	maxRange := defaultRawSearchRange
+	if cfg.MaxRange > 0 {
+		maxRange = time.Duration(cfg.MaxRange) * time.Hour
+	}
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if start.IsZero() {
+		start = end.Add(-maxRange)
+	}
+	if !end.After(start) || end.Sub(start) > maxRange {
+		resp.Errors = append(resp.Errors, structuredError(errors.BadRequest(fmt.Sprintf("the time range must be positive and at most %s", maxRange))))
+		return resp
+	}
+
+	limit := cfg.MaxLimit
+	if limit <= 0 {
+		limit = defaultRawSearchLimit
+	}
+	streams := cfg.Streams
+	if len(streams) == 0 {
+		streams = map[string]string{openobserve_service.SearchTraceDefaultStream: "traces"}
+	}
+	sql, streamType, err := checkRawSQL(sql, streams, limit)
+	if err != nil {
+		resp.Errors = append(resp.Errors, structuredError(err))
+		return resp
+	}
+	requestid.Logf(ctx, "raw search sql: %s", sql)
+
+	qq := openobserve_service.OOSearchQuery{
+		Query: openobserve_service.OOSearchQueryQuery{
+			StartTime: start.UnixMicro(),
+			EndTime:   end.UnixMicro(),
+			Sql:       base64.StdEncoding.EncodeToString([]byte(sql)),
+			Size:      int64(limit),
+		},
+	}
+	ooresp, err := s.ooservice.SearchStream(ctx, qq, streamType)
+	if err != nil {
+		resp.Errors = append(resp.Errors, structuredError(err))
+		return resp
+	}
+
+	resp.Data, resp.Total, resp.Limit = ooresp.Hits, len(ooresp.Hits), limit
+	return resp
+}
+
+// checkRawSQL accepts a single SELECT reading only the allowed streams, all of one type, and returns it with
+// its LIMIT capped at limit, or added if it has none, and the type of its streams. The rejections are
+// errors.BadRequest.
+func checkRawSQL(sql string, streams map[string]string, limit int) (string, string, error) {
+	sql = strings.TrimSpace(sql)
+	sql = strings.TrimSpace(strings.TrimSuffix(sql, ";"))
+	switch {
+	case sql == "":
+		return "", "", errors.BadRequest("the sql statement is empty")
+	case !rawSelectReg.MatchString(sql):
+		return "", "", errors.BadRequest("only SELECT statements are allowed")
+	case strings.Contains(sql, ";"):
+		return "", "", errors.BadRequest("only one statement is allowed")
+	case strings.Contains(sql, "--") || strings.Contains(sql, "/*"):
+		return "", "", errors.BadRequest("comments are not allowed")
+	}
+	if m := rawForbiddenReg.FindString(sql); m != "" {
+		return "", "", errors.BadRequest(fmt.Sprintf("%s is not allowed", strings.ToUpper(m)))
+	}
+
+	tokens, err := scanRawSQL(sql)
+	if err != nil {
+		return "", "", err
+	}
+	tables, err := rawTables(tokens)
+	if err != nil {
+		return "", "", err
+	}
+	if len(tables) == 0 {
+		return "", "", errors.BadRequest("the statement must read a stream, FROM <stream>")
+	}
+	streamType := ""
+	for _, stream := range tables {
+		t, ok := streams[stream]
+		if !ok {
+			return "", "", errors.BadRequest(fmt.Sprintf("stream %s is not allowed", stream))
+		}
+		if streamType != "" && t != streamType {
+			return "", "", errors.BadRequest("the streams of a statement must be of one type")
+		}
+		streamType = t
+	}
+
+	sql, err = capRawLimit(sql, tokens, limit)
+	if err != nil {
+		return "", "", err
+	}
+	return sql, streamType, nil
+}
+
+// rawToken is a token of a raw search statement: a word (keyword, name or number), a quoted identifier,
+// a string literal or a punctuation character, at [start, end) of the statement.
+type rawToken struct {
+	kind       byte // 'w' word, 'q' quoted identifier, 's' string literal, else the punctuation character
+	text       string
+	start, end int
+	depth      int // parentheses around the token
+}
+
+func isRawWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-' || c == '$'
+}
+
+// scanRawSQL splits sql into tokens, the quotes are matched so a string or a quoted name hides no keyword,
+// comma or parenthesis.
+func scanRawSQL(sql string) ([]rawToken, error) {
+	tokens := make([]rawToken, 0, 32)
+	depth := 0
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			// a doubled quote is the quote character itself
+			j := i + 1
+			var text strings.Builder
+			for {
+				k := strings.IndexByte(sql[j:], c)
+				if k < 0 {
+					return nil, errors.BadRequest(fmt.Sprintf("unterminated %c quote", c))
+				}
+				text.WriteString(sql[j : j+k])
+				j += k + 1
+				if j < len(sql) && sql[j] == c {
+					text.WriteByte(c)
+					j++
+					continue
+				}
+				break
+			}
+			kind := byte('s')
+			if c == '"' {
+				kind = 'q'
+			}
+			tokens = append(tokens, rawToken{kind: kind, text: text.String(), start: i, end: j, depth: depth})
+			i = j
+		case isRawWordByte(c):
+			j := i
+			for j < len(sql) && isRawWordByte(sql[j]) {
+				j++
+			}
+			tokens = append(tokens, rawToken{kind: 'w', text: sql[i:j], start: i, end: j, depth: depth})
+			i = j
+		default:
+			if c == ')' {
+				depth--
+			}
+			tokens = append(tokens, rawToken{kind: c, text: sql[i : i+1], start: i, end: i + 1, depth: depth})
+			if c == '(' {
+				depth++
+			}
+			i++
+		}
+	}
+	if depth != 0 {
+		return nil, errors.BadRequest("unbalanced parentheses")
+	}
+	return tokens, nil
+}
+
+// rawClauseEnds are the keywords ending the FROM clause of a SELECT
+var rawClauseEnds = map[string]bool{
+	"WHERE": true, "GROUP": true, "HAVING": true, "ORDER": true, "LIMIT": true, "OFFSET": true, "UNION": true,
+	"EXCEPT": true, "INTERSECT": true, "WINDOW": true, "QUALIFY": true,
+}
+
+// rawTables returns the stream of every table reference, the name after FROM or JOIN, of the statement and of
+// its subqueries. A comma in a FROM clause is rejected, the tables of a comma join are not checked otherwise,
+// as are the table functions.
+func rawTables(tokens []rawToken) ([]string, error) {
+	tables := make([]string, 0, 2)
+	// inFrom is whether the FROM clause of the SELECT at the depth is being read
+	inFrom := map[int]bool{}
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch t.kind {
+		case '(':
+			inFrom[t.depth+1] = false
+		case ',':
+			if inFrom[t.depth] {
+				return nil, errors.BadRequest("comma joins are not allowed, use JOIN")
+			}
+		case 'w':
+			keyword := strings.ToUpper(t.text)
+			switch {
+			case keyword == "FROM" || keyword == "JOIN":
+				if keyword == "FROM" {
+					inFrom[t.depth] = true
+				}
+				if i+1 == len(tokens) {
+					return nil, errors.BadRequest(keyword + " has no stream")
+				}
+				next := tokens[i+1]
+				switch next.kind {
+				case '(':
+					// a subquery has its own FROM checked, a table in parentheses, e.g. FROM (secret), is a table reference
+					j := i + 1
+					for j < len(tokens) && tokens[j].kind == '(' {
+						j++
+					}
+					if j == len(tokens) {
+						return nil, errors.BadRequest(keyword + " has no stream")
+					}
+					if first := strings.ToUpper(tokens[j].text); tokens[j].kind == 'w' && (first == "SELECT" || first == "WITH") {
+						continue
+					}
+					if tokens[j].kind != 'w' && tokens[j].kind != 'q' {
+						return nil, errors.BadRequest(keyword + " must be followed by a stream")
+					}
+					if j+1 < len(tokens) && tokens[j+1].kind == '(' {
+						return nil, errors.BadRequest(fmt.Sprintf("table function %s is not allowed", tokens[j].text))
+					}
+					tables = append(tables, tokens[j].text)
+				case 'w', 'q':
+					if i+2 < len(tokens) && tokens[i+2].kind == '(' {
+						return nil, errors.BadRequest(fmt.Sprintf("table function %s is not allowed", next.text))
+					}
+					tables = append(tables, next.text)
+					i++
+				default:
+					return nil, errors.BadRequest(keyword + " must be followed by a stream")
+				}
+			case rawClauseEnds[keyword]:
+				inFrom[t.depth] = false
+			}
+		}
+	}
+	return tables, nil
+}
+
+// capRawLimit caps the LIMIT of the statement at limit, or adds one. Only the LIMIT outside the parentheses
+// bounds the result, the one of a subquery is left as it is; a LIMIT is added before a trailing OFFSET.
+func capRawLimit(sql string, tokens []rawToken, limit int) (string, error) {
+	limitAt, offsetAt := -1, -1
+	for i, t := range tokens {
+		if t.kind != 'w' || t.depth != 0 {
+			continue
+		}
+		switch strings.ToUpper(t.text) {
+		case "LIMIT":
+			limitAt = i
+		case "OFFSET":
+			offsetAt = i
+		case "UNION", "EXCEPT", "INTERSECT":
+			// the LIMIT of a SELECT before the set operation bounds that SELECT alone
+			limitAt, offsetAt = -1, -1
+		}
+	}
+
+	if limitAt >= 0 {
+		if limitAt+1 == len(tokens) {
+			return "", errors.BadRequest("LIMIT must be followed by a number")
+		}
+		value := tokens[limitAt+1]
+		n, err := strconv.Atoi(value.text)
+		if value.kind != 'w' || (err != nil && !strings.EqualFold(value.text, "ALL")) {
+			return "", errors.BadRequest("LIMIT must be followed by a number")
+		}
+		if err != nil || n > limit {
+			return sql[:value.start] + strconv.Itoa(limit) + sql[value.end:], nil
+		}
+		return sql, nil
+	}
+	if offsetAt >= 0 {
+		at := tokens[offsetAt].start
+		return sql[:at] + fmt.Sprintf("LIMIT %d ", limit) + sql[at:], nil
+	}
+	return sql + fmt.Sprintf(" LIMIT %d", limit), nil
+}
@@ -0,0 +1,69 @@
+package jaeger_service
+
+import (
+	"testing"
+)
+
+func TestCheckRawSQL(t *testing.T) {
+	streams := map[string]string{"default": "traces", "app_logs": "logs", "app-logs": "logs"}
+	cases := []struct {
+		sql     string
+		want    string // the checked statement, empty for a rejection
+		wantTyp string
+	}{
+		{sql: "SELECT * FROM default", want: "SELECT * FROM default LIMIT 100", wantTyp: "traces"},
+		{sql: `SELECT * FROM "default" WHERE a = 'b';`, want: `SELECT * FROM "default" WHERE a = 'b' LIMIT 100`, wantTyp: "traces"},
+		{sql: "SELECT * FROM app-logs LIMIT 5", want: "SELECT * FROM app-logs LIMIT 5", wantTyp: "logs"},
+		{sql: "SELECT * FROM default LIMIT 5000", want: "SELECT * FROM default LIMIT 100"},
+		{sql: "SELECT * FROM default LIMIT 5 OFFSET 100000", want: "SELECT * FROM default LIMIT 5 OFFSET 100000"},
+		{sql: "SELECT * FROM default LIMIT 500 OFFSET 10", want: "SELECT * FROM default LIMIT 100 OFFSET 10"},
+		{sql: "SELECT * FROM default OFFSET 10", want: "SELECT * FROM default LIMIT 100 OFFSET 10"},
+		{sql: "SELECT * FROM default LIMIT ALL", want: "SELECT * FROM default LIMIT 100"},
+		{sql: "SELECT * FROM (SELECT * FROM default LIMIT 5000) t", want: "SELECT * FROM (SELECT * FROM default LIMIT 5000) t LIMIT 100"},
+		{sql: "SELECT * FROM ((SELECT * FROM default)) t", want: "SELECT * FROM ((SELECT * FROM default)) t LIMIT 100"},
+		{sql: "SELECT * FROM (default) d JOIN (default) p ON d.x = p.y", want: "SELECT * FROM (default) d JOIN (default) p ON d.x = p.y LIMIT 100", wantTyp: "traces"},
+		{sql: "SELECT a, count(b, c) FROM default d JOIN default p ON d.x = p.y GROUP BY a, b", want: "SELECT a, count(b, c) FROM default d JOIN default p ON d.x = p.y GROUP BY a, b LIMIT 100"},
+		{sql: "SELECT * FROM default WHERE x = 'FROM secret, other' AND y IN (1, 2)", want: "SELECT * FROM default WHERE x = 'FROM secret, other' AND y IN (1, 2) LIMIT 100"},
+
+		{sql: "SELECT * FROM default, secret"},
+		{sql: "SELECT * FROM default AS d, secret s"},
+		{sql: "SELECT * FROM (SELECT * FROM default) t, secret"},
+		{sql: "SELECT * FROM default d JOIN default p ON d.x = p.x, secret"},
+		{sql: "SELECT * FROM default JOIN secret ON a = b"},
+		{sql: "SELECT * FROM default UNION SELECT * FROM secret"},
+		{sql: "SELECT (SELECT max(a) FROM secret) FROM default"},
+		{sql: `SELECT * FROM "secret"`},
+		{sql: "SELECT * FROM default JOIN (secret) ON a = b"},
+		{sql: "SELECT * FROM default WHERE x IN (SELECT y FROM (secret))"},
+		{sql: "SELECT * FROM (secret) s"},
+		{sql: "SELECT * FROM ((secret)) s"},
+		{sql: "SELECT * FROM (default JOIN secret ON a = b)"},
+		{sql: "SELECT * FROM (read_csv('secret.csv')) t"},
+		{sql: "SELECT * FROM default JOIN app_logs ON a = b"},
+		{sql: "SELECT * FROM read_csv('x')"},
+		{sql: "SELECT * FROM default WHERE a = 'x"},
+		{sql: "SELECT * FROM default LIMIT x"},
+		{sql: "SELECT 1"},
+		{sql: "DELETE FROM default"},
+		{sql: "SELECT * FROM default; SELECT * FROM secret"},
+	}
+	for _, c := range cases {
+		got, typ, err := checkRawSQL(c.sql, streams, 100)
+		if c.want == "" {
+			if err == nil {
+				t.Errorf("%q: accepted as %q, want a rejection", c.sql, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", c.sql, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%q: got %q, want %q", c.sql, got, c.want)
+		}
+		if c.wantTyp != "" && typ != c.wantTyp {
+			t.Errorf("%q: stream type %q, want %q", c.sql, typ, c.wantTyp)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package jaeger_service
+
+import (
+	"encoding/json"
+	"github.com/jaegertracing/jaeger/plugin/storage/es/spanstore/dbmodel"
+	"github.com/spf13/cast"
+	"log"
+	"sort"
+	"strings"
+)
+
+// refType maps the stored ref type, e.g. CHILD_OF, childOf or FOLLOWSFROM, to the dbmodel one, def if it's unknown.
+func refType(stored string, def dbmodel.ReferenceType) dbmodel.ReferenceType {
+	switch strings.ToUpper(strings.ReplaceAll(stored, "_", "")) {
+	case "CHILDOF":
+		return dbmodel.ChildOf
+	case "FOLLOWSFROM":
+		return dbmodel.FollowsFrom
+	}
+	return def
+}
+
+// parseOOLinks decodes the JSON links column, a list of {"trace_id", "span_id", "ref_type"} objects.
+// The OTLP span links form {"context": {"traceId", "spanId"}} is accepted too, entries without a ref type
+// are FOLLOWS_FROM like the span links of the jaeger OTLP receiver.
+func parseOOLinks(v interface{}) []dbmodel.Reference {
+	refs := make([]dbmodel.Reference, 0)
+	raw := cast.ToString(v)
+	if raw == "" {
+		return refs
+	}
+
+	entries := make([]map[string]interface{}, 0)
+	if err := json.Unmarshal([]byte(raw), &entries); err != nil {
+		log.Printf("parseOOLinks err: %v", err)
+		return refs
+	}
+
+	for _, entry := range entries {
+		fields := entry
+		if context, ok := entry["context"].(map[string]interface{}); ok {
+			fields = context
+		}
+
+		traceID := firstString(fields, "trace_id", "traceId", "traceID")
+		spanID := firstString(fields, "span_id", "spanId", "spanID")
+		if traceID == "" || spanID == "" {
+			continue
+		}
+
+		refs = append(refs, dbmodel.Reference{
+			RefType: refType(firstString(entry, "ref_type", "refType"), dbmodel.FollowsFrom),
+			TraceID: dbmodel.TraceID(traceID),
+			SpanID:  dbmodel.SpanID(spanID),
+		})
+	}
+
+	return refs
+}
+
+// parseOOLinkAttributes returns the attributes of the entries of the JSON links column as span tags,
+// link.<key>, the references have no place for them. The attributes are a {"key": value} object or the
+// OTLP [{"key", "value": {"stringValue": ...}}] list.
+func parseOOLinkAttributes(v interface{}, arrayTags string) []dbmodel.KeyValue {
+	kvs := make([]dbmodel.KeyValue, 0)
+	raw := cast.ToString(v)
+	if raw == "" {
+		return kvs
+	}
+
+	entries := make([]map[string]interface{}, 0)
+	if err := json.Unmarshal([]byte(raw), &entries); err != nil {
+		return kvs
+	}
+
+	for _, entry := range entries {
+		switch attributes := entry["attributes"].(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(attributes))
+			for k := range attributes {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if attributes[k] != nil {
+					kvs = append(kvs, attributeTags(LinkTagPrefix+k, attributes[k], arrayTags)...)
+				}
+			}
+		case []interface{}:
+			for _, a := range attributes {
+				attribute := cast.ToStringMap(a)
+				k := cast.ToString(attribute["key"])
+				if k == "" {
+					continue
+				}
+				value := attribute["value"]
+				if typed, ok := value.(map[string]interface{}); ok {
+					value = nil
+					for _, t := range []string{"stringValue", "intValue", "doubleValue", "boolValue"} {
+						if v, ok := typed[t]; ok {
+							value = v
+							break
+						}
+					}
+				}
+				if value != nil {
+					kvs = append(kvs, attributeTags(LinkTagPrefix+k, value, arrayTags)...)
+				}
+			}
+		}
+	}
+
+	return kvs
+}
+
+func firstString(m map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		if v := cast.ToString(m[k]); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package jaeger_service
+
+import (
+	"hash/fnv"
+	"math"
+	"openobserve-jaeger/internal/config"
+)
+
+// maxSampledSearchLimit caps the trace list search of a sampled search, which fetches limit/ratio ids.
+const maxSampledSearchLimit = 10000
+
+// searchSampleRatio is the ratio of the trace ids kept, the sample param or openobserve.search_sample_ratio,
+// 0 means no sampling.
+func searchSampleRatio(q *TraceQueryParameters) float64 {
+	ratio := q.SampleRatio
+	if ratio == 0 {
+		ratio = config.Get().OpenObserve.SearchSampleRatio
+	}
+	if ratio <= 0 || ratio >= 1 {
+		return 0
+	}
+	return ratio
+}
+
+// sampledSearchLimit widens the trace list limit, so the sample is drawn from about limit/ratio traces.
+func sampledSearchLimit(limit int, ratio float64) int {
+	if limit <= 0 {
+		return limit
+	}
+	wide := int(math.Ceil(float64(limit) / ratio))
+	if wide > maxSampledSearchLimit {
+		wide = maxSampledSearchLimit
+	}
+	if wide < limit {
+		wide = limit
+	}
+	return wide
+}
+
+// sampleTraces keeps the traces whose id hash falls in ratio, the same trace is always kept or dropped so
+// repeated searches stay stable. The newest limit of the kept ones are returned.
+func sampleTraces(items []traceListItem, ratio float64, limit int) []traceListItem {
+	threshold := uint64(ratio * math.MaxUint32)
+	sampled := make([]traceListItem, 0, int(float64(len(items))*ratio)+1)
+	for _, item := range items {
+		h := fnv.New32a()
+		h.Write([]byte(item.TraceID))
+		if uint64(h.Sum32()) <= threshold {
+			sampled = append(sampled, item)
+		}
+	}
+
+	// a broad search never comes back empty because of the sampling
+	if len(sampled) == 0 && len(items) > 0 {
+		sampled = append(sampled, items[0])
+	}
+	if limit > 0 && len(sampled) > limit {
+		sampled = sampled[:limit]
+	}
+	return sampled
+}
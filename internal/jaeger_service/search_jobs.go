@@ -0,0 +1,189 @@
+package jaeger_service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/errors"
+	"sync"
+	"time"
+)
+
+const (
+	JobRunning  = "running"
+	JobDone     = "done"
+	JobCanceled = "canceled"
+
+	defaultSearchJobTTL     = 600 // second
+	defaultSearchJobTimeout = 600 // second
+	defaultSearchJobMax     = 100
+)
+
+// SearchJob is a trace search running in the background, set on the responses of /api/search-jobs.
+type SearchJob struct {
+	ID       string `json:"jobID"`
+	Status   string `json:"status"`
+	Created  int64  `json:"created"`            // unix milliseconds
+	Finished int64  `json:"finished,omitempty"` // unix milliseconds
+	Expires  int64  `json:"expires,omitempty"`  // unix milliseconds, the result is dropped then
+
+	owner  string
+	result JaegerStructuredResponse
+	cancel context.CancelFunc
+}
+
+// searchJobs keeps the jobs until search_jobs.ttl after they finished.
+type searchJobs struct {
+	mu   sync.Mutex
+	jobs map[string]*SearchJob
+}
+
+var jobs = &searchJobs{jobs: make(map[string]*SearchJob)}
+
+func searchJobsConfig() (ttl, timeout time.Duration, maxJobs int) {
+	cfg := config.Get().SearchJobs
+	ttl, timeout, maxJobs = defaultSearchJobTTL*time.Second, defaultSearchJobTimeout*time.Second, defaultSearchJobMax
+	if cfg.TTL > 0 {
+		ttl = time.Duration(cfg.TTL) * time.Second
+	}
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	if cfg.MaxJobs > 0 {
+		maxJobs = cfg.MaxJobs
+	}
+	return ttl, timeout, maxJobs
+}
+
+// view copies the public fields of the job, jobs.mu is held.
+func (job *SearchJob) view() *SearchJob {
+	return &SearchJob{
+		ID:       job.ID,
+		Status:   job.Status,
+		Created:  job.Created,
+		Finished: job.Finished,
+		Expires:  job.Expires,
+	}
+}
+
+// expire drops the finished jobs past their ttl, j.mu is held.
+func (j *searchJobs) expire(now time.Time) {
+	for id, job := range j.jobs {
+		if job.Expires > 0 && now.UnixMilli() >= job.Expires {
+			delete(j.jobs, id)
+		}
+	}
+}
+
+// SubmitSearchJob starts the search of q in the background and returns the job at once, owner is the
+// user the job results are returned to. The search keeps the request values of ctx, e.g. the org and
+// the fields, with its own search_jobs.timeout instead of the request deadline.
+func (s *JaegerService) SubmitSearchJob(ctx *gin.Context, q *TraceQueryParameters, owner string) JaegerStructuredResponse {
+	resp := JaegerStructuredResponse{
+		Data:   make([]string, 0),
+		Errors: make([]JaegerStructuredError, 0),
+	}
+	ttl, timeout, maxJobs := searchJobsConfig()
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		resp.Errors = append(resp.Errors, structuredError(err))
+		return resp
+	}
+	now := time.Now()
+	job := &SearchJob{
+		ID:      hex.EncodeToString(id),
+		Status:  JobRunning,
+		Created: now.UnixMilli(),
+		owner:   owner,
+	}
+
+	jobs.mu.Lock()
+	jobs.expire(now)
+	if len(jobs.jobs) >= maxJobs {
+		jobs.mu.Unlock()
+		resp.Errors = append(resp.Errors, structuredError(errors.Newf(http.StatusTooManyRequests, "%d search jobs are kept already, retry later or cancel some", maxJobs)))
+		return resp
+	}
+	jobCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	job.cancel = cancel
+	jobs.jobs[job.ID] = job
+	jobs.mu.Unlock()
+
+	bg := ctx.Copy()
+	bg.Request = ctx.Request.Clone(jobCtx)
+	go func() {
+		defer cancel()
+		result := s.FindTraces(bg, q)
+
+		jobs.mu.Lock()
+		defer jobs.mu.Unlock()
+		if job.Status != JobRunning {
+			return
+		}
+		if jobCtx.Err() == context.DeadlineExceeded && len(result.Errors) > 0 {
+			result.Errors = []JaegerStructuredError{structuredError(errors.BackendTimeout(fmt.Sprintf("the search job exceeded search_jobs.timeout of %s", timeout)))}
+		}
+		finished := time.Now()
+		job.Status, job.result = JobDone, result
+		job.Finished, job.Expires = finished.UnixMilli(), finished.Add(ttl).UnixMilli()
+	}()
+
+	resp.Job = job.view()
+	return resp
+}
+
+// GetSearchJob returns the job, with the search response once it is done. The jobs of another owner
+// are not found.
+func (s *JaegerService) GetSearchJob(ctx *gin.Context, id, owner string) JaegerStructuredResponse {
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+	jobs.expire(time.Now())
+
+	job, ok := jobs.jobs[id]
+	if !ok || job.owner != owner {
+		return JaegerStructuredResponse{
+			Errors: []JaegerStructuredError{structuredError(errors.NotFound("search job not found, it may have expired"))},
+		}
+	}
+
+	resp := JaegerStructuredResponse{
+		Data:   make([]string, 0),
+		Errors: make([]JaegerStructuredError, 0),
+	}
+	if job.Status == JobDone {
+		resp = job.result
+	}
+	resp.Job = job.view()
+	return resp
+}
+
+// CancelSearchJob cancels the search of a running job, the job is kept as canceled until its ttl.
+func (s *JaegerService) CancelSearchJob(ctx *gin.Context, id, owner string) JaegerStructuredResponse {
+	ttl, _, _ := searchJobsConfig()
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+
+	job, ok := jobs.jobs[id]
+	if !ok || job.owner != owner {
+		return JaegerStructuredResponse{
+			Errors: []JaegerStructuredError{structuredError(errors.NotFound("search job not found, it may have expired"))},
+		}
+	}
+	if job.Status == JobRunning {
+		job.cancel()
+		now := time.Now()
+		job.Status = JobCanceled
+		job.Finished, job.Expires = now.UnixMilli(), now.Add(ttl).UnixMilli()
+	}
+
+	return JaegerStructuredResponse{
+		Data:   make([]string, 0),
+		Errors: make([]JaegerStructuredError, 0),
+		Job:    job.view(),
+	}
+}
@@ -0,0 +1,34 @@
+package jaeger_service
+
+import (
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/openobserve_service"
+)
+
+// searchStrategyName is the search strategy of q, search_strategy if q names none.
+func searchStrategyName(q *TraceQueryParameters) string {
+	if q.Strategy != "" {
+		return q.Strategy
+	}
+	if name := config.Get().SearchStrategy; name != "" {
+		return name
+	}
+	return config.DefaultSearchStrategy
+}
+
+// searchStrategy resolves the search strategy of q, the parser rejects the unknown names so a strategy
+// dropped by a config reload falls back to the openobserve defaults.
+func searchStrategy(q *TraceQueryParameters) config.SearchStrategyConfig {
+	s, _ := config.Get().LookupSearchStrategy(searchStrategyName(q))
+	return s
+}
+
+// applySearchStrategy sets skip_wal and the search type of the strategy on a search, before applySearchDefaults.
+func applySearchStrategy(qq *openobserve_service.OOSearchQuery, s config.SearchStrategyConfig) {
+	if s.SkipWal {
+		qq.Query.SkipWal = true
+	}
+	if s.SearchType != "" {
+		qq.SearchType = s.SearchType
+	}
+}
@@ -0,0 +1,139 @@
+package jaeger_service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"openobserve-jaeger/internal/openobserve_service"
+	"sort"
+	"time"
+)
+
+// ServiceMap is the /api/servicemap response, the nodes and edges frames of the grafana node graph panel:
+// mainstat, secondarystat, arc__ and detail__ are the field names the panel reads.
+type ServiceMap struct {
+	Nodes []ServiceMapNode `json:"nodes"`
+	Edges []ServiceMapEdge `json:"edges"`
+}
+
+// ServiceMapNode is a service with the calls it received from the other services
+type ServiceMapNode struct {
+	ID         string  `json:"id"`
+	Title      string  `json:"title"`
+	CallCount  uint64  `json:"mainstat"`
+	ErrorRate  float64 `json:"secondarystat"` // errors of the received calls, 0 to 1
+	Success    float64 `json:"arc__success"`
+	Errors     float64 `json:"arc__errors"`
+	ErrorCount uint64  `json:"detail__errorCount"`
+}
+
+// ServiceMapEdge is the calls of the source service to the target service
+type ServiceMapEdge struct {
+	ID          string  `json:"id"`
+	Source      string  `json:"source"`
+	Target      string  `json:"target"`
+	CallCount   uint64  `json:"mainstat"`
+	P95Duration float64 `json:"secondarystat"` // unit: millisecond, of the target spans of the calls
+	ErrorCount  uint64  `json:"detail__errorCount"`
+	ErrorRate   float64 `json:"detail__errorRate"`
+}
+
+// serviceMapSQL is dependenciesSQL with the p95 duration of the child spans, a call is an error when the
+// child span status is ERROR.
+const serviceMapSQL = "SELECT p.%[1]s AS parent, c.%[1]s AS child, COUNT(*) AS call_count, " +
+	"SUM(CASE WHEN c.%[2]s = 'ERROR' THEN 1 ELSE 0 END) AS error_count, " +
+	"approx_percentile_cont(%[3]s, 0.95) AS p95_duration " +
+	"FROM default AS c JOIN default AS p ON c.%[4]s = p.%[4]s AND c.%[5]s = p.%[6]s " +
+	"WHERE p.%[1]s != c.%[1]s " +
+	"GROUP BY p.%[1]s, c.%[1]s"
+
+// GetServiceMap returns the service map of the spans started in [endTs-lookback, endTs]: the call, error
+// counts and p95 durations of the calls between the services, aggregated by OpenObserve.
+func (s *JaegerService) GetServiceMap(ctx *gin.Context, endTs time.Time, lookback time.Duration) JaegerStructuredResponse {
+	jaegerResp := JaegerStructuredResponse{
+		Data:   ServiceMap{Nodes: make([]ServiceMapNode, 0), Edges: make([]ServiceMapEdge, 0)},
+		Errors: make([]JaegerStructuredError, 0),
+	}
+
+	sql := fmt.Sprintf(serviceMapSQL, fieldColumn(OOSpanFixedKey.ServiceName), fieldColumn(OOSpanFixedKey.SpanStatus),
+		durationMicrosSQL(ctx, func(field string) string { return "c." + fieldColumn(field) }), fieldColumn(OOSpanFixedKey.TraceID),
+		fieldColumn(OOSpanFixedKey.ReferenceParentSpanId), fieldColumn(OOSpanFixedKey.SpanID))
+	qq := openobserve_service.OOSearchQuery{
+		Stream: openobserve_service.SearchTraceDefaultStream,
+		Query: openobserve_service.OOSearchQueryQuery{
+			StartTime: endTs.Add(-lookback).UnixMicro(),
+			EndTime:   endTs.UnixMicro(),
+			Sql:       base64.StdEncoding.EncodeToString([]byte(sql)),
+			Size:      -1,
+		},
+	}
+
+	ooresp, err := s.ooservice.SearchTraces(ctx, qq)
+	if err != nil {
+		jaegerResp.Errors = append(jaegerResp.Errors, structuredError(err))
+		return jaegerResp
+	}
+
+	edges := make([]ServiceMapEdge, 0, len(ooresp.Hits))
+	index := make(map[[2]string]int, len(ooresp.Hits))
+	for _, hit := range ooresp.Hits {
+		edge := ServiceMapEdge{
+			Source:      cast.ToString(hit["parent"]),
+			Target:      cast.ToString(hit["child"]),
+			CallCount:   cast.ToUint64(hit["call_count"]),
+			ErrorCount:  cast.ToUint64(hit["error_count"]),
+			P95Duration: cast.ToFloat64(hit["p95_duration"]) / 1e3,
+		}
+		// a pair is found once per cluster of openobserve.clusters, the highest p95 is the conservative estimate
+		key := [2]string{edge.Source, edge.Target}
+		if i, ok := index[key]; ok {
+			edges[i].CallCount += edge.CallCount
+			edges[i].ErrorCount += edge.ErrorCount
+			if edge.P95Duration > edges[i].P95Duration {
+				edges[i].P95Duration = edge.P95Duration
+			}
+			continue
+		}
+		edge.ID = edge.Source + "->" + edge.Target
+		index[key] = len(edges)
+		edges = append(edges, edge)
+	}
+
+	nodes := make(map[string]*ServiceMapNode)
+	node := func(service string) *ServiceMapNode {
+		n, ok := nodes[service]
+		if !ok {
+			n = &ServiceMapNode{ID: service, Title: service}
+			nodes[service] = n
+		}
+		return n
+	}
+	for i := range edges {
+		edge := &edges[i]
+		if edge.CallCount > 0 {
+			edge.ErrorRate = float64(edge.ErrorCount) / float64(edge.CallCount)
+		}
+		node(edge.Source)
+		target := node(edge.Target)
+		target.CallCount += edge.CallCount
+		target.ErrorCount += edge.ErrorCount
+	}
+	sort.Slice(edges, func(i, j int) bool { return edges[i].ID < edges[j].ID })
+
+	serviceMap := ServiceMap{Nodes: make([]ServiceMapNode, 0, len(nodes)), Edges: edges}
+	for _, n := range nodes {
+		// the services nobody calls have no errors, their arc is all success
+		n.Success = 1
+		if n.CallCount > 0 {
+			n.ErrorRate = float64(n.ErrorCount) / float64(n.CallCount)
+			n.Success, n.Errors = 1-n.ErrorRate, n.ErrorRate
+		}
+		serviceMap.Nodes = append(serviceMap.Nodes, *n)
+	}
+	sort.Slice(serviceMap.Nodes, func(i, j int) bool { return serviceMap.Nodes[i].ID < serviceMap.Nodes[j].ID })
+
+	jaegerResp.Data = serviceMap
+	jaegerResp.Total = len(edges)
+	return jaegerResp
+}
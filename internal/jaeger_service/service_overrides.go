@@ -0,0 +1,57 @@
+package jaeger_service
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/openobserve_service"
+	"time"
+)
+
+// applyServiceOverride applies the service_overrides of the service searched alone to q, the returned query
+// is q itself if there are none. The warnings tell about the cut search range. The stream of the service is
+// set in ctx for the openobserve queries, it is always searched on its spans, trace_list_index has no stream.
+func applyServiceOverride(ctx *gin.Context, q *TraceQueryParameters) (*TraceQueryParameters, []string) {
+	if len(q.ServiceName) != 1 || len(q.TraceIDs) > 0 || len(q.DownstreamOf) > 0 {
+		return q, nil
+	}
+	o, ok := config.Get().ServiceOverrides[q.ServiceName[0]]
+	if !ok {
+		return q, nil
+	}
+
+	var warnings []string
+	qq := *q
+	if o.Limit > 0 && (q.DefaultLimit || q.NumTraces <= 0 || q.NumTraces > o.Limit) {
+		qq.NumTraces = o.Limit
+	}
+	if lookback := time.Duration(o.MaxLookback) * time.Hour; lookback > 0 && q.StartTimeMax.Sub(q.StartTimeMin) > lookback {
+		qq.StartTimeMin = q.StartTimeMax.Add(-lookback)
+		warnings = append(warnings, fmt.Sprintf("the search range of %s was cut to the last %s by its service_overrides.max_lookback", q.ServiceName[0], lookback))
+	}
+	if len(o.Tags) > 0 {
+		qq.Tags = make(map[string]string, len(q.Tags)+len(o.Tags))
+		filtered := make(map[string]bool, len(q.Tags))
+		for k, v := range q.Tags {
+			qq.Tags[k] = v
+			filtered[parseTagFilter(k, v).key] = true
+		}
+		for k, v := range o.Tags {
+			if !filtered[parseTagFilter(k, v).key] {
+				qq.Tags[k] = v
+			}
+		}
+	}
+	if o.Stream != "" && ctx != nil {
+		ctx.Set(openobserve_service.TraceStreamContextKey, o.Stream)
+	}
+	return &qq, warnings
+}
+
+// traceStreamOverride is the traces stream ctx queries instead of the default stream, empty if none.
+func traceStreamOverride(ctx *gin.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	return ctx.GetString(openobserve_service.TraceStreamContextKey)
+}
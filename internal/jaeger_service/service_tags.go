@@ -0,0 +1,116 @@
+package jaeger_service
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"openobserve-jaeger/internal/errors"
+	"openobserve-jaeger/internal/openobserve_service"
+	"sort"
+	"strings"
+	"time"
+)
+
+// spanFixedKeys are the span fields which are no tags
+var spanFixedKeys = []string{
+	OOSpanFixedKey.ServiceName, OOSpanFixedKey.StartTime, OOSpanFixedKey.EndTime, OOSpanFixedKey.Timestamp,
+	OOSpanFixedKey.TraceID, OOSpanFixedKey.SpanID, OOSpanFixedKey.Duration, OOSpanFixedKey.Flags,
+	OOSpanFixedKey.OperationName, OOSpanFixedKey.SpanKind, OOSpanFixedKey.SpanStatus,
+	OOSpanFixedKey.ReferenceParentSpanId, OOSpanFixedKey.ReferenceParentTraceId, OOSpanFixedKey.ReferenceRefType,
+	OOSpanFixedKey.Links, OOSpanFixedKey.Events,
+}
+
+// GetServiceTags returns the tag keys having a value on the spans of service started in [start, end],
+// for the autocompletion of the tag filters.
+func (s *JaegerService) GetServiceTags(ctx *gin.Context, service string, start, end time.Time) JaegerStructuredResponse {
+	jaegerResp := JaegerStructuredResponse{
+		Data:   make([]string, 0),
+		Errors: make([]JaegerStructuredError, 0),
+	}
+
+	columns, err := s.streamColumns(ctx)
+	if err != nil {
+		jaegerResp.Errors = append(jaegerResp.Errors, structuredError(err))
+		return jaegerResp
+	}
+	fixed := make(map[string]struct{}, len(spanFixedKeys))
+	for _, k := range spanFixedKeys {
+		fixed[fieldColumn(k)] = struct{}{}
+	}
+	candidates := make([]string, 0, len(columns))
+	for column := range columns {
+		if _, ok := fixed[column]; !ok {
+			candidates = append(candidates, column)
+		}
+	}
+	if len(candidates) == 0 {
+		return jaegerResp
+	}
+	sort.Strings(candidates)
+
+	ooresp, err := s.ooservice.GetTraceValues(ctx, serviceValueQuery(strings.Join(candidates, ","), service, start, end, 1))
+	if err != nil {
+		jaegerResp.Errors = append(jaegerResp.Errors, structuredError(err))
+		return jaegerResp
+	}
+
+	keys := make([]string, 0, len(ooresp.Hits))
+	for _, hit := range ooresp.Hits {
+		if len(cast.ToSlice(hit["values"])) > 0 {
+			keys = append(keys, cast.ToString(hit["field"]))
+		}
+	}
+	sort.Strings(keys)
+
+	jaegerResp.Data = keys
+	jaegerResp.Total = len(keys)
+	return jaegerResp
+}
+
+// GetServiceTagValues returns the most frequent values of the tag key on the spans of service started in
+// [start, end], at most limit.
+func (s *JaegerService) GetServiceTagValues(ctx *gin.Context, service, key string, start, end time.Time, limit int) JaegerStructuredResponse {
+	jaegerResp := JaegerStructuredResponse{
+		Data:   make([]string, 0),
+		Errors: make([]JaegerStructuredError, 0),
+	}
+
+	column := fieldColumn(key)
+	if columns, err := s.streamColumns(ctx); err == nil {
+		if _, ok := columns[column]; !ok {
+			jaegerResp.Errors = append(jaegerResp.Errors, structuredError(errors.NotFound(fmt.Sprintf("tag %s is not a column of the %s stream", key, openobserve_service.SearchTraceDefaultStream))))
+			return jaegerResp
+		}
+	}
+
+	ooresp, err := s.ooservice.GetTraceValues(ctx, serviceValueQuery(column, service, start, end, limit))
+	if err != nil {
+		jaegerResp.Errors = append(jaegerResp.Errors, structuredError(err))
+		return jaegerResp
+	}
+
+	values := make([]string, 0, limit)
+	for _, hit := range ooresp.Hits {
+		for _, v := range cast.ToSlice(hit["values"]) {
+			if value := cast.ToString(cast.ToStringMap(v)["zo_sql_key"]); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+
+	jaegerResp.Data = values
+	jaegerResp.Total = len(values)
+	jaegerResp.Limit = limit
+	return jaegerResp
+}
+
+func serviceValueQuery(fields, service string, start, end time.Time, size int) openobserve_service.OOValueQuery {
+	return openobserve_service.OOValueQuery{
+		Fields:    fields,
+		Size:      int64(size),
+		StartTime: start.UnixMicro(),
+		EndTime:   end.UnixMicro(),
+		Type:      "traces",
+		Filter:    fieldColumn(OOSpanFixedKey.ServiceName) + "=" + service,
+	}
+}
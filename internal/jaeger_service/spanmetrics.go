@@ -0,0 +1,136 @@
+package jaeger_service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/spf13/cast"
+	"log"
+	"net/http"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/metrics"
+	"openobserve-jaeger/internal/openobserve_service"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSpanMetricsWindow = time.Minute
+	defaultSpanMetricsDelay  = 30 * time.Second
+)
+
+// defaultSpanMetricsBuckets are the latency buckets of the spanmetrics processor, unit: millisecond
+var defaultSpanMetricsBuckets = []float64{2, 4, 6, 8, 10, 50, 100, 200, 400, 800, 1000, 1400, 2000, 5000, 10000, 15000}
+
+// spanMetricsSQL aggregates the spans by service, operation, span kind and status, %[6]s are the cumulative
+// le_ bucket counts. The columns keep the span field names in the hits.
+const spanMetricsSQL = "SELECT %[1]s AS service_name, %[2]s AS operation_name, %[3]s AS span_kind, %[4]s AS span_status, " +
+	"COUNT(*) AS calls, SUM(%[5]s) AS duration_sum, %[6]s FROM default GROUP BY %[1]s, %[2]s, %[3]s, %[4]s"
+
+// SpanMetrics derives the calls_total and latency metrics of the OpenTelemetry spanmetrics processor,
+// which the jaeger-ui monitor tab reads, from the spans in OpenObserve. Every scrape aggregates the
+// spans ingested since the previous one in OpenObserve and adds them to the counters.
+type SpanMetrics struct {
+	s        *JaegerService
+	registry *metrics.Registry
+	calls    *metrics.Counter
+	latency  *metrics.Histogram
+	window   time.Duration
+	delay    time.Duration
+
+	mu   sync.Mutex
+	last time.Time // end of the time range aggregated so far
+}
+
+func (s *JaegerService) NewSpanMetrics(cfg config.SpanMetricsConfig) *SpanMetrics {
+	buckets := cfg.Buckets
+	if len(buckets) == 0 {
+		buckets = defaultSpanMetricsBuckets
+	}
+	m := &SpanMetrics{
+		s:        s,
+		registry: metrics.NewRegistry(),
+		window:   defaultSpanMetricsWindow,
+		delay:    defaultSpanMetricsDelay,
+	}
+	if cfg.Window > 0 {
+		m.window = time.Duration(cfg.Window) * time.Second
+	}
+	if cfg.Delay > 0 {
+		m.delay = time.Duration(cfg.Delay) * time.Second
+	}
+
+	labels := []string{"service_name", "operation", "span_kind", "status_code"}
+	m.calls = m.registry.NewCounter("calls_total", "Number of spans, derived from the OpenObserve spans.", labels...)
+	m.latency = m.registry.NewHistogram("latency", "Span duration in milliseconds, derived from the OpenObserve spans.", buckets, labels...)
+	return m
+}
+
+// Handler serves the span metrics, updated on every scrape.
+func (m *SpanMetrics) Handler() http.Handler {
+	return m.registry.Handler(m.collect)
+}
+
+func (m *SpanMetrics) collect(req *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	end := time.Now().Add(-m.delay)
+	start := m.last
+	if start.IsZero() || end.Sub(start) > m.window {
+		start = end.Add(-m.window)
+	}
+	if !end.After(start) {
+		return
+	}
+
+	bounds := m.latency.UpperBounds()
+	columns := make([]string, 0, len(bounds))
+	for i, bound := range bounds {
+		// the bounds are milliseconds, the scrape has no service override, the spans are in the default stream
+		le := durationCondition(nil, fieldColumn, "<=", time.Duration(bound*float64(time.Millisecond)))
+		columns = append(columns, fmt.Sprintf("SUM(CASE WHEN %s THEN 1 ELSE 0 END) AS le_%d", le, i))
+	}
+	sql := fmt.Sprintf(spanMetricsSQL, fieldColumn(OOSpanFixedKey.ServiceName), fieldColumn(OOSpanFixedKey.OperationName),
+		fieldColumn(OOSpanFixedKey.SpanKind), fieldColumn(OOSpanFixedKey.SpanStatus), durationMicrosSQL(nil, fieldColumn), strings.Join(columns, ", "))
+
+	qq := openobserve_service.OOSearchQuery{
+		Stream: openobserve_service.SearchTraceDefaultStream,
+		Query: openobserve_service.OOSearchQueryQuery{
+			StartTime: start.UnixMicro(),
+			EndTime:   end.UnixMicro(),
+			Sql:       base64.StdEncoding.EncodeToString([]byte(sql)),
+			Size:      -1,
+		},
+	}
+	ooresp, err := m.s.ooservice.SearchTraces(req.Context(), qq)
+	if err != nil {
+		// the range is aggregated by the next scrape
+		log.Printf("spanmetrics err: %v", err)
+		return
+	}
+
+	for _, hit := range ooresp.Hits {
+		labels := []string{
+			cast.ToString(hit[OOSpanFixedKey.ServiceName]),
+			cast.ToString(hit[OOSpanFixedKey.OperationName]),
+			"SPAN_KIND_" + strings.ToUpper(SpanKindName(cast.ToInt(hit[OOSpanFixedKey.SpanKind]))),
+			"STATUS_CODE_" + strings.ToUpper(cast.ToString(hit[OOSpanFixedKey.SpanStatus])),
+		}
+		calls := cast.ToUint64(hit["calls"])
+
+		counts := make([]uint64, len(bounds))
+		var below uint64
+		for i := range bounds {
+			cumulative := cast.ToUint64(hit[fmt.Sprintf("le_%d", i)])
+			if cumulative > below {
+				counts[i] = cumulative - below
+				below = cumulative
+			}
+		}
+
+		m.calls.Add(float64(calls), labels...)
+		m.latency.ObserveBuckets(counts, calls, cast.ToFloat64(hit["duration_sum"])/1e3, labels...)
+	}
+	m.last = end
+}
@@ -0,0 +1,134 @@
+package jaeger_service
+
+import (
+	"github.com/gin-gonic/gin"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"net/http"
+	"openobserve-jaeger/internal/openobserve_service"
+	"sort"
+)
+
+// TraceStats is the /api/traces/:id/stats response, the spans of the trace aggregated by service and by
+// service and operation, durations in microseconds
+type TraceStats struct {
+	TraceID    string        `json:"traceID"`
+	Spans      int           `json:"spans"`
+	Errors     int           `json:"errors"`
+	Duration   uint64        `json:"duration"` // first span start to last span end
+	Services   []*StatsGroup `json:"services"`
+	Operations []*StatsGroup `json:"operations"`
+}
+
+// StatsGroup aggregates the spans of a service, or of an operation of a service. The groups are sorted by
+// SelfDuration, the largest first.
+type StatsGroup struct {
+	Service      string `json:"service"`
+	Operation    string `json:"operation,omitempty"`
+	Spans        int    `json:"spans"`
+	Errors       int    `json:"errors"`
+	Duration     uint64 `json:"duration"`     // sum of the span durations
+	SelfDuration uint64 `json:"selfDuration"` // sum of the span durations not covered by their children
+}
+
+// GetTraceStats fetches the spans of q.TraceID and aggregates them by service and operation, so the
+// tooling can tell where the time of a request went without downloading the trace.
+func (s *JaegerService) GetTraceStats(ctx *gin.Context, q *openobserve_service.OOQuery) JaegerStructuredResponse {
+	resp := JaegerStructuredResponse{
+		Errors: make([]JaegerStructuredError, 0),
+	}
+
+	ooresp, jaegerErr := s.searchTraceSpans(ctx, q)
+	if jaegerErr != nil {
+		resp.Errors = append(resp.Errors, *jaegerErr)
+		return resp
+	}
+
+	trace, jaegerErr := s.transOOToJaegerUI(ctx, ooresp, q.TraceID)
+	if jaegerErr != nil {
+		// skipped spans are a warning, the stats of the others are still returned
+		if jaegerErr.Code == 0 {
+			jaegerErr.Code = http.StatusPartialContent
+		}
+		resp.Errors = append(resp.Errors, *jaegerErr)
+	}
+	if trace == nil {
+		return resp
+	}
+
+	resp.Data = traceStats(trace)
+	resp.Total = len(trace.Spans)
+	return resp
+}
+
+func traceStats(trace *ui.Trace) *TraceStats {
+	stats := &TraceStats{
+		TraceID: string(trace.TraceID),
+		Spans:   len(trace.Spans),
+	}
+
+	_, children, _ := spanTree(trace)
+	services := make(map[string]*StatsGroup)
+	operations := make(map[[2]string]*StatsGroup)
+	var start, end uint64
+	for i := range trace.Spans {
+		span := &trace.Spans[i]
+		if i == 0 || span.StartTime < start {
+			start = span.StartTime
+		}
+		if span.StartTime+span.Duration > end {
+			end = span.StartTime + span.Duration
+		}
+
+		service := trace.Processes[span.ProcessID].ServiceName
+		svc, ok := services[service]
+		if !ok {
+			svc = &StatsGroup{Service: service}
+			services[service] = svc
+		}
+		key := [2]string{service, span.OperationName}
+		op, ok := operations[key]
+		if !ok {
+			op = &StatsGroup{Service: service, Operation: span.OperationName}
+			operations[key] = op
+		}
+
+		self := selfTime(span, children[span.SpanID])
+		failed := uiSpanHasError(span)
+		for _, g := range []*StatsGroup{svc, op} {
+			g.Spans++
+			g.Duration += span.Duration
+			g.SelfDuration += self
+			if failed {
+				g.Errors++
+			}
+		}
+		if failed {
+			stats.Errors++
+		}
+	}
+	stats.Duration = end - start
+
+	stats.Services = make([]*StatsGroup, 0, len(services))
+	for _, g := range services {
+		stats.Services = append(stats.Services, g)
+	}
+	stats.Operations = make([]*StatsGroup, 0, len(operations))
+	for _, g := range operations {
+		stats.Operations = append(stats.Operations, g)
+	}
+	sortStatsGroups(stats.Services)
+	sortStatsGroups(stats.Operations)
+	return stats
+}
+
+func sortStatsGroups(groups []*StatsGroup) {
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].SelfDuration != groups[j].SelfDuration {
+			return groups[i].SelfDuration > groups[j].SelfDuration
+		}
+		if groups[i].Service != groups[j].Service {
+			return groups[i].Service < groups[j].Service
+		}
+		return groups[i].Operation < groups[j].Operation
+	})
+}
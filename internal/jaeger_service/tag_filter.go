@@ -0,0 +1,239 @@
+package jaeger_service
+
+import (
+	"fmt"
+	"openobserve-jaeger/internal/config"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var plainColumnReg = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// defaultErrorTagColumns are the error attributes of openobserve.error_tag_fallback, the error tag of the
+// OpenTracing convention
+var defaultErrorTagColumns = []string{"error"}
+
+// tagFilter is one parsed tag filter of a trace search
+type tagFilter struct {
+	key   string
+	op    string // =, !=, >=, <=, >, <
+	value string
+}
+
+// tagCondition translates one tag filter to a SQL condition. The operator is either the end of
+// the key, as the UI tags box splits "k>=500" into "k>" and "500", or the start of the value:
+//
+//	k=v        k = 'v'
+//	k!=v       k != 'v'
+//	k>=500     k >= 500 (k<=, k:>, k:< likewise, numbers are compared as numbers)
+//	k=~/api/%  k LIKE '/api/%' (* works as %, k!=~ is NOT LIKE)
+//	k=~^/api/  re_match(k, '^/api/') when the pattern has no wildcard
+//
+// w3c.tracestate is the trace_state column, w3c.tracestate.<vendor> and the link.<key> attributes are
+// matched in the trace_state and links columns, see embeddedTagSQL.
+func tagCondition(key, value string) string {
+	return parseTagFilter(key, value).sql()
+}
+
+// TagTerm is a key:value tag filter of an OR group, with the operators of tagCondition.
+type TagTerm struct {
+	Key   string
+	Value string
+}
+
+// tagTermCondition is the condition of a tag term, error:true matches the error spans, see errorCondition,
+// and the other values of the error tag match everything, so they have no condition.
+func tagTermCondition(t TagTerm, column func(string) string) string {
+	if t.Key == OOSpanFixedKey.Error {
+		if t.Value == "true" {
+			return errorCondition(column)
+		}
+		return ""
+	}
+	return tagCondition(t.Key, t.Value)
+}
+
+// errorCondition matches the spans with the ERROR status and, with openobserve.error_tag_fallback, the
+// spans of the SDKs which only set an error attribute: any of openobserve.error_tag_columns is 'true'.
+func errorCondition(column func(string) string) string {
+	status := column(OOSpanFixedKey.SpanStatus) + "='ERROR'"
+	cfg := config.Get().OpenObserve
+	if !cfg.ErrorTagFallback {
+		return status
+	}
+	columns := cfg.ErrorTagColumns
+	if len(columns) == 0 {
+		columns = defaultErrorTagColumns
+	}
+	conds := []string{status}
+	for _, c := range columns {
+		conds = append(conds, sqlColumn(c)+"='true'")
+	}
+	return "(" + strings.Join(conds, " OR ") + ")"
+}
+
+// tagGroupCondition ORs the conditions of the terms, the plain values of a single key become k IN ('a', 'b').
+// A term matching everything makes the whole group match everything, it has no condition then.
+func tagGroupCondition(group []TagTerm, column func(string) string) string {
+	if len(group) == 0 {
+		return ""
+	}
+	values := make([]string, 0, len(group))
+	for _, t := range group {
+		f := parseTagFilter(t.Key, t.Value)
+		if _, isPattern := f.pattern(); f.key != group[0].Key || f.op != "=" || isPattern || f.key == OOSpanFixedKey.Error || isEmbeddedTag(f.key) {
+			values = nil
+			break
+		}
+		values = append(values, sqlString(f.value))
+	}
+	if len(values) > 1 {
+		return fmt.Sprintf("%s IN (%s)", sqlColumn(tagColumn(group[0].Key)), strings.Join(values, ", "))
+	}
+
+	conds := make([]string, 0, len(group))
+	for _, t := range group {
+		c := tagTermCondition(t, column)
+		if c == "" {
+			return ""
+		}
+		conds = append(conds, c)
+	}
+	if len(conds) == 1 {
+		return conds[0]
+	}
+	return "(" + strings.Join(conds, " OR ") + ")"
+}
+
+func parseTagFilter(key, value string) tagFilter {
+	key = strings.TrimSpace(key)
+	value = strings.TrimSpace(value)
+
+	op := "="
+	switch {
+	case strings.HasSuffix(key, ">"):
+		key, op = strings.TrimSuffix(key, ">"), ">="
+	case strings.HasSuffix(key, "<"):
+		key, op = strings.TrimSuffix(key, "<"), "<="
+	case strings.HasSuffix(key, "!"):
+		key, op = strings.TrimSuffix(key, "!"), "!="
+	default:
+		for _, prefix := range []string{">=", "<=", "!=", ">", "<"} {
+			if strings.HasPrefix(value, prefix) {
+				op, value = prefix, strings.TrimSpace(strings.TrimPrefix(value, prefix))
+				break
+			}
+		}
+	}
+	return tagFilter{key: strings.TrimSpace(key), op: op, value: value}
+}
+
+// pattern returns the ~ pattern of the value, ok is false if it's no pattern filter.
+func (f tagFilter) pattern() (string, bool) {
+	if strings.HasPrefix(f.value, "~") && (f.op == "=" || f.op == "!=") {
+		return strings.TrimPrefix(f.value, "~"), true
+	}
+	return "", false
+}
+
+func (f tagFilter) sql() string {
+	if isEmbeddedTag(f.key) {
+		return f.embeddedTagSQL()
+	}
+	column := sqlColumn(tagColumn(f.key))
+
+	if pattern, ok := f.pattern(); ok {
+		if strings.ContainsAny(pattern, "%*") {
+			like := "LIKE"
+			if f.op == "!=" {
+				like = "NOT LIKE"
+			}
+			return fmt.Sprintf("%s %s %s", column, like, sqlString(strings.ReplaceAll(pattern, "*", "%")))
+		}
+
+		match := "re_match"
+		if f.op == "!=" {
+			match = "re_not_match"
+		}
+		return fmt.Sprintf("%s(%s, %s)", match, column, sqlString(pattern))
+	}
+
+	if f.op != "=" && f.op != "!=" {
+		if _, err := strconv.ParseFloat(f.value, 64); err == nil {
+			return fmt.Sprintf("%s %s %s", column, f.op, f.value)
+		}
+	}
+	if f.op == "=" {
+		return fmt.Sprintf("%s=%s", column, sqlString(f.value))
+	}
+	return fmt.Sprintf("%s %s %s", column, f.op, sqlString(f.value))
+}
+
+// match evaluates the filter against a tag value the way the SQL condition does.
+func (f tagFilter) match(v string) bool {
+	if pattern, ok := f.pattern(); ok {
+		var matched bool
+		if strings.ContainsAny(pattern, "%*") {
+			like := regexp.QuoteMeta(strings.ReplaceAll(pattern, "*", "%"))
+			matched, _ = regexp.MatchString("^"+strings.ReplaceAll(like, "%", ".*")+"$", v)
+		} else {
+			matched, _ = regexp.MatchString(pattern, v)
+		}
+		return matched == (f.op == "=")
+	}
+
+	switch f.op {
+	case "=":
+		return v == f.value
+	case "!=":
+		return v != f.value
+	}
+
+	cmp := strings.Compare(v, f.value)
+	if want, err := strconv.ParseFloat(f.value, 64); err == nil {
+		got, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return false
+		}
+		cmp = 0
+		if got < want {
+			cmp = -1
+		} else if got > want {
+			cmp = 1
+		}
+	}
+	switch f.op {
+	case ">=":
+		return cmp >= 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case "<":
+		return cmp < 0
+	}
+	return false
+}
+
+// sqlColumn returns key as is if it is a plain column name, double quoted otherwise.
+func sqlColumn(key string) string {
+	if plainColumnReg.MatchString(key) {
+		return key
+	}
+	return `"` + strings.ReplaceAll(key, `"`, `""`) + `"`
+}
+
+// sqlString quotes v as a SQL string literal.
+func sqlString(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+}
+
+// sqlStrings quotes values as the comma separated SQL string literals of an IN list.
+func sqlStrings(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = sqlString(v)
+	}
+	return strings.Join(quoted, ",")
+}
@@ -0,0 +1,24 @@
+package jaeger_service
+
+import (
+	"testing"
+)
+
+func TestBuildSQLCondQuotesNames(t *testing.T) {
+	column := func(field string) string { return field }
+	cases := []struct {
+		q    TraceQueryParameters
+		want string
+	}{
+		{q: TraceQueryParameters{ServiceName: []string{"o'brien"}}, want: "service_name ='o''brien'"},
+		{q: TraceQueryParameters{ServiceName: []string{"a", "b') OR ('1'='1"}}, want: "service_name IN('a','b'') OR (''1''=''1')"},
+		{q: TraceQueryParameters{OperationName: []string{"GET /x'"}}, want: "operation_name IN('GET /x''')"},
+	}
+	s := &JaegerService{}
+	for _, c := range cases {
+		cond := s.buildSQLCond(nil, &c.q, column)
+		if len(cond) != 1 || cond[0] != c.want {
+			t.Errorf("buildSQLCond(%+v) = %q, want [%q]", c.q, cond, c.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package jaeger_service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"github.com/spf13/cast"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/openobserve_service"
+	"time"
+)
+
+const defaultTailLookback = time.Minute
+
+// SpanTail follows the spans of an in-progress trace, each Next returns the spans which arrived since the
+// previous call. The query window moves with the newest span, lagging by tail.lookback since the spans are
+// written when they end, and the spans seen before are dropped.
+type SpanTail struct {
+	s       *JaegerService
+	traceID string
+	start   int64 // unix microseconds
+	seen    map[string]struct{}
+}
+
+// NewSpanTail starts following q.TraceID from the start of the q time range, or the trace_list_index time
+// range of the trace.
+func (s *JaegerService) NewSpanTail(ctx *gin.Context, q *openobserve_service.OOQuery) *SpanTail {
+	start, _ := s.traceDetailTimeRange(ctx, q)
+	return &SpanTail{
+		s:       s,
+		traceID: q.TraceID,
+		start:   start,
+		seen:    make(map[string]struct{}),
+	}
+}
+
+// Next fetches the new spans, the trace is nil if there are none.
+func (t *SpanTail) Next(ctx *gin.Context) (*ui.Trace, *JaegerStructuredError) {
+	sql := fmt.Sprintf("SELECT * FROM default WHERE %s ORDER BY %s", traceIDCond(fieldColumn(OOSpanFixedKey.TraceID), t.traceID), fieldColumn(OOSpanFixedKey.StartTime))
+	qq := openobserve_service.OOSearchQuery{
+		Stream: openobserve_service.SearchTraceDefaultStream,
+		Query: openobserve_service.OOSearchQueryQuery{
+			StartTime: t.start,
+			EndTime:   time.Now().UnixMicro(),
+			Sql:       base64.StdEncoding.EncodeToString([]byte(sql)),
+			Size:      -1,
+			SkipWal:   false, // the newest spans are still in the WAL
+		},
+	}
+
+	ooresp, err := t.s.ooservice.SearchTraces(openobserve_service.WithAffinity(ctx, t.traceID), qq)
+	if err != nil {
+		e := structuredError(err)
+		e.TraceID = ui.TraceID(t.traceID)
+		return nil, &e
+	}
+
+	fresh := &openobserve_service.OpenObserveResp{
+		Hits: make([]map[string]interface{}, 0, len(ooresp.Hits)),
+	}
+	var newest int64
+	for _, hit := range mapSpanHits(ooresp.Hits) {
+		if ts := cast.ToInt64(hit[OOSpanFixedKey.StartTime]) / 1e3; ts > newest {
+			newest = ts
+		}
+		spanID := cast.ToString(hit[OOSpanFixedKey.SpanID])
+		if _, ok := t.seen[spanID]; ok {
+			continue
+		}
+		t.seen[spanID] = struct{}{}
+		fresh.Hits = append(fresh.Hits, hit)
+	}
+
+	lookback := defaultTailLookback
+	if v := config.Get().Tail.Lookback; v > 0 {
+		lookback = time.Duration(v) * time.Second
+	}
+	if start := newest - lookback.Microseconds(); start > t.start {
+		t.start = start
+	}
+
+	if len(fresh.Hits) == 0 {
+		return nil, nil
+	}
+	return t.s.transOOToJaegerUI(ctx, fresh, t.traceID)
+}
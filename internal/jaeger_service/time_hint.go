@@ -0,0 +1,85 @@
+package jaeger_service
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/openobserve_service"
+	"openobserve-jaeger/internal/requestid"
+	"time"
+)
+
+// traceTimeHintPadding widens the trace_list_index time bounds for the spans which are written late
+// or carry skewed clocks.
+const traceTimeHintPadding = time.Minute
+
+// defaultTraceFetchPadding is the default openobserve.trace_fetch_padding, unit: second
+const defaultTraceFetchPadding = 60
+
+// spanFetchStart is the start of the span fetch of the traces a search found. The trace list only sees
+// the spans in the search window, so the spans of a trace which started shortly before it are fetched
+// from openobserve.trace_fetch_padding before the MIN(_timestamp) found for the trace when that's earlier.
+func spanFetchStart(start time.Time, items []traceListItem) time.Time {
+	padding := config.Get().OpenObserve.TraceFetchPadding
+	if padding < 0 {
+		return start
+	}
+	if padding == 0 {
+		padding = defaultTraceFetchPadding
+	}
+
+	for _, item := range items {
+		if item.Timestamp <= 0 {
+			continue
+		}
+		if t := time.UnixMicro(item.Timestamp).Add(-time.Duration(padding) * time.Second); t.Before(start) {
+			start = t
+		}
+	}
+	return start
+}
+
+// traceTimeHint looks the trace up in trace_list_index over openobserve.trace_lookup_range_time hours and
+// returns its start and end in unix microseconds, ok is false if it's disabled, failed or found nothing.
+func (s *JaegerService) traceTimeHint(ctx *gin.Context, traceID string) (int64, int64, bool) {
+	lookup := config.Get().OpenObserve.TraceLookupRange
+	if lookup <= 0 || traceID == "" {
+		return 0, 0, false
+	}
+
+	now := time.Now()
+	ooresp, err := s.ooservice.GetTraceServiceIndex(openobserve_service.WithAffinity(ctx, traceID), traceIDForms(traceID), now.Add(-time.Duration(lookup)*time.Hour).UnixMicro(), now.UnixMicro())
+	if err != nil {
+		requestid.Logf(ctx, "traceTimeHint trace_id: %s, err: %v", traceID, err)
+		return 0, 0, false
+	}
+
+	var start, end int64
+	for _, hit := range ooresp.Hits {
+		// start_time and end_time are unix nanoseconds
+		st := cast.ToInt64(hit[OOSpanFixedKey.StartTime]) / 1e3
+		et := cast.ToInt64(hit[OOSpanFixedKey.EndTime]) / 1e3
+		if st > 0 && (start == 0 || st < start) {
+			start = st
+		}
+		if et > end {
+			end = et
+		}
+	}
+	if start == 0 || end < start {
+		return 0, 0, false
+	}
+
+	padding := traceTimeHintPadding.Microseconds()
+	return start - padding, end + padding, true
+}
+
+// traceDetailTimeRange is the detailTimeRange of q, resolved from trace_list_index if q has no time range.
+func (s *JaegerService) traceDetailTimeRange(ctx *gin.Context, q *openobserve_service.OOQuery) (int64, int64) {
+	if q.StartTime.IsZero() && q.EndTime.IsZero() {
+		if start, end, ok := s.traceTimeHint(ctx, q.TraceID); ok {
+			return start, end
+		}
+	}
+	return detailTimeRange(q)
+}
@@ -0,0 +1,71 @@
+package jaeger_service
+
+import (
+	"github.com/gin-gonic/gin"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"github.com/spf13/cast"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/errors"
+	"openobserve-jaeger/internal/openobserve_service"
+	"sort"
+	"time"
+)
+
+// TraceExists looks q.TraceID up in trace_list_index alone, without fetching its spans. The data is the
+// trace id with its services and time bounds in the summaries, or a 404 error if the trace is not indexed
+// in the q time range, by default the last openobserve.trace_lookup_range_time hours, or the
+// default_trace_detail_search_range_time hours when the lookup is disabled.
+func (s *JaegerService) TraceExists(ctx *gin.Context, q *openobserve_service.OOQuery) JaegerStructuredResponse {
+	resp := JaegerStructuredResponse{
+		Data:   make([]string, 0),
+		Errors: make([]JaegerStructuredError, 0),
+	}
+
+	start, end := detailTimeRange(q)
+	if lookup := config.Get().OpenObserve.TraceLookupRange; lookup > 0 && q.StartTime.IsZero() && q.EndTime.IsZero() {
+		start = time.UnixMicro(end).Add(-time.Duration(lookup) * time.Hour).UnixMicro()
+	}
+
+	ooresp, err := s.ooservice.GetTraceServiceIndex(openobserve_service.WithAffinity(ctx, q.TraceID), traceIDForms(q.TraceID), start, end)
+	if err != nil {
+		e := structuredError(err)
+		e.TraceID = ui.TraceID(q.TraceID)
+		resp.Errors = append(resp.Errors, e)
+		return resp
+	}
+
+	var summary *TraceSummary
+	var traceEnd int64
+	for _, hit := range ooresp.Hits {
+		// start_time and end_time are unix nanoseconds
+		st := cast.ToInt64(hit[OOSpanFixedKey.StartTime]) / 1e3
+		et := cast.ToInt64(hit[OOSpanFixedKey.EndTime]) / 1e3
+		if summary == nil {
+			summary = &TraceSummary{Services: make([]string, 0, 1), StartTime: st}
+		}
+		if service := cast.ToString(hit[OOSpanFixedKey.ServiceName]); service != "" && !containsString(summary.Services, service) {
+			summary.Services = append(summary.Services, service)
+		}
+		if st > 0 && st < summary.StartTime {
+			summary.StartTime = st
+		}
+		if et > traceEnd {
+			traceEnd = et
+		}
+	}
+	if summary == nil {
+		e := structuredError(errors.NotFound("trace not found"))
+		e.TraceID = ui.TraceID(q.TraceID)
+		resp.Errors = append(resp.Errors, e)
+		return resp
+	}
+	if traceEnd > summary.StartTime {
+		summary.Duration = traceEnd - summary.StartTime
+	}
+	sort.Strings(summary.Services)
+
+	resp.Data = []string{q.TraceID}
+	resp.Total = 1
+	resp.Summaries = map[string]*TraceSummary{q.TraceID: summary}
+	return resp
+}
@@ -0,0 +1,78 @@
+package jaeger_service
+
+import (
+	"fmt"
+	"github.com/jaegertracing/jaeger/model"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"openobserve-jaeger/internal/errors"
+	"openobserve-jaeger/internal/openobserve_service"
+)
+
+// NormalizeTraceID parses a trace id of up to 32 hex digits and returns it the way jaeger prints it,
+// 16 digits for the 64-bit ids and 32 for the others, so the same trace has the same id whatever the client sent.
+// A malformed id is a BadRequest.
+func NormalizeTraceID(id string) (string, error) {
+	traceID, err := model.TraceIDFromString(id)
+	if err != nil {
+		return "", errors.BadRequest(fmt.Sprintf("TraceID %q is not a hex trace id of at most 32 characters", id))
+	}
+	return traceID.String(), nil
+}
+
+// traceIDForms lists the spellings a normalized trace id may be stored with: zero padded to 32 digits,
+// 16 digits for a 64-bit id, without the leading zeros of old jaeger clients, and the low 64 bits for the
+// tracers truncating 128-bit ids.
+func traceIDForms(id string) []string {
+	traceID, err := model.TraceIDFromString(id)
+	if err != nil {
+		return []string{id}
+	}
+
+	forms := []string{fmt.Sprintf("%016x%016x", traceID.High, traceID.Low)}
+	if traceID.High == 0 {
+		forms = append(forms, fmt.Sprintf("%016x", traceID.Low), fmt.Sprintf("%x", traceID.Low))
+	} else {
+		forms = append(forms, fmt.Sprintf("%x%016x", traceID.High, traceID.Low), fmt.Sprintf("%016x", traceID.Low))
+	}
+
+	unique := make([]string, 0, len(forms))
+	seen := make(map[string]struct{}, len(forms))
+	for _, form := range forms {
+		if _, ok := seen[form]; !ok {
+			seen[form] = struct{}{}
+			unique = append(unique, form)
+		}
+	}
+	return unique
+}
+
+// traceIDCond is the WHERE condition matching every form of the trace id in column.
+func traceIDCond(column, id string) string {
+	return openobserve_service.TraceIDsIn(column, traceIDForms(id))
+}
+
+// normalizeSpanTraceIDs gives the spans and references stored with another spelling of the trace id,
+// e.g. truncated to 64 bits, the id of the trace, so the UI shows one trace.
+func normalizeSpanTraceIDs(trace *ui.Trace, id string) {
+	if trace == nil || id == "" {
+		return
+	}
+	forms := make(map[ui.TraceID]struct{})
+	for _, form := range traceIDForms(id) {
+		forms[ui.TraceID(form)] = struct{}{}
+	}
+	normalize := func(traceID *ui.TraceID) {
+		if _, ok := forms[*traceID]; ok {
+			*traceID = ui.TraceID(id)
+		}
+	}
+
+	normalize(&trace.TraceID)
+	for i := range trace.Spans {
+		span := &trace.Spans[i]
+		normalize(&span.TraceID)
+		for j := range span.References {
+			normalize(&span.References[j].TraceID)
+		}
+	}
+}
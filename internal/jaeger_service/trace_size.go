@@ -0,0 +1,60 @@
+package jaeger_service
+
+import (
+	ui "github.com/jaegertracing/jaeger/model/json"
+)
+
+// rough JSON sizes of the fixed fields, the ids, times and the key names
+const (
+	spanBaseBytes      = 260
+	referenceBytes     = 90
+	logBaseBytes       = 40
+	keyValueBaseBytes  = 40
+	nonStringValueSize = 8
+)
+
+// TraceSize is the span count and the estimated JSON size of the traces of a response. It's sent ahead of the
+// spans, so a client can fall back to a summary or the paged spans instead of downloading a huge trace.
+type TraceSize struct {
+	Spans          int   `json:"spans"`
+	EstimatedBytes int64 `json:"estimatedBytes"`
+}
+
+// MeasureTraces estimates the size of traces without encoding them.
+func MeasureTraces(traces []*ui.Trace) TraceSize {
+	size := TraceSize{}
+	for _, trace := range traces {
+		if trace == nil {
+			continue
+		}
+		size.Spans += len(trace.Spans)
+		for i := range trace.Spans {
+			span := &trace.Spans[i]
+			size.EstimatedBytes += spanBaseBytes + int64(len(span.OperationName)) +
+				int64(len(span.References))*referenceBytes + keyValuesSize(span.Tags)
+			for _, log := range span.Logs {
+				size.EstimatedBytes += logBaseBytes + keyValuesSize(log.Fields)
+			}
+			for _, warning := range span.Warnings {
+				size.EstimatedBytes += int64(len(warning)) + 3
+			}
+		}
+		for id, process := range trace.Processes {
+			size.EstimatedBytes += keyValueBaseBytes + int64(len(id)+len(process.ServiceName)) + keyValuesSize(process.Tags)
+		}
+	}
+	return size
+}
+
+func keyValuesSize(kvs []ui.KeyValue) int64 {
+	var n int64
+	for _, kv := range kvs {
+		n += keyValueBaseBytes + int64(len(kv.Key))
+		if s, ok := kv.Value.(string); ok {
+			n += int64(len(s))
+		} else {
+			n += nonStringValueSize
+		}
+	}
+	return n
+}
@@ -0,0 +1,60 @@
+package jaeger_service
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The tags of the trace_state and links columns. OpenObserve flattens the dots of the attribute keys to
+// underscores, so no column is named like them.
+const (
+	// TraceStateTag is the W3C tracestate of the span, the key of the jaeger OTLP receiver,
+	// w3c.tracestate.<vendor> filters on the value of one list member
+	TraceStateTag = "w3c.tracestate"
+	// LinkTagPrefix prefixes the attributes of the span links, link.<key>
+	LinkTagPrefix = "link."
+)
+
+// tagColumn returns the column the tag filter on key is a condition on, key itself for the attribute columns.
+func tagColumn(key string) string {
+	switch {
+	case key == TraceStateTag || strings.HasPrefix(key, TraceStateTag+"."):
+		return fieldColumn(OOSpanFixedKey.TraceState)
+	case strings.HasPrefix(key, LinkTagPrefix):
+		return fieldColumn(OOSpanFixedKey.Links)
+	}
+	return key
+}
+
+// isEmbeddedTag reports whether key is a tracestate member or a link attribute, a part of its column.
+func isEmbeddedTag(key string) bool {
+	return strings.HasPrefix(key, TraceStateTag+".") || strings.HasPrefix(key, LinkTagPrefix)
+}
+
+// embeddedTagSQL is the condition of a filter on a tracestate member or a link attribute. Both are regex
+// matches on the column comparing the value as is, != negates the match and the other operators are taken as =.
+func (f tagFilter) embeddedTagSQL() string {
+	var reg string
+	if strings.HasPrefix(f.key, TraceStateTag+".") {
+		// list-member = key "=" value, the members are separated by commas and optional white space
+		vendor := strings.TrimPrefix(f.key, TraceStateTag+".")
+		reg = `(^|,)\s*` + regexp.QuoteMeta(vendor+"="+f.value) + `\s*(,|$)`
+	} else {
+		// "key":"value" in the JSON, a number or bool value is not quoted
+		reg = regexp.QuoteMeta(jsonString(strings.TrimPrefix(f.key, LinkTagPrefix))) + `\s*:\s*` +
+			`(` + regexp.QuoteMeta(jsonString(f.value)) + `|` + regexp.QuoteMeta(f.value) + `)\s*[,}]`
+	}
+
+	match := "re_match"
+	if f.op == "!=" {
+		match = "re_not_match"
+	}
+	return fmt.Sprintf("%s(%s, %s)", match, sqlColumn(tagColumn(f.key)), sqlString(reg))
+}
+
+func jsonString(v string) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
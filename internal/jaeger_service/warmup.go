@@ -0,0 +1,106 @@
+package jaeger_service
+
+import (
+	"context"
+	"github.com/spf13/cast"
+	"log"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/openobserve_service"
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultWarmupInterval = 300 // second
+
+// metadataCache holds the services and their operations refreshed by the warm-up worker,
+// the services and operations requests are served from it instead of the distinct_values stream.
+type metadataCache struct {
+	mu         sync.RWMutex
+	services   []interface{}
+	operations map[string][]interface{}
+}
+
+// getServices returns the cached services, ok is false before the first refresh.
+func (c *metadataCache) getServices() ([]interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.services, c.services != nil
+}
+
+// getOperations returns the cached operations of service, ok is false for a service unknown at the last refresh.
+func (c *metadataCache) getOperations(service string) ([]interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	operations, ok := c.operations[service]
+	return operations, ok
+}
+
+func (c *metadataCache) store(services []interface{}, operations map[string][]interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.services, c.operations = services, operations
+}
+
+// StartWarmup refreshes the services and operations every interval in the background, so the first UI load
+// is served from memory. A failed refresh keeps the previous lists, an unknown service is still queried.
+func (s *JaegerService) StartWarmup(cfg config.WarmupConfig) {
+	interval := time.Duration(cfg.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultWarmupInterval * time.Second
+	}
+
+	s.metadata = &metadataCache{}
+	go func() {
+		s.warmup(interval)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.warmup(interval)
+		}
+	}()
+}
+
+func (s *JaegerService) warmup(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ooresp, err := s.ooservice.GetServiceOperations(ctx, openobserve_service.BackgroundSearchType)
+	if err != nil {
+		log.Printf("warmup services and operations err: %v", err)
+		return
+	}
+
+	operations := make(map[string][]interface{})
+	for _, hit := range ooresp.Hits {
+		service := cast.ToString(hit[OOSpanFixedKey.ServiceName])
+		if service == "" {
+			continue
+		}
+		if _, ok := operations[service]; !ok {
+			operations[service] = make([]interface{}, 0)
+		}
+		if operation := cast.ToString(hit[OOSpanFixedKey.OperationName]); operation != "" {
+			operations[service] = append(operations[service], operation)
+		}
+	}
+
+	names := make([]string, 0, len(operations))
+	for service := range operations {
+		names = append(names, service)
+	}
+	sort.Strings(names)
+	services := make([]interface{}, 0, len(names))
+	for _, service := range names {
+		services = append(services, service)
+	}
+
+	s.metadata.store(services, operations)
+	log.Printf("warmup refreshed %d services", len(services))
+}
@@ -0,0 +1,165 @@
+package jaeger_service
+
+import (
+	"encoding/json"
+	"fmt"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ZipkinSpan is a span in the Zipkin v2 JSON format.
+type ZipkinSpan struct {
+	TraceID        string             `json:"traceId"`
+	ID             string             `json:"id"`
+	ParentID       string             `json:"parentId,omitempty"`
+	Name           string             `json:"name,omitempty"`
+	Kind           string             `json:"kind,omitempty"`
+	Timestamp      uint64             `json:"timestamp,omitempty"` // microseconds since Unix epoch
+	Duration       uint64             `json:"duration,omitempty"`  // microseconds
+	Debug          bool               `json:"debug,omitempty"`
+	LocalEndpoint  *ZipkinEndpoint    `json:"localEndpoint,omitempty"`
+	RemoteEndpoint *ZipkinEndpoint    `json:"remoteEndpoint,omitempty"`
+	Annotations    []ZipkinAnnotation `json:"annotations,omitempty"`
+	Tags           map[string]string  `json:"tags,omitempty"`
+}
+
+type ZipkinEndpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+	IPv4        string `json:"ipv4,omitempty"`
+	IPv6        string `json:"ipv6,omitempty"`
+	Port        int    `json:"port,omitempty"`
+}
+
+type ZipkinAnnotation struct {
+	Timestamp uint64 `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+var (
+	zipkinLocalIPKeys    = []string{"ip", "host.ip", "net.host.ip"}
+	zipkinLocalPortKeys  = []string{"net.host.port", "host.port"}
+	zipkinRemoteNameKeys = []string{"peer.service"}
+	zipkinRemoteIPKeys   = []string{"net.peer.ip", "peer.ipv4", "peer.ipv6", "net.sock.peer.addr"}
+	zipkinRemotePortKeys = []string{"net.peer.port", "peer.port", "net.sock.peer.port"}
+)
+
+// ToZipkinSpans converts a Jaeger UI trace to Zipkin v2 spans, the localEndpoint comes from the
+// process service name and tags, the remoteEndpoint from the peer.* / net.peer.* span tags.
+func ToZipkinSpans(trace *ui.Trace) []ZipkinSpan {
+	if trace == nil {
+		return nil
+	}
+
+	spans := make([]ZipkinSpan, 0, len(trace.Spans))
+	for i := range trace.Spans {
+		span := &trace.Spans[i]
+		zs := ZipkinSpan{
+			TraceID:   string(span.TraceID),
+			ID:        string(span.SpanID),
+			Name:      span.OperationName,
+			Timestamp: span.StartTime,
+			Duration:  span.Duration,
+			Debug:     span.Flags&2 != 0,
+			Tags:      make(map[string]string, len(span.Tags)),
+		}
+
+		for _, ref := range span.References {
+			if ref.RefType == ui.ChildOf && ref.TraceID == span.TraceID {
+				zs.ParentID = string(ref.SpanID)
+				break
+			}
+		}
+		if zs.ParentID == "" && span.ParentSpanID != "" {
+			zs.ParentID = string(span.ParentSpanID)
+		}
+
+		tags := make(map[string]string, len(span.Tags))
+		for _, kv := range span.Tags {
+			tags[kv.Key] = fmt.Sprint(kv.Value)
+		}
+		for k, v := range tags {
+			if k == "span.kind" {
+				if kind := strings.ToUpper(v); kind != "INTERNAL" && kind != "UNSPECIFIED" {
+					zs.Kind = kind
+				}
+				continue
+			}
+			zs.Tags[k] = v
+		}
+
+		process := span.Process
+		if p, ok := trace.Processes[span.ProcessID]; ok {
+			process = &p
+		}
+		if process != nil {
+			processTags := make(map[string]string, len(process.Tags))
+			for _, kv := range process.Tags {
+				processTags[kv.Key] = fmt.Sprint(kv.Value)
+			}
+			zs.LocalEndpoint = zipkinEndpoint(process.ServiceName, processTags, zipkinLocalIPKeys, zipkinLocalPortKeys)
+		}
+		zs.RemoteEndpoint = zipkinEndpoint(firstTag(tags, zipkinRemoteNameKeys), tags, zipkinRemoteIPKeys, zipkinRemotePortKeys)
+
+		for _, l := range span.Logs {
+			zs.Annotations = append(zs.Annotations, ZipkinAnnotation{
+				Timestamp: l.Timestamp,
+				Value:     zipkinAnnotationValue(l.Fields),
+			})
+		}
+
+		spans = append(spans, zs)
+	}
+
+	return spans
+}
+
+func zipkinEndpoint(serviceName string, tags map[string]string, ipKeys, portKeys []string) *ZipkinEndpoint {
+	endpoint := &ZipkinEndpoint{ServiceName: serviceName}
+	if ip := net.ParseIP(firstTag(tags, ipKeys)); ip != nil {
+		if ip.To4() != nil {
+			endpoint.IPv4 = ip.String()
+		} else {
+			endpoint.IPv6 = ip.String()
+		}
+	}
+	if port, err := strconv.Atoi(firstTag(tags, portKeys)); err == nil {
+		endpoint.Port = port
+	}
+
+	if *endpoint == (ZipkinEndpoint{}) {
+		return nil
+	}
+	return endpoint
+}
+
+func firstTag(tags map[string]string, keys []string) string {
+	for _, k := range keys {
+		if v, ok := tags[k]; ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// zipkinAnnotationValue uses the event name if it's the only field, otherwise all the fields as JSON.
+func zipkinAnnotationValue(fields []ui.KeyValue) string {
+	values := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		values[f.Key] = f.Value
+	}
+	if event, ok := values["event"]; ok && len(values) == 1 {
+		return fmt.Sprint(event)
+	}
+	if name, ok := values["name"]; ok && len(values) == 1 {
+		return fmt.Sprint(name)
+	}
+
+	// map keys are marshaled sorted, so the value is stable
+	data, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Sprint(values)
+	}
+	return string(data)
+}
@@ -0,0 +1,334 @@
+// Package metrics keeps the process metrics and writes them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const namespace = "oo_jaeger"
+
+type collector interface {
+	write(w io.Writer)
+}
+
+// Registry is a set of metrics served together
+type Registry struct {
+	prefix     string
+	mu         sync.Mutex
+	collectors map[string]collector
+}
+
+// NewRegistry returns a registry for metrics served apart from the process metrics, e.g. the derived
+// span metrics, their names are used as given.
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]collector),
+	}
+}
+
+// process is the registry of the package level constructors, served on /metrics
+var process = &Registry{
+	prefix:     namespace + "_",
+	collectors: make(map[string]collector),
+}
+
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.collectors[name]; ok {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.collectors[name] = c
+}
+
+// WriteText writes all the registered metrics sorted by name.
+func (r *Registry) WriteText(w io.Writer) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	r.mu.Unlock()
+
+	sort.Strings(names)
+	for _, name := range names {
+		r.mu.Lock()
+		c := r.collectors[name]
+		r.mu.Unlock()
+		c.write(w)
+	}
+}
+
+// Handler serves the metrics for Prometheus scrapes, before is called first if not nil, e.g. to update them.
+func (r *Registry) Handler(before func(req *http.Request)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if before != nil {
+			before(req)
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// WriteText writes all the process metrics sorted by name.
+func WriteText(w io.Writer) {
+	process.WriteText(w)
+}
+
+// Handler serves the process metrics for Prometheus scrapes.
+func Handler() http.Handler {
+	return process.Handler(nil)
+}
+
+// vec holds the values of a metric per label values.
+type vec struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	buckets     []uint64 // histogram only, not cumulative
+	count       uint64
+}
+
+func newVec(name, help, typ string, labelNames []string) *vec {
+	return &vec{
+		name:       name,
+		help:       help,
+		typ:        typ,
+		labelNames: labelNames,
+		values:     make(map[string]*series),
+	}
+}
+
+func (v *vec) series(labelValues []string) *series {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.values[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		v.values[key] = s
+	}
+	return s
+}
+
+func (v *vec) sortedSeries() []*series {
+	all := make([]*series, 0, len(v.values))
+	for _, s := range v.values {
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return strings.Join(all[i].labelValues, "\xff") < strings.Join(all[j].labelValues, "\xff")
+	})
+	return all
+}
+
+func (v *vec) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, escapeHelp(v.help), v.name, v.typ)
+}
+
+func labels(names, values []string, extra ...string) string {
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Counter is a monotonically increasing metric.
+type Counter struct {
+	*vec
+}
+
+// NewCounter registers a counter, the name gets the oo_jaeger_ prefix.
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	return process.NewCounter(name, help, labelNames...)
+}
+
+// NewCounter registers a counter in r.
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{vec: newVec(r.prefix+name, help, "counter", labelNames)}
+	r.register(c.name, c)
+	return c
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	c.mu.Lock()
+	c.series(labelValues).value += delta
+	c.mu.Unlock()
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeader(w)
+	for _, s := range c.sortedSeries() {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, labels(c.labelNames, s.labelValues), formatFloat(s.value))
+	}
+}
+
+// Gauge is a metric that can go up and down.
+type Gauge struct {
+	*vec
+}
+
+// NewGauge registers a gauge, the name gets the oo_jaeger_ prefix.
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	return process.NewGauge(name, help, labelNames...)
+}
+
+// NewGauge registers a gauge in r.
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{vec: newVec(r.prefix+name, help, "gauge", labelNames)}
+	r.register(g.name, g)
+	return g
+}
+
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.mu.Lock()
+	g.series(labelValues).value = value
+	g.mu.Unlock()
+}
+
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.mu.Lock()
+	g.series(labelValues).value += delta
+	g.mu.Unlock()
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.writeHeader(w)
+	for _, s := range g.sortedSeries() {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, labels(g.labelNames, s.labelValues), formatFloat(s.value))
+	}
+}
+
+// DefaultBuckets are latency buckets in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// Histogram counts observations in buckets.
+type Histogram struct {
+	*vec
+	upperBounds []float64
+}
+
+// NewHistogram registers a histogram, the name gets the oo_jaeger_ prefix.
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	return process.NewHistogram(name, help, buckets, labelNames...)
+}
+
+// NewHistogram registers a histogram in r.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	bounds := append([]float64(nil), buckets...)
+	sort.Float64s(bounds)
+	h := &Histogram{vec: newVec(r.prefix+name, help, "histogram", labelNames), upperBounds: bounds}
+	r.register(h.name, h)
+	return h
+}
+
+// UpperBounds returns the sorted bucket upper bounds.
+func (h *Histogram) UpperBounds() []float64 {
+	return append([]float64(nil), h.upperBounds...)
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.series(labelValues)
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(h.upperBounds))
+	}
+	for i, bound := range h.upperBounds {
+		if value <= bound {
+			s.buckets[i]++
+			break
+		}
+	}
+	s.count++
+	s.value += value
+}
+
+// ObserveBuckets adds observations counted elsewhere: counts[i] observations in bucket i of the
+// UpperBounds (not cumulative), count observations in total including the ones above all bounds.
+func (h *Histogram) ObserveBuckets(counts []uint64, count uint64, sum float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.series(labelValues)
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(h.upperBounds))
+	}
+	for i := range s.buckets {
+		if i < len(counts) {
+			s.buckets[i] += counts[i]
+		}
+	}
+	s.count += count
+	s.value += sum
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w)
+	for _, s := range h.sortedSeries() {
+		var cumulative uint64
+		for i, bound := range h.upperBounds {
+			cumulative += s.buckets[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labels(h.labelNames, s.labelValues, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, labels(h.labelNames, s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, labels(h.labelNames, s.labelValues), formatFloat(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, labels(h.labelNames, s.labelValues), s.count)
+	}
+}
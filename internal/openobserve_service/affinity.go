@@ -0,0 +1,79 @@
+package openobserve_service
+
+import (
+	"context"
+	"hash/fnv"
+	"openobserve-jaeger/internal/config"
+	"strings"
+	"sync"
+)
+
+type affinityKeyType struct{}
+
+// WithAffinity marks the queries of ctx as queries of one trace, they go to the same querier.
+func WithAffinity(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, affinityKeyType{}, traceID)
+}
+
+func affinityKey(ctx context.Context) string {
+	key, _ := ctx.Value(affinityKeyType{}).(string)
+	return key
+}
+
+// backend returns the base url of the query, the openobserve.queriers entry with the highest
+// rendezvous hash of the affinity key if any, so adding or removing a querier only moves its traces.
+func backend(cfg config.OpenObserveConfig, key string) string {
+	if key == "" || len(cfg.Queriers) == 0 {
+		return cfg.Addr
+	}
+
+	var best string
+	var bestScore uint64
+	for _, querier := range cfg.Queriers {
+		h := fnv.New64a()
+		h.Write([]byte(querier))
+		h.Write([]byte(key))
+		if score := h.Sum64(); best == "" || score > bestScore {
+			best, bestScore = querier, score
+		}
+	}
+	return strings.TrimRight(best, "/")
+}
+
+// BackendsContextKey is the key of the request *Backends, gin.Context resolves string keys set by ctx.Set in Value.
+const BackendsContextKey = "openobserve_backends"
+
+// Backends records the base urls the OpenObserve queries of a request went to, for the debug output.
+type Backends struct {
+	mu    sync.Mutex
+	addrs []string
+}
+
+func (b *Backends) add(addr string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, a := range b.addrs {
+		if a == addr {
+			return
+		}
+	}
+	b.addrs = append(b.addrs, addr)
+}
+
+// List returns a copy of the recorded base urls.
+func (b *Backends) List() []string {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.addrs...)
+}
+
+func backendsFromContext(ctx context.Context) *Backends {
+	b, _ := ctx.Value(BackendsContextKey).(*Backends)
+	return b
+}
@@ -0,0 +1,90 @@
+package openobserve_service
+
+import (
+	"context"
+	"openobserve-jaeger/internal/config"
+	"sync"
+	"time"
+)
+
+type clusterKeyType struct{}
+
+// withCluster sends the queries of ctx to c instead of openobserve.addr.
+func withCluster(ctx context.Context, c *config.ClusterConfig) context.Context {
+	return context.WithValue(ctx, clusterKeyType{}, c)
+}
+
+func clusterFromContext(ctx context.Context) *config.ClusterConfig {
+	c, _ := ctx.Value(clusterKeyType{}).(*config.ClusterConfig)
+	return c
+}
+
+// clusterAddr is the base url of the cluster of ctx, empty means openobserve.addr.
+func clusterAddr(ctx context.Context) string {
+	if c := clusterFromContext(ctx); c != nil {
+		return c.Addr
+	}
+	return ""
+}
+
+// clusterRange clips [start, end] in unix microseconds to the time range c owns at now,
+// ok is false if they don't overlap.
+func clusterRange(c config.ClusterConfig, now time.Time, start, end int64) (int64, int64, bool) {
+	if c.MaxAge > 0 {
+		if from := now.Add(-time.Duration(c.MaxAge) * time.Hour).UnixMicro(); start < from {
+			start = from
+		}
+	}
+	if c.MinAge > 0 {
+		if to := now.Add(-time.Duration(c.MinAge) * time.Hour).UnixMicro(); end > to {
+			end = to
+		}
+	}
+	return start, end, start < end
+}
+
+// searchClusters runs q concurrently on every cluster owning a part of its time range, clipped to that part,
+// and concatenates the hits in the order of the clusters. A trace or a group found in several clusters is
+// returned once per cluster, the callers merge them. Any failed cluster fails the search.
+func (oo *OpenObserveService) searchClusters(ctx context.Context, q OOSearchQuery, api string, clusters []config.ClusterConfig) (*OpenObserveResp, error) {
+	now := time.Now()
+	resps := make([]*OpenObserveResp, len(clusters))
+	errs := make([]error, len(clusters))
+
+	var wg sync.WaitGroup
+	for i := range clusters {
+		start, end, ok := clusterRange(clusters[i], now, q.Query.StartTime, q.Query.EndTime)
+		if !ok {
+			continue
+		}
+		sub := q
+		sub.Query.StartTime, sub.Query.EndTime = start, end
+
+		wg.Add(1)
+		go func(i int, sub OOSearchQuery) {
+			defer wg.Done()
+			resps[i], errs[i] = oo.searchOne(withCluster(ctx, &clusters[i]), sub, api)
+		}(i, sub)
+	}
+	wg.Wait()
+
+	merged := &OpenObserveResp{Hits: make([]map[string]interface{}, 0)}
+	for i, resp := range resps {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if resp == nil {
+			continue
+		}
+		merged.Hits = append(merged.Hits, resp.Hits...)
+		merged.Total += resp.Total
+		merged.Size += resp.Size
+		merged.ScanSize += resp.ScanSize
+		if resp.Took > merged.Took {
+			merged.Took = resp.Took
+			merged.TookDetail = resp.TookDetail
+			merged.TraceId = resp.TraceId
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,50 @@
+package openobserve_service
+
+import (
+	"context"
+	"net/http/httptrace"
+	"sync/atomic"
+)
+
+// the connection pool counters of the OpenObserve clients since the start
+var (
+	connsNew    int64
+	connsReused int64
+	connsIdle   int64 // returned to the idle pool
+)
+
+// ConnStats are the connection pool counters of the OpenObserve clients, for the debug endpoint.
+type ConnStats struct {
+	New    int64 `json:"new"`    // connections dialed
+	Reused int64 `json:"reused"` // requests served by a pooled connection
+	Idle   int64 `json:"idle"`   // connections put back into the idle pool after a request
+}
+
+// GetConnStats returns the current connection pool counters.
+func GetConnStats() ConnStats {
+	return ConnStats{
+		New:    atomic.LoadInt64(&connsNew),
+		Reused: atomic.LoadInt64(&connsReused),
+		Idle:   atomic.LoadInt64(&connsIdle),
+	}
+}
+
+var connTrace = &httptrace.ClientTrace{
+	GotConn: func(info httptrace.GotConnInfo) {
+		if info.Reused {
+			atomic.AddInt64(&connsReused, 1)
+		} else {
+			atomic.AddInt64(&connsNew, 1)
+		}
+	},
+	PutIdleConn: func(err error) {
+		if err == nil {
+			atomic.AddInt64(&connsIdle, 1)
+		}
+	},
+}
+
+// withConnStats counts the connections of the requests made with the returned context.
+func withConnStats(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, connTrace)
+}
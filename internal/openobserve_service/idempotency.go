@@ -0,0 +1,60 @@
+package openobserve_service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyKey identifies a search by its api and body, the same search always gets the same key.
+func idempotencyKey(api string, q OOSearchQuery) string {
+	body, _ := json.Marshal(q)
+	h := sha256.New()
+	h.Write([]byte(api))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+type inflightCall struct {
+	wg   sync.WaitGroup
+	resp *OpenObserveResp
+	err  error
+}
+
+// inflightGroup runs one search per key at a time, the callers arriving while it runs get its result.
+type inflightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*inflightCall
+}
+
+func newInflightGroup() *inflightGroup {
+	return &inflightGroup{
+		calls: make(map[string]*inflightCall),
+	}
+}
+
+func (g *inflightGroup) do(key string, fn func() (*OpenObserveResp, error)) (*OpenObserveResp, error) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.resp, c.err
+	}
+	c := &inflightCall{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	c.resp, c.err = fn()
+	c.wg.Done()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	return c.resp, c.err
+}
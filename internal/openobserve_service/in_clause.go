@@ -0,0 +1,39 @@
+package openobserve_service
+
+import (
+	"openobserve-jaeger/internal/config"
+	"strings"
+)
+
+// TraceIDsIn is the IN() condition of traceids on the trace id column, e.g. trace_id.
+func TraceIDsIn(column string, traceids []string) string {
+	return column + " IN('" + strings.Join(traceids, "','") + "')"
+}
+
+// sqlString quotes v as a SQL string literal.
+func sqlString(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+}
+
+// TraceIDChunks splits traceids so the TraceIDsIn of every chunk keeps a query of overhead bytes
+// without trace ids within openobserve.max_sql_length, OpenObserve rejects longer queries. It's one
+// chunk if the limit is not set or the ids fit.
+func TraceIDChunks(traceids []string, overhead int) [][]string {
+	limit := config.Get().OpenObserve.MaxSQLLength
+	if limit <= 0 {
+		return [][]string{traceids}
+	}
+
+	budget := limit - overhead
+	chunks := make([][]string, 0, 1)
+	start, size := 0, 0
+	for i, id := range traceids {
+		cost := len(id) + 3 // quotes and comma
+		if i > start && size+cost > budget {
+			chunks = append(chunks, traceids[start:i])
+			start, size = i, 0
+		}
+		size += cost
+	}
+	return append(chunks, traceids[start:])
+}
@@ -0,0 +1,96 @@
+package openobserve_service
+
+import (
+	"encoding/json"
+	"github.com/go-resty/resty/v2"
+	"net/http"
+	"openobserve-jaeger/internal/errors"
+	"strconv"
+	"strings"
+)
+
+// The metadata keys of the errors decoded from the OpenObserve error responses.
+const (
+	ErrorMetaStatus  = "openobserve_status"
+	ErrorMetaCode    = "openobserve_code"
+	ErrorMetaDetail  = "openobserve_error_detail"
+	ErrorMetaTraceID = "openobserve_trace_id"
+)
+
+// The error codes of the OpenObserve search errors, the code of the error JSON.
+const (
+	ooCodeSQLNotValid           = 20002
+	ooCodeFullTextFieldNotFound = 20004
+	ooCodeFieldNotFound         = 20005
+	ooCodeFunctionNotDefined    = 20006
+	ooCodeFieldNoCompatibleType = 20008
+)
+
+// ooErrorResponse is the error JSON of OpenObserve, code is the HTTP status or a search error code.
+type ooErrorResponse struct {
+	Code        int    `json:"code"`
+	Message     string `json:"message"`
+	Error       string `json:"error"`
+	ErrorDetail string `json:"error_detail"`
+	TraceID     string `json:"trace_id"`
+}
+
+// The messages of the errors without a search error code, matched in lower case.
+var (
+	ooFunctionErrors = []string{"function not found", "function not defined", "invalid function", "unknown function"}
+	ooSQLErrors      = []string{"sql parser error", "sql not valid", "sql not supported", "syntax error", "schema error", "field not found", "no field named"}
+)
+
+// responseError decodes the error response of OpenObserve: the message and error_detail make the message,
+// the status, code, detail and trace_id are kept as the metadata. The SQL errors are BadRequest, the auth
+// errors Unauthorized and the unknown functions Unprocessable, the others keep the status of the response.
+// A body which is no error JSON is the message as is.
+func responseError(resp *resty.Response) error {
+	var body ooErrorResponse
+	if err := json.Unmarshal(resp.Body(), &body); err != nil || (body.Message == "" && body.Error == "") {
+		return errors.New(int32(resp.StatusCode()), "status: "+resp.Status()+" Body: "+string(resp.Body()))
+	}
+
+	msg := body.Message
+	if msg == "" {
+		msg = body.Error
+	}
+	if body.ErrorDetail != "" && body.ErrorDetail != msg {
+		msg += ": " + body.ErrorDetail
+	}
+	msg = "openobserve: " + msg
+
+	var err *errors.Error
+	switch lower := strings.ToLower(msg); {
+	case resp.StatusCode() == http.StatusUnauthorized || resp.StatusCode() == http.StatusForbidden:
+		err = errors.Unauthorized(msg)
+	case body.Code == ooCodeFunctionNotDefined || containsAny(lower, ooFunctionErrors):
+		err = errors.Unprocessable(msg)
+	case body.Code == ooCodeSQLNotValid || body.Code == ooCodeFullTextFieldNotFound || body.Code == ooCodeFieldNotFound ||
+		body.Code == ooCodeFieldNoCompatibleType || containsAny(lower, ooSQLErrors):
+		err = errors.BadRequest(msg)
+	default:
+		err = errors.New(int32(resp.StatusCode()), msg)
+	}
+
+	metadata := map[string]string{ErrorMetaStatus: strconv.Itoa(resp.StatusCode())}
+	if body.Code != 0 {
+		metadata[ErrorMetaCode] = strconv.Itoa(body.Code)
+	}
+	if body.ErrorDetail != "" {
+		metadata[ErrorMetaDetail] = body.ErrorDetail
+	}
+	if body.TraceID != "" {
+		metadata[ErrorMetaTraceID] = body.TraceID
+	}
+	return err.WithMetadata(metadata)
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
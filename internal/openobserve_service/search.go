@@ -6,33 +6,55 @@ import (
 	"fmt"
 	"github.com/go-resty/resty/v2"
 	"github.com/prometheus/common/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"log"
 	"net/http"
 	"net/url"
+	"openobserve-jaeger/internal/audit"
+	"openobserve-jaeger/internal/codec"
 	"openobserve-jaeger/internal/config"
 	"openobserve-jaeger/internal/errors"
+	"openobserve-jaeger/internal/metrics"
+	"openobserve-jaeger/internal/requestid"
+	"openobserve-jaeger/internal/timing"
+	"openobserve-jaeger/internal/tracing"
 	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	searchTraceAPI           = "/api/default/_search?type=traces"
-	searchMetadataAPI        = "/api/default/_search?type=metadata"
+	streamsAPI               = "/api/%s/streams"
+	searchTraceAPI           = "/api/%s/_search?type=traces"
+	searchMetadataAPI        = "/api/%s/_search?type=metadata"
+	searchStreamAPI          = "/api/%s/_search?type="
+	ingestTracesAPI          = "/api/%s/v1/traces"
+	ingestLogsAPI            = "/api/%s/"
+	searchTraceValueAPI      = "/api/%s/default/_values"
+	DefaultOrg               = "default"
 	searchEncoding           = "base64"
 	SearchTraceDefaultStream = "default"
 	SearchTraceListStream    = "trace_list_index"
+	SearchDistinctStream     = "distinct_values_traces_default"
+	DefaultSqlMode           = "full"
 	BackgroundSearchType     = "reports"
 	UiSearchType             = "ui"
 )
 
+var (
+	searchRequests = metrics.NewCounter("openobserve_searches_total", "OpenObserve search requests by result (ok or error).", "result")
+	searchScanSize = metrics.NewCounter("openobserve_scan_size_total", "Sum of the scan_size (MB) reported by the OpenObserve searches.")
+)
+
+// OpenObserveService reads addr, auth and sizes from config.Get() on every request,
+// so they follow config reloads.
 type OpenObserveService struct {
-	client                   *resty.Client
-	addr                     string
-	traceindex_addr          []string
-	auth                     string
-	DefaultServicenameSize   int64
-	DefaultOperationnameSize int64
+	client          *resty.Client
+	traceindex_addr []string
+	background      *inflightGroup
 }
 
 type OpenObserveResp struct {
@@ -83,6 +105,8 @@ type OOSearchQuery struct {
 	Query      OOSearchQueryQuery     `json:"query"`
 	Encoding   string                 `json:"encoding"`
 	SearchType string                 `json:"search_type"`
+	// Stream selects the openobserve.streams search defaults, it is not sent
+	Stream string `json:"-"`
 }
 
 type OOSearchQueryQuery struct {
@@ -93,6 +117,7 @@ type OOSearchQueryQuery struct {
 	Size      int64  `json:"size"`
 	Sql       string `json:"sql"`
 	SkipWal   bool   `json:"skip_wal"`
+	Timeout   int64  `json:"timeout,omitempty"` // unit: second, OpenObserve cancels the query after it
 }
 
 type OOMetricsPromQuery struct {
@@ -157,31 +182,72 @@ type HttpClientOption struct {
 }
 
 func NewOpenObserveService() *OpenObserveService {
+	client := resty.New().SetJSONMarshaler(codec.Marshal).SetJSONUnmarshaler(codec.Unmarshal)
+	tlsConfig, err := config.Get().OpenObserve.TLS.ClientTLS()
+	if err != nil {
+		// config.Validate loads the same files, only a file changed since then gets here
+		log.Printf("openobserve tls config ignored: %v", err)
+	} else if tlsConfig != nil {
+		client.SetTLSClientConfig(tlsConfig)
+	}
+
 	return &OpenObserveService{
-		client:                   resty.New(),
-		addr:                     config.Cfg.OpenObserve.Addr,
-		auth:                     config.Cfg.OpenObserve.Auth,
-		DefaultServicenameSize:   config.Cfg.OpenObserve.DefaultServiceNameSize,
-		DefaultOperationnameSize: config.Cfg.OpenObserve.DefaultOperationNameSize,
+		client:     client,
+		background: newInflightGroup(),
 	}
 }
 
 func (oo *OpenObserveService) SearchTraces(ctx context.Context, q OOSearchQuery) (*OpenObserveResp, error) {
-	return oo.Search(ctx, q, searchTraceAPI)
+	return oo.Search(ctx, q, oo.orgAPI(ctx, searchTraceAPI))
+}
+
+// SearchStream searches the streams of streamType, traces, logs or metadata.
+func (oo *OpenObserveService) SearchStream(ctx context.Context, q OOSearchQuery, streamType string) (*OpenObserveResp, error) {
+	return oo.Search(ctx, q, oo.orgAPI(ctx, searchStreamAPI)+url.QueryEscape(streamType))
 }
 
 func (oo *OpenObserveService) SearchMeatadata(ctx context.Context, q OOSearchQuery) (*OpenObserveResp, error) {
-	return oo.Search(ctx, q, searchMetadataAPI)
+	return oo.Search(ctx, q, oo.orgAPI(ctx, searchMetadataAPI))
 }
 
-func (oo *OpenObserveService) Search(ctx context.Context, q OOSearchQuery, api string) (*OpenObserveResp, error) {
-	var reqOpt HttpClientOption
-	reqOpt.Header = map[string]string{
-		"Content-Type":  "application/json",
-		"Authorization": "Basic " + oo.auth,
+// OrgContextKey is the key of the org overriding openobserve.org for the queries of a request,
+// gin.Context resolves string keys set by ctx.Set in Value.
+const OrgContextKey = "openobserve_org"
+
+// orgAPI fills the org segment of an api path template
+func (oo *OpenObserveService) orgAPI(ctx context.Context, api string) string {
+	return fmt.Sprintf(api, url.PathEscape(oo.orgName(ctx)))
+}
+
+func (oo *OpenObserveService) orgName(ctx context.Context) string {
+	if org, _ := ctx.Value(OrgContextKey).(string); org != "" {
+		return org
 	}
-	reqOpt.Method = "POST"
-	reqOpt.Api = api
+	org := config.Get().OpenObserve.Org
+	if len(org) == 0 {
+		org = DefaultOrg
+	}
+	return org
+}
+
+// request starts an OpenObserve request with the auth, user agent and custom headers of the config,
+// the auth of the cluster of ctx if it has its own.
+func (oo *OpenObserveService) request(ctx context.Context) *resty.Request {
+	cfg := config.Get().OpenObserve
+	auth := cfg.Auth
+	if c := clusterFromContext(ctx); c != nil && c.Auth != "" {
+		auth = c.Auth
+	}
+	r := oo.client.R().SetContext(withConnStats(ctx)).
+		SetHeaders(cfg.Headers).
+		SetHeader("Authorization", "Basic "+auth)
+	if cfg.UserAgent != "" {
+		r.SetHeader("User-Agent", cfg.UserAgent)
+	}
+	return r
+}
+
+func (oo *OpenObserveService) Search(ctx context.Context, q OOSearchQuery, api string) (*OpenObserveResp, error) {
 	if len(q.Encoding) == 0 {
 		q.Encoding = searchEncoding
 	}
@@ -189,37 +255,134 @@ func (oo *OpenObserveService) Search(ctx context.Context, q OOSearchQuery, api s
 		q.Aggs = make(map[string]interface{})
 	}
 
-	if q.SearchType == BackgroundSearchType {
-		reqOpt.Query = "search_type=" + BackgroundSearchType
-	} else if q.SearchType == "" {
-		q.SearchType = UiSearchType
-		reqOpt.Query = "search_type=" + UiSearchType
+	applyTraceStream(ctx, &q)
+	applyStreamDefaults(&q)
+	if clusters := config.Get().OpenObserve.Clusters; len(clusters) > 0 && clusterFromContext(ctx) == nil {
+		return oo.searchClusters(ctx, q, api, clusters)
+	}
+	return oo.searchOne(ctx, q, api)
+}
+
+// searchOne runs the prepared search q on one cluster.
+func (oo *OpenObserveService) searchOne(ctx context.Context, q OOSearchQuery, api string) (*OpenObserveResp, error) {
+	if q.SearchType != BackgroundSearchType {
+		return oo.send(ctx, q, api, "")
+	}
+
+	// identical background searches, e.g. a retried request, share one heavy job in OpenObserve
+	key := idempotencyKey(clusterAddr(ctx)+api, q)
+	return oo.background.do(key, func() (*OpenObserveResp, error) {
+		return oo.send(ctx, q, api, key)
+	})
+}
+
+// send posts the prepared search q, a non-empty idempotencyKey is sent as the Idempotency-Key header.
+func (oo *OpenObserveService) send(ctx context.Context, q OOSearchQuery, api, idempotencyKey string) (*OpenObserveResp, error) {
+	var reqOpt HttpClientOption
+	reqOpt.Header = map[string]string{
+		"Content-Type": "application/json",
+	}
+	if idempotencyKey != "" {
+		reqOpt.Header[idempotencyKeyHeader] = idempotencyKey
+	}
+	reqOpt.Method = "POST"
+	reqOpt.Api = api
+	reqOpt.Query = "search_type=" + q.SearchType
+
+	// the remaining deadline budget of the request, so OpenObserve stops the query the client gave up on
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, errors.BackendTimeout("deadline budget exceeded before the openobserve query")
+		}
+		q.Query.Timeout = int64((remaining + time.Second - 1) / time.Second)
 	}
 
 	reqOpt.Body = q
 	reqOpt.Result = OpenObserveResp{}
 
+	ctx, span := tracing.Tracer().Start(ctx, "openobserve "+strings.SplitN(api, "?", 2)[0],
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("openobserve.api", api),
+			attribute.String("openobserve.stream", q.Stream),
+			attribute.String("openobserve.search_type", q.SearchType),
+			attribute.Int64("openobserve.start_time", q.Query.StartTime),
+			attribute.Int64("openobserve.end_time", q.Query.EndTime),
+			attribute.Int64("openobserve.size", q.Query.Size),
+		),
+	)
+	defer span.End()
+
 	oo.client.SetTimeout(time.Duration(reqOpt.TimeOut) * time.Second)
-	r := oo.client.R().SetHeaders(reqOpt.Header).SetContext(ctx).SetQueryString(reqOpt.Query).SetBody(reqOpt.Body).SetResult(reqOpt.Result)
+	r := oo.request(ctx).SetHeaders(reqOpt.Header).SetQueryString(reqOpt.Query).SetBody(reqOpt.Body).SetResult(reqOpt.Result)
 	r.Method = reqOpt.Method
-	r.URL = strings.TrimRight(oo.addr+reqOpt.Api, "/")
+	cfg := config.Get().OpenObserve
+	key := affinityKey(ctx)
+	if key != "" && cfg.AffinityHeader != "" {
+		r.SetHeader(cfg.AffinityHeader, key)
+	}
+	addr := backend(cfg, key)
+	if c := clusterFromContext(ctx); c != nil {
+		// the queriers belong to openobserve.addr
+		addr = strings.TrimRight(c.Addr, "/")
+		span.SetAttributes(attribute.String("openobserve.cluster", c.Name))
+	}
+	backendsFromContext(ctx).add(addr)
+	span.SetAttributes(attribute.String("openobserve.backend", addr))
+	r.URL = strings.TrimRight(addr+reqOpt.Api, "/")
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(r.Header))
 
+	query := auditQuery(q, api)
+	done := timing.Track(ctx, timing.PhaseOpenObserve)
 	resp, err := r.Send()
+	done()
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		searchRequests.Inc("error")
+		query.Error = err.Error()
+		audit.FromContext(ctx).AddQuery(query)
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, errors.BackendTimeout("deadline budget exceeded, the openobserve query was cancelled: " + err.Error()).WithCause(err)
+		}
 		return nil, err
 	}
 
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode()))
 	if resp.StatusCode() != http.StatusOK {
-		return nil, errors.New(int32(resp.StatusCode()), "status: "+resp.Status()+" Body: "+string(resp.Body()))
+		span.SetStatus(codes.Error, resp.Status())
+		searchRequests.Inc("error")
+		query.Error = resp.Status()
+		audit.FromContext(ctx).AddQuery(query)
+		err := responseError(resp)
+		if id := errors.FromError(err).GetMetadata()[ErrorMetaTraceID]; id != "" {
+			// the failed search is looked up by its trace_id as well
+			requestid.FromContext(ctx).AddSession(id)
+		}
+		return nil, err
 	}
 
 	res := resp.Result()
-	log.Printf("ooresp result: %#v", res)
+	requestid.Debugf(ctx, "ooresp result: %#v", res)
 	if ooresp, ok := res.(*OpenObserveResp); ok {
-		log.Printf("ooresp result took total: %d ms, watiqueue: %d ms, session_id: %s, q: %v", ooresp.TookDetail.Total, ooresp.TookDetail.WaitQueue, ooresp.TraceId, q)
-		// debug info
-		if ooresp.TookDetail.Total > 4000 {
-			log.Printf("ooresp slow result took total: %d ms, watiqueue: %d ms, session_id: %s, q: %v, api: %s", ooresp.TookDetail.Total, ooresp.TookDetail.WaitQueue, ooresp.TraceId, q, api)
+		span.SetAttributes(
+			attribute.String("openobserve.session_id", ooresp.TraceId),
+			attribute.Int("openobserve.took_ms", ooresp.TookDetail.Total),
+			attribute.Int("openobserve.wait_queue_ms", ooresp.TookDetail.WaitQueue),
+			attribute.Int("openobserve.hits", len(ooresp.Hits)),
+		)
+		searchRequests.Inc("ok")
+		requestid.FromContext(ctx).AddSession(ooresp.TraceId)
+		searchScanSize.Add(float64(ooresp.ScanSize))
+		requestid.Debugf(ctx, "ooresp result took total: %d ms, watiqueue: %d ms, session_id: %s, q: %v", ooresp.TookDetail.Total, ooresp.TookDetail.WaitQueue, ooresp.TraceId, q)
+
+		query.Took, query.WaitQueue, query.ScanSize = ooresp.TookDetail.Total, ooresp.TookDetail.WaitQueue, ooresp.ScanSize
+		query.Hits, query.SessionID = len(ooresp.Hits), ooresp.TraceId
+		query.Slow = audit.IsSlow(ooresp.TookDetail.Total)
+		audit.FromContext(ctx).AddQuery(query)
+		if query.Slow {
+			requestid.Logf(ctx, "ooresp slow result took total: %d ms, watiqueue: %d ms, session_id: %s, sql: %s, api: %s", ooresp.TookDetail.Total, ooresp.TookDetail.WaitQueue, ooresp.TraceId, query.SQL, api)
 		}
 		return ooresp, nil
 	}
@@ -227,15 +390,31 @@ func (oo *OpenObserveService) Search(ctx context.Context, q OOSearchQuery, api s
 	return nil, errors.New(int32(resp.StatusCode()), "Error Body: "+string(resp.Body()))
 }
 
+// auditQuery describes the search q for the audit log, with the decoded sql.
+func auditQuery(q OOSearchQuery, api string) audit.Query {
+	query := audit.Query{
+		API:        api,
+		Stream:     q.Stream,
+		SearchType: q.SearchType,
+		SQL:        q.Query.Sql,
+		StartTime:  q.Query.StartTime,
+		EndTime:    q.Query.EndTime,
+	}
+	if sql, err := base64.StdEncoding.DecodeString(q.Query.Sql); err == nil {
+		query.SQL = string(sql)
+	}
+	return query
+}
+
 func (oo *OpenObserveService) GetService(ctx context.Context) (*OpenObserveResp, error) {
 	sql := "SELECT service_name FROM distinct_values_traces_default GROUP BY service_name"
 	qq := OOSearchQuery{
+		Stream: SearchDistinctStream,
 		Query: OOSearchQueryQuery{
-			SqlMode:   "full",
 			StartTime: time.Now().Add(-time.Hour * time.Duration(168)).UnixMicro(),
 			EndTime:   time.Now().UnixMicro(),
 			Sql:       base64.StdEncoding.EncodeToString([]byte(sql)),
-			Size:      oo.DefaultServicenameSize,
+			Size:      config.Get().OpenObserve.DefaultServiceNameSize,
 		},
 	}
 
@@ -244,14 +423,14 @@ func (oo *OpenObserveService) GetService(ctx context.Context) (*OpenObserveResp,
 
 func (oo *OpenObserveService) GetServiceOperation(ctx context.Context, service_name, search_type string) (*OpenObserveResp, error) {
 	sql := "SELECT operation_name FROM distinct_values_traces_default " +
-		"WHERE service_name = '" + service_name + "' GROUP BY operation_name"
+		"WHERE service_name = " + sqlString(service_name) + " GROUP BY operation_name"
 	qq := OOSearchQuery{
+		Stream: SearchDistinctStream,
 		Query: OOSearchQueryQuery{
-			SqlMode:   "full",
 			StartTime: time.Now().Add(-time.Hour * time.Duration(168)).UnixMicro(),
 			EndTime:   time.Now().UnixMicro(),
 			Sql:       base64.StdEncoding.EncodeToString([]byte(sql)),
-			Size:      oo.DefaultOperationnameSize,
+			Size:      config.Get().OpenObserve.DefaultOperationNameSize,
 		},
 	}
 
@@ -262,17 +441,241 @@ func (oo *OpenObserveService) GetServiceOperation(ctx context.Context, service_n
 	return oo.SearchMeatadata(ctx, qq)
 }
 
+// GetServiceOperations fetches all the service and operation name pairs, e.g. for warming up a cache.
+func (oo *OpenObserveService) GetServiceOperations(ctx context.Context, search_type string) (*OpenObserveResp, error) {
+	sql := "SELECT service_name, operation_name FROM distinct_values_traces_default GROUP BY service_name, operation_name"
+	qq := OOSearchQuery{
+		Stream: SearchDistinctStream,
+		Query: OOSearchQueryQuery{
+			StartTime: time.Now().Add(-time.Hour * time.Duration(168)).UnixMicro(),
+			EndTime:   time.Now().UnixMicro(),
+			Sql:       base64.StdEncoding.EncodeToString([]byte(sql)),
+			Size:      -1,
+		},
+		SearchType: search_type,
+	}
+
+	return oo.SearchMeatadata(ctx, qq)
+}
+
+// SelfCheck verifies OpenObserve is reachable, the auth is accepted and the streams used by
+// the queries exist, the error says which of them failed.
+func (oo *OpenObserveService) SelfCheck(ctx context.Context) error {
+	required := map[string]string{
+		SearchTraceDefaultStream: "traces",
+		SearchTraceListStream:    "metadata",
+	}
+
+	for stream, streamType := range required {
+		var result struct {
+			List []struct {
+				Name string `json:"name"`
+			} `json:"list"`
+		}
+
+		resp, err := oo.request(ctx).
+			SetQueryParam("type", streamType).
+			SetResult(&result).
+			Get(strings.TrimRight(config.Get().OpenObserve.Addr, "/") + oo.orgAPI(ctx, streamsAPI))
+		if err != nil {
+			return fmt.Errorf("openobserve %s is not reachable: %w", config.Get().OpenObserve.Addr, err)
+		}
+
+		switch resp.StatusCode() {
+		case http.StatusOK:
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return fmt.Errorf("openobserve rejected openobserve.auth (status %d), check the base64 user:password", resp.StatusCode())
+		case http.StatusNotFound:
+			return fmt.Errorf("openobserve org %q not found (status 404), check openobserve.org", oo.orgName(ctx))
+		default:
+			return fmt.Errorf("openobserve streams api returned status %d: %s", resp.StatusCode(), string(resp.Body()))
+		}
+
+		found := false
+		for _, s := range result.List {
+			if s.Name == stream {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("openobserve %s stream %q not found in org %q", streamType, stream, oo.orgName(ctx))
+		}
+	}
+
+	return nil
+}
+
+// GetStreamColumns fetches the column names of the stream schema.
+func (oo *OpenObserveService) GetStreamColumns(ctx context.Context, stream, streamType string) ([]string, error) {
+	var result struct {
+		Schema []struct {
+			Name string `json:"name"`
+		} `json:"schema"`
+	}
+
+	resp, err := oo.request(ctx).
+		SetQueryParam("type", streamType).
+		SetResult(&result).
+		Get(strings.TrimRight(config.Get().OpenObserve.Addr, "/") + oo.orgAPI(ctx, streamsAPI) + "/" + url.PathEscape(stream) + "/schema")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	columns := make([]string, 0, len(result.Schema))
+	for _, field := range result.Schema {
+		columns = append(columns, field.Name)
+	}
+	return columns, nil
+}
+
+// GetTraceValues fetches the most frequent values of the q.Fields columns of the span stream from the _values api,
+// one hit per field with its values as {zo_sql_key, zo_sql_num}.
+func (oo *OpenObserveService) GetTraceValues(ctx context.Context, q OOValueQuery) (*OpenObserveResp, error) {
+	params := map[string]string{
+		"fields":     q.Fields,
+		"size":       strconv.FormatInt(q.Size, 10),
+		"start_time": strconv.FormatInt(q.StartTime, 10),
+		"end_time":   strconv.FormatInt(q.EndTime, 10),
+		"type":       q.Type,
+	}
+	if q.Filter != "" {
+		params["filter"] = q.Filter
+	}
+
+	var result OpenObserveResp
+	resp, err := oo.request(ctx).
+		SetQueryParams(params).
+		SetResult(&result).
+		Get(strings.TrimRight(config.Get().OpenObserve.Addr, "/") + oo.orgAPI(ctx, searchTraceValueAPI))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, responseError(resp)
+	}
+	return &result, nil
+}
+
+// IngestTraces posts an OTLP/HTTP export request, JSON or protobuf per contentType, to the traces ingestion
+// of stream, empty means default.
+func (oo *OpenObserveService) IngestTraces(ctx context.Context, stream, contentType string, body []byte) error {
+	r := oo.request(ctx).
+		SetHeader("Content-Type", contentType).
+		SetBody(body)
+	if stream != "" {
+		r.SetHeader("stream-name", stream)
+	}
+
+	resp, err := r.Post(strings.TrimRight(config.Get().OpenObserve.Addr, "/") + oo.orgAPI(ctx, ingestTracesAPI))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// IngestLogs posts a JSON array of records to the log stream.
+func (oo *OpenObserveService) IngestLogs(ctx context.Context, stream string, body []byte) error {
+	resp, err := oo.request(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(body).
+		Post(strings.TrimRight(config.Get().OpenObserve.Addr, "/") + oo.orgAPI(ctx, ingestLogsAPI) + url.PathEscape(stream) + "/_json")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// GetServiceOperationSpanKind fetches the operation name and span kind pairs of a service from the spans,
+// spanKind < 0 means any kind. column maps the span fields to the columns of the default stream, the hits
+// keep the field names.
+func (oo *OpenObserveService) GetServiceOperationSpanKind(ctx context.Context, column func(string) string, service_name string, spanKind int, start, end int64, search_type string) (*OpenObserveResp, error) {
+	qq := OOSearchQuery{
+		Stream: SearchTraceDefaultStream,
+		Query: OOSearchQueryQuery{
+			StartTime: start,
+			EndTime:   end,
+			Sql:       base64.StdEncoding.EncodeToString([]byte(serviceOperationSpanKindSQL(column, service_name, spanKind))),
+			Size:      config.Get().OpenObserve.DefaultOperationNameSize,
+		},
+	}
+
+	if len(search_type) > 0 {
+		qq.SearchType = search_type
+	}
+
+	return oo.SearchTraces(ctx, qq)
+}
+
+// serviceOperationSpanKindSQL is the query of GetServiceOperationSpanKind, service is a request parameter
+// and quoted as a string literal.
+func serviceOperationSpanKindSQL(column func(string) string, service string, spanKind int) string {
+	operation, kind := column("operation_name"), column("span_kind")
+	sql := fmt.Sprintf("SELECT %s AS operation_name, %s AS span_kind FROM default WHERE %s = %s", operation, kind, column("service_name"), sqlString(service))
+	if spanKind >= 0 {
+		sql += fmt.Sprintf(" AND %s = '%d'", kind, spanKind)
+	}
+	return sql + " GROUP BY " + operation + ", " + kind
+}
+
+const traceServiceIndexSQL = "SELECT trace_id, service_name, MIN(start_time) AS start_time, MAX(end_time) AS end_time " +
+	"FROM \"trace_list_index\" WHERE %s GROUP BY trace_id, service_name"
+
+// GetTraceServiceIndex fetches the services and the time bounds of every given trace in one aggregated query,
+// one per TraceIDChunks chunk if the ids exceed openobserve.max_sql_length.
 func (oo *OpenObserveService) GetTraceServiceIndex(ctx context.Context, traceids []string, start, end int64) (*OpenObserveResp, error) {
-	traceidsql := "trace_id IN('" + strings.Join(traceids, "','") + "')"
-	relatetive_service_sql := fmt.Sprintf("SELECT service_name FROM \"trace_list_index\" where %s GROUP BY service_name", traceidsql)
+	var merged *OpenObserveResp
+	for _, chunk := range TraceIDChunks(traceids, len(fmt.Sprintf(traceServiceIndexSQL, TraceIDsIn("trace_id", nil)))) {
+		relatetive_service_sql := fmt.Sprintf(traceServiceIndexSQL, TraceIDsIn("trace_id", chunk))
+		qq := OOSearchQuery{
+			Stream: SearchTraceListStream,
+			Query: OOSearchQueryQuery{
+				StartTime: start,
+				EndTime:   end,
+				Sql:       base64.StdEncoding.EncodeToString([]byte(relatetive_service_sql)),
+				Size:      -1,
+			},
+		}
+
+		resp, err := oo.SearchMeatadata(ctx, qq)
+		if err != nil {
+			return nil, err
+		}
+		if merged == nil {
+			merged = resp
+			continue
+		}
+		// the chunks have no trace in common
+		merged.Hits = append(merged.Hits, resp.Hits...)
+		merged.Total += resp.Total
+	}
+
+	return merged, nil
+}
+
+// GetReferencingSpans fetches the spans of the other traces with a reference to traceids, the spellings of one trace id.
+// column maps the span fields to the columns of the default stream, the hits keep the field names.
+func (oo *OpenObserveService) GetReferencingSpans(ctx context.Context, column func(string) string, traceids []string, start, end int64) (*OpenObserveResp, error) {
+	sql := fmt.Sprintf("SELECT %s AS trace_id, %s AS span_id FROM default WHERE %s AND NOT %s", column("trace_id"), column("span_id"),
+		TraceIDsIn(column("reference_parent_trace_id"), traceids), TraceIDsIn(column("trace_id"), traceids))
 	qq := OOSearchQuery{
+		Stream: SearchTraceDefaultStream,
 		Query: OOSearchQueryQuery{
-			SqlMode:   "full",
 			StartTime: start,
 			EndTime:   end,
-			Sql:       base64.StdEncoding.EncodeToString([]byte(relatetive_service_sql)),
+			Sql:       base64.StdEncoding.EncodeToString([]byte(sql)),
+			Size:      -1,
 		},
 	}
 
-	return oo.SearchMeatadata(ctx, qq)
+	return oo.SearchTraces(ctx, qq)
 }
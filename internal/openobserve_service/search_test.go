@@ -0,0 +1,24 @@
+package openobserve_service
+
+import (
+	"testing"
+)
+
+func TestServiceOperationSpanKindSQL(t *testing.T) {
+	column := func(field string) string { return field }
+	cases := []struct {
+		service  string
+		spanKind int
+		want     string
+	}{
+		{service: "frontend", spanKind: -1, want: "SELECT operation_name AS operation_name, span_kind AS span_kind FROM default WHERE service_name = 'frontend' GROUP BY operation_name, span_kind"},
+		{service: "frontend", spanKind: 2, want: "SELECT operation_name AS operation_name, span_kind AS span_kind FROM default WHERE service_name = 'frontend' AND span_kind = '2' GROUP BY operation_name, span_kind"},
+		{service: "x' OR '1'='1", spanKind: -1, want: "SELECT operation_name AS operation_name, span_kind AS span_kind FROM default WHERE service_name = 'x'' OR ''1''=''1' GROUP BY operation_name, span_kind"},
+		{service: "o'brien", spanKind: -1, want: "SELECT operation_name AS operation_name, span_kind AS span_kind FROM default WHERE service_name = 'o''brien' GROUP BY operation_name, span_kind"},
+	}
+	for _, c := range cases {
+		if got := serviceOperationSpanKindSQL(column, c.service, c.spanKind); got != c.want {
+			t.Errorf("serviceOperationSpanKindSQL(%q, %d) = %q, want %q", c.service, c.spanKind, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package openobserve_service
+
+import (
+	"openobserve-jaeger/internal/config"
+	"time"
+)
+
+// applyStreamDefaults fills the sql_mode, size and search_type q leaves unset from the
+// openobserve.streams defaults of q.Stream, falling back to full sql mode and ui searches.
+func applyStreamDefaults(q *OOSearchQuery) {
+	d := config.Get().OpenObserve.Streams[q.Stream]
+
+	if q.Query.SqlMode == "" {
+		q.Query.SqlMode = d.SqlMode
+		if q.Query.SqlMode == "" {
+			q.Query.SqlMode = DefaultSqlMode
+		}
+	}
+
+	if q.Query.Size == 0 {
+		q.Query.Size = d.Size
+	}
+
+	if q.SearchType == "" {
+		q.SearchType = d.SearchType
+		searchRange := time.Duration(q.Query.EndTime-q.Query.StartTime) * time.Microsecond
+		if d.BackgroundRange > 0 && searchRange > time.Duration(d.BackgroundRange)*time.Hour {
+			q.SearchType = BackgroundSearchType
+		}
+		if q.SearchType == "" {
+			q.SearchType = UiSearchType
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package openobserve_service
+
+import (
+	"context"
+	"encoding/base64"
+	"regexp"
+	"strings"
+)
+
+// TraceStreamContextKey is the key of the traces stream replacing the default stream in the queries of a
+// request, see service_overrides. gin.Context resolves string keys set by ctx.Set in Value.
+const TraceStreamContextKey = "openobserve_trace_stream"
+
+var fromDefaultStreamReg = regexp.MustCompile(`(?i)\bFROM\s+("default"|default)(\s|$)`)
+
+// applyTraceStream points the query of the default stream at the traces stream of ctx, if it has one.
+func applyTraceStream(ctx context.Context, q *OOSearchQuery) {
+	stream, _ := ctx.Value(TraceStreamContextKey).(string)
+	if stream == "" || stream == SearchTraceDefaultStream || q.Stream != SearchTraceDefaultStream {
+		return
+	}
+	sql, err := base64.StdEncoding.DecodeString(q.Query.Sql)
+	if err != nil {
+		return
+	}
+	from := `FROM "` + strings.ReplaceAll(stream, `"`, `""`) + `"$2`
+	q.Query.Sql = base64.StdEncoding.EncodeToString([]byte(fromDefaultStreamReg.ReplaceAllString(string(sql), from)))
+	q.Stream = stream
+}
@@ -0,0 +1,94 @@
+// Package otlp holds the OTLP/HTTP JSON encoding of ExportTraceServiceRequest, ids are hex and
+// 64 bit integers are strings.
+package otlp
+
+import "strconv"
+
+// status codes of Status.Code
+const (
+	StatusUnset = 0
+	StatusOk    = 1
+	StatusError = 2
+)
+
+type Request struct {
+	ResourceSpans []ResourceSpans `json:"resourceSpans"`
+}
+
+type ResourceSpans struct {
+	Resource   Resource     `json:"resource"`
+	ScopeSpans []ScopeSpans `json:"scopeSpans"`
+}
+
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+type ScopeSpans struct {
+	Scope Scope  `json:"scope"`
+	Spans []Span `json:"spans"`
+}
+
+type Scope struct {
+	Name string `json:"name"`
+}
+
+type Span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	TraceState        string     `json:"traceState,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	Events            []Event    `json:"events,omitempty"`
+	Links             []Link     `json:"links,omitempty"`
+	Status            Status     `json:"status"`
+}
+
+type Event struct {
+	TimeUnixNano string     `json:"timeUnixNano"`
+	Name         string     `json:"name"`
+	Attributes   []KeyValue `json:"attributes,omitempty"`
+}
+
+type Link struct {
+	TraceID    string     `json:"traceId"`
+	SpanID     string     `json:"spanId"`
+	Attributes []KeyValue `json:"attributes,omitempty"`
+}
+
+type Status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type KeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// the AnyValue encodings of the attribute values
+
+func String(k, v string) KeyValue {
+	return KeyValue{Key: k, Value: map[string]interface{}{"stringValue": v}}
+}
+
+func Bool(k string, v bool) KeyValue {
+	return KeyValue{Key: k, Value: map[string]interface{}{"boolValue": v}}
+}
+
+func Int64(k string, v int64) KeyValue {
+	return KeyValue{Key: k, Value: map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}}
+}
+
+func Float64(k string, v float64) KeyValue {
+	return KeyValue{Key: k, Value: map[string]interface{}{"doubleValue": v}}
+}
+
+// UnixNano is the string encoding of a timestamp in unix nanoseconds.
+func UnixNano(ns int64) string {
+	return strconv.FormatInt(ns, 10)
+}
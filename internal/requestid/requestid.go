@@ -0,0 +1,111 @@
+// Package requestid correlates a request with its log lines and the OpenObserve searches it ran.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"openobserve-jaeger/internal/config"
+	"sync"
+)
+
+// Header is the request id header, accepted from the caller or generated, and echoed in the response.
+const Header = "X-Request-ID"
+
+// ContextKey is the key of the request *Request, gin.Context resolves string keys set by ctx.Set in Value.
+const ContextKey = "request_id"
+
+// maxLength bounds the accepted ids, longer ones are replaced by a generated id.
+const maxLength = 128
+
+// Request is the id of a request and the session ids (trace_id) of the OpenObserve searches it ran.
+type Request struct {
+	ID string
+
+	mu       sync.Mutex
+	sessions []string
+}
+
+// New returns the Request of id, a new random id if id is empty or not printable ascii.
+func New(id string) *Request {
+	if !valid(id) {
+		id = generate()
+	}
+	return &Request{ID: id}
+}
+
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func generate() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// AddSession records the session id of an OpenObserve search.
+func (r *Request) AddSession(id string) {
+	if r == nil || id == "" {
+		return
+	}
+	r.mu.Lock()
+	r.sessions = append(r.sessions, id)
+	r.mu.Unlock()
+}
+
+// Sessions returns a copy of the recorded OpenObserve session ids.
+func (r *Request) Sessions() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.sessions...)
+}
+
+// FromContext returns the Request, nil outside of a request.
+func FromContext(ctx context.Context) *Request {
+	if ctx == nil {
+		return nil
+	}
+	r, _ := ctx.Value(ContextKey).(*Request)
+	return r
+}
+
+// ID returns the request id, empty outside of a request.
+func ID(ctx context.Context) string {
+	if r := FromContext(ctx); r != nil {
+		return r.ID
+	}
+	return ""
+}
+
+// Logf is log.Printf with the request id prepended:
+//
+//	requestid.Logf(ctx, "findTracesIds sql: %s", sql)
+func Logf(ctx context.Context, format string, args ...interface{}) {
+	if id := ID(ctx); id != "" {
+		log.Printf("request_id: %s, %s", id, fmt.Sprintf(format, args...))
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// Debugf is Logf for the verbose lines, the sql and the results of the queries, logged with log_level debug only.
+func Debugf(ctx context.Context, format string, args ...interface{}) {
+	if config.Get().LogLevel != config.LogLevelDebug {
+		return
+	}
+	Logf(ctx, format, args...)
+}
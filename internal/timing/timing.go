@@ -0,0 +1,75 @@
+// Package timing records how long each phase of a request takes.
+package timing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ContextKey is the key of the request Timings, gin.Context resolves string keys set by ctx.Set in Value.
+const ContextKey = "timings"
+
+const (
+	PhaseParse       = "parse"
+	PhaseSQLBuild    = "sql_build"
+	PhaseOpenObserve = "openobserve"
+	PhaseConversion  = "conversion"
+	PhaseEncode      = "encode"
+)
+
+// Timings accumulates the durations per phase, phases run more than once (e.g. several
+// OpenObserve round trips) are summed.
+type Timings struct {
+	mu     sync.Mutex
+	phases map[string]time.Duration
+}
+
+func New() *Timings {
+	return &Timings{
+		phases: make(map[string]time.Duration),
+	}
+}
+
+func (t *Timings) Add(phase string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.phases[phase] += d
+	t.mu.Unlock()
+}
+
+// Phases returns a copy of the recorded durations.
+func (t *Timings) Phases() map[string]time.Duration {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	phases := make(map[string]time.Duration, len(t.phases))
+	for k, v := range t.phases {
+		phases[k] = v
+	}
+	return phases
+}
+
+// FromContext returns the request Timings, nil if the request is not timed.
+func FromContext(ctx context.Context) *Timings {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(ContextKey).(*Timings)
+	return t
+}
+
+// Track starts timing a phase, call the returned func when the phase is done:
+//
+//	defer timing.Track(ctx, timing.PhaseConversion)()
+func Track(ctx context.Context, phase string) func() {
+	t := FromContext(ctx)
+	start := time.Now()
+	return func() {
+		t.Add(phase, time.Since(start))
+	}
+}
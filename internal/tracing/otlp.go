@@ -0,0 +1,195 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"log"
+	"net/http"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/otlp"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBatchSize     = 512
+	defaultFlushInterval = 5
+	exportTimeout        = 10 * time.Second
+)
+
+// exporter sends the ended spans in batches as OTLP/HTTP JSON, spans are dropped when the queue is full
+// so a slow collector never blocks the requests.
+type exporter struct {
+	url         string
+	headers     map[string]string
+	serviceName string
+	batchSize   int
+	interval    time.Duration
+	queue       chan *span
+	client      *http.Client
+}
+
+func newExporter(cfg config.TracingConfig) *exporter {
+	e := &exporter{
+		url:         strings.TrimRight(cfg.Endpoint, "/") + "/v1/traces",
+		headers:     cfg.Headers,
+		serviceName: cfg.ServiceName,
+		batchSize:   cfg.BatchSize,
+		interval:    time.Duration(cfg.FlushInterval) * time.Second,
+		client:      &http.Client{Timeout: exportTimeout},
+	}
+	if e.serviceName == "" {
+		e.serviceName = defaultServiceName
+	}
+	if e.batchSize <= 0 {
+		e.batchSize = defaultBatchSize
+	}
+	if e.interval <= 0 {
+		e.interval = defaultFlushInterval * time.Second
+	}
+	e.queue = make(chan *span, e.batchSize*4)
+
+	go e.run()
+	return e
+}
+
+func (e *exporter) enqueue(s *span) {
+	select {
+	case e.queue <- s:
+	default:
+	}
+}
+
+func (e *exporter) run() {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	batch := make([]*span, 0, e.batchSize)
+	for {
+		select {
+		case s := <-e.queue:
+			batch = append(batch, s)
+			if len(batch) < e.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		if err := e.export(batch); err != nil {
+			log.Printf("tracing export %d spans err: %v", len(batch), err)
+		}
+		batch = make([]*span, 0, e.batchSize)
+	}
+}
+
+func (e *exporter) export(spans []*span) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s returned status %d", e.url, resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *exporter) request(spans []*span) otlp.Request {
+	otlpSpans := make([]otlp.Span, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		os := otlp.Span{
+			TraceID:           s.spanContext.TraceID().String(),
+			SpanID:            s.spanContext.SpanID().String(),
+			TraceState:        s.spanContext.TraceState().String(),
+			Name:              s.name,
+			Kind:              int(s.kind), // the api and OTLP enums have the same values
+			StartTimeUnixNano: otlp.UnixNano(s.start.UnixNano()),
+			EndTimeUnixNano:   otlp.UnixNano(s.end.UnixNano()),
+			Attributes:        otlpAttributes(s.attributes),
+			Status:            otlp.Status{Code: otlpStatusCode(s.status), Message: s.description},
+		}
+		if s.parent.IsValid() {
+			os.ParentSpanID = s.parent.SpanID().String()
+		}
+		for _, ev := range s.events {
+			os.Events = append(os.Events, otlp.Event{
+				TimeUnixNano: otlp.UnixNano(ev.time.UnixNano()),
+				Name:         ev.name,
+				Attributes:   otlpAttributes(ev.attributes),
+			})
+		}
+		s.mu.Unlock()
+		otlpSpans = append(otlpSpans, os)
+	}
+
+	return otlp.Request{
+		ResourceSpans: []otlp.ResourceSpans{
+			{
+				Resource: otlp.Resource{
+					Attributes: otlpAttributes([]attribute.KeyValue{attribute.String("service.name", e.serviceName)}),
+				},
+				ScopeSpans: []otlp.ScopeSpans{
+					{
+						Scope: otlp.Scope{Name: instrumentationName},
+						Spans: otlpSpans,
+					},
+				},
+			},
+		},
+	}
+}
+
+// otlpStatusCode maps the api status codes (Unset, Error, Ok) to the OTLP ones (UNSET, OK, ERROR).
+func otlpStatusCode(code codes.Code) int {
+	switch code {
+	case codes.Ok:
+		return otlp.StatusOk
+	case codes.Error:
+		return otlp.StatusError
+	}
+	return otlp.StatusUnset
+}
+
+func otlpAttributes(kvs []attribute.KeyValue) []otlp.KeyValue {
+	attrs := make([]otlp.KeyValue, 0, len(kvs))
+	for _, kv := range kvs {
+		attrs = append(attrs, otlpValue(kv))
+	}
+	return attrs
+}
+
+func otlpValue(kv attribute.KeyValue) otlp.KeyValue {
+	k := string(kv.Key)
+	switch kv.Value.Type() {
+	case attribute.BOOL:
+		return otlp.Bool(k, kv.Value.AsBool())
+	case attribute.INT64:
+		return otlp.Int64(k, kv.Value.AsInt64())
+	case attribute.FLOAT64:
+		return otlp.Float64(k, kv.Value.AsFloat64())
+	case attribute.STRING:
+		return otlp.String(k, kv.Value.AsString())
+	}
+	return otlp.String(k, kv.Value.Emit())
+}
@@ -0,0 +1,211 @@
+// Package tracing traces the proxy itself with the OpenTelemetry trace api and exports the spans
+// to an OTLP/HTTP endpoint, so a UI query can be followed through the proxy into OpenObserve.
+package tracing
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"math/rand"
+	"openobserve-jaeger/internal/config"
+	"sync"
+	"time"
+)
+
+const (
+	instrumentationName = "openobserve-jaeger"
+	defaultServiceName  = "openobserve-jaeger"
+)
+
+var provider trace.TracerProvider = trace.NewNoopTracerProvider()
+
+// Init starts exporting the spans when cfg is enabled, it is called once before serving.
+func Init(cfg config.TracingConfig) {
+	if !cfg.Enabled {
+		return
+	}
+
+	ratio := cfg.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+
+	var seed int64
+	_ = binary.Read(crand.Reader, binary.LittleEndian, &seed)
+	provider = &tracerProvider{
+		exporter: newExporter(cfg),
+		ratio:    ratio,
+		rand:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Tracer returns the tracer of the proxy, a no-op one when tracing is disabled.
+func Tracer() trace.Tracer {
+	return provider.Tracer(instrumentationName)
+}
+
+type tracerProvider struct {
+	exporter *exporter
+	ratio    float64
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func (p *tracerProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return p
+}
+
+func (p *tracerProvider) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+
+	parent := trace.SpanContextFromContext(ctx)
+	if cfg.NewRoot() {
+		parent = trace.SpanContext{}
+	}
+
+	scc := trace.SpanContextConfig{
+		TraceID:    parent.TraceID(),
+		TraceState: parent.TraceState(),
+	}
+	p.mu.Lock()
+	if !parent.IsValid() {
+		p.rand.Read(scc.TraceID[:])
+	}
+	p.rand.Read(scc.SpanID[:])
+	p.mu.Unlock()
+
+	sampled := parent.IsSampled()
+	if !parent.IsValid() {
+		// same decision for the same trace id, like the sdk TraceIDRatioBased sampler
+		sampled = binary.BigEndian.Uint64(scc.TraceID[8:16])>>1 < uint64(p.ratio*(1<<63))
+	}
+	if sampled {
+		scc.TraceFlags = trace.FlagsSampled
+	}
+
+	s := &span{
+		provider:    p,
+		spanContext: trace.NewSpanContext(scc),
+		parent:      parent,
+		name:        name,
+		kind:        cfg.SpanKind(),
+		start:       cfg.Timestamp(),
+		attributes:  cfg.Attributes(),
+		recording:   sampled,
+	}
+	if s.start.IsZero() {
+		s.start = time.Now()
+	}
+
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+type event struct {
+	name       string
+	time       time.Time
+	attributes []attribute.KeyValue
+}
+
+// span records its data until End hands it to the exporter, spans not sampled only carry the context.
+type span struct {
+	provider    *tracerProvider
+	spanContext trace.SpanContext
+	parent      trace.SpanContext
+	kind        trace.SpanKind
+	start       time.Time
+
+	mu          sync.Mutex
+	name        string
+	end         time.Time
+	attributes  []attribute.KeyValue
+	events      []event
+	status      codes.Code
+	description string
+	recording   bool
+}
+
+func (s *span) End(options ...trace.SpanEndOption) {
+	s.mu.Lock()
+	if !s.recording {
+		s.mu.Unlock()
+		return
+	}
+	s.recording = false
+	cfg := trace.NewSpanEndConfig(options...)
+	s.end = cfg.Timestamp()
+	if s.end.IsZero() {
+		s.end = time.Now()
+	}
+	s.mu.Unlock()
+
+	s.provider.exporter.enqueue(s)
+}
+
+func (s *span) AddEvent(name string, options ...trace.EventOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.recording {
+		return
+	}
+	cfg := trace.NewEventConfig(options...)
+	ev := event{name: name, time: cfg.Timestamp(), attributes: cfg.Attributes()}
+	if ev.time.IsZero() {
+		ev.time = time.Now()
+	}
+	s.events = append(s.events, ev)
+}
+
+func (s *span) IsRecording() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.recording
+}
+
+func (s *span) RecordError(err error, options ...trace.EventOption) {
+	if err == nil {
+		return
+	}
+	options = append(options, trace.WithAttributes(
+		attribute.String("exception.message", err.Error()),
+	))
+	s.AddEvent("exception", options...)
+}
+
+func (s *span) SpanContext() trace.SpanContext {
+	return s.spanContext
+}
+
+func (s *span) SetStatus(code codes.Code, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.recording || s.status == codes.Ok {
+		return
+	}
+	s.status = code
+	if code == codes.Error {
+		s.description = description
+	}
+}
+
+func (s *span) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+func (s *span) SetAttributes(kv ...attribute.KeyValue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.recording {
+		return
+	}
+	s.attributes = append(s.attributes, kv...)
+}
+
+func (s *span) TracerProvider() trace.TracerProvider {
+	return s.provider
+}
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/requestid"
+	"time"
+)
+
+var defaultRemoteIPHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
+
+// accessLogLine is a line of the json access log
+type accessLogLine struct {
+	Time      string  `json:"time"`
+	Status    int     `json:"status"`
+	Latency   float64 `json:"latency_ms"`
+	ClientIP  string  `json:"client_ip"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Size      int     `json:"size"`
+	RequestID string  `json:"request_id,omitempty"`
+	User      string  `json:"user,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// newEngine creates the gin engine of server: the gin mode, the trusted proxies giving the client ip
+// behind an ingress, and the access log. It replaces the defaults of gin.Default, debug mode and all the
+// proxies trusted.
+func newEngine(cfg config.ServerConfig) (*gin.Engine, error) {
+	mode := cfg.Mode
+	if mode == "" {
+		mode = gin.ReleaseMode
+	}
+	gin.SetMode(mode)
+
+	engine := gin.New()
+	if err := engine.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("server.trusted_proxies: %w", err)
+	}
+	engine.RemoteIPHeaders = defaultRemoteIPHeaders
+	if len(cfg.RemoteIPHeaders) > 0 {
+		engine.RemoteIPHeaders = cfg.RemoteIPHeaders
+	}
+	if logger := accessLog(cfg.AccessLog); logger != nil {
+		engine.Use(logger)
+	}
+	return engine, nil
+}
+
+// accessLog logs every request in the text or json format, with the client ip, the request id and the
+// auth user; nil for off.
+func accessLog(format string) gin.HandlerFunc {
+	switch format {
+	case config.AccessLogOff:
+		return nil
+	case config.AccessLogJSON:
+		return gin.LoggerWithFormatter(func(p gin.LogFormatterParams) string {
+			line := accessLogLine{
+				Time:      p.TimeStamp.UTC().Format(time.RFC3339Nano),
+				Status:    p.StatusCode,
+				Latency:   float64(p.Latency.Microseconds()) / 1e3,
+				ClientIP:  p.ClientIP,
+				Method:    p.Method,
+				Path:      p.Path,
+				Size:      p.BodySize,
+				RequestID: accessLogRequestID(p),
+				User:      accessLogUser(p),
+				Error:     p.ErrorMessage,
+			}
+			b, _ := json.Marshal(line)
+			return string(b) + "\n"
+		})
+	}
+	return gin.LoggerWithFormatter(func(p gin.LogFormatterParams) string {
+		line := fmt.Sprintf("[GIN] %s | %3d | %13v | %15s | %-7s %q | request_id: %s",
+			p.TimeStamp.Format("2006/01/02 - 15:04:05"), p.StatusCode, p.Latency, p.ClientIP, p.Method, p.Path, accessLogRequestID(p))
+		if user := accessLogUser(p); user != "" {
+			line += " | user: " + user
+		}
+		if p.ErrorMessage != "" {
+			line += " | " + p.ErrorMessage
+		}
+		return line + "\n"
+	})
+}
+
+func accessLogRequestID(p gin.LogFormatterParams) string {
+	if r, ok := p.Keys[requestid.ContextKey].(*requestid.Request); ok {
+		return r.ID
+	}
+	return ""
+}
+
+func accessLogUser(p gin.LogFormatterParams) string {
+	user, _ := p.Keys[authUserKey].(string)
+	return user
+}
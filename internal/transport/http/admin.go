@@ -0,0 +1,117 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+	"log"
+	"net/http"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/errors"
+	"openobserve-jaeger/internal/jaeger_service"
+)
+
+const redacted = "REDACTED"
+
+// AdminSettings are the live settings of /admin/settings, a PUT changes the ones it sets
+// until the next config reload.
+type AdminSettings struct {
+	LogLevel         *string `json:"log_level,omitempty"`
+	SkipWal          *bool   `json:"skip_wal,omitempty"`
+	BackgroundSearch *bool   `json:"background_search,omitempty"`
+}
+
+// GetAdminConfig serves /admin/config, the current config as yaml with the credentials redacted.
+func (s *jaegerServerRoute) GetAdminConfig(ctx *gin.Context) {
+	data, err := yaml.Marshal(redactConfig(*config.Get()))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Data(http.StatusOK, "application/yaml; charset=utf-8", data)
+}
+
+// redactConfig replaces the passwords, tokens and header values of cfg, the maps and slices are copied.
+func redactConfig(cfg config.Config) config.Config {
+	redactMap := func(m map[string]string) map[string]string {
+		if m == nil {
+			return nil
+		}
+		res := make(map[string]string, len(m))
+		for k := range m {
+			res[k] = redacted
+		}
+		return res
+	}
+
+	if cfg.OpenObserve.Auth != "" {
+		cfg.OpenObserve.Auth = redacted
+	}
+	cfg.OpenObserve.Headers = redactMap(cfg.OpenObserve.Headers)
+	clusters := make([]config.ClusterConfig, len(cfg.OpenObserve.Clusters))
+	for i, c := range cfg.OpenObserve.Clusters {
+		if c.Auth != "" {
+			c.Auth = redacted
+		}
+		clusters[i] = c
+	}
+	cfg.OpenObserve.Clusters = clusters
+	cfg.Auth.BasicUsers = redactMap(cfg.Auth.BasicUsers)
+	tokens := make([]string, len(cfg.Auth.BearerTokens))
+	for i := range tokens {
+		tokens[i] = redacted
+	}
+	cfg.Auth.BearerTokens = tokens
+	cfg.Tracing.Headers = redactMap(cfg.Tracing.Headers)
+	return cfg
+}
+
+// FlushCache serves POST /admin/cache/flush, it drops the cached search responses.
+func (s *jaegerServerRoute) FlushCache(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	s.JaegerService.FlushCache()
+	log.Printf("admin %s flushed the search cache", ctx.GetString(authUserKey))
+	return &jaeger_service.JaegerStructuredResponse{}, nil
+}
+
+// GetAdminSettings serves GET /admin/settings.
+func (s *jaegerServerRoute) GetAdminSettings(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	return &jaeger_service.JaegerStructuredResponse{Data: currentSettings(config.Get())}, nil
+}
+
+// UpdateAdminSettings serves PUT /admin/settings with a json AdminSettings body. The settings apply to the new
+// requests right away and last until the config file is reloaded.
+func (s *jaegerServerRoute) UpdateAdminSettings(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	var settings AdminSettings
+	if err := ctx.ShouldBindJSON(&settings); err != nil {
+		return nil, errors.BadRequest("invalid settings: " + err.Error())
+	}
+
+	cfg := *config.Get()
+	if settings.LogLevel != nil {
+		cfg.LogLevel = *settings.LogLevel
+	}
+	if settings.SkipWal != nil {
+		cfg.OpenObserve.SkipWal = *settings.SkipWal
+	}
+	if settings.BackgroundSearch != nil {
+		cfg.OpenObserve.BackgroundSearch = *settings.BackgroundSearch
+	}
+	if err := config.Validate(cfg); err != nil {
+		return nil, errors.BadRequest(err.Error())
+	}
+
+	config.Set(cfg)
+	log.Printf("admin %s changed the settings to %+v", ctx.GetString(authUserKey), currentSettings(&cfg))
+	return &jaeger_service.JaegerStructuredResponse{Data: currentSettings(&cfg)}, nil
+}
+
+func currentSettings(cfg *config.Config) AdminSettings {
+	logLevel := cfg.LogLevel
+	if logLevel == "" {
+		logLevel = config.LogLevelInfo
+	}
+	return AdminSettings{
+		LogLevel:         &logLevel,
+		SkipWal:          &cfg.OpenObserve.SkipWal,
+		BackgroundSearch: &cfg.OpenObserve.BackgroundSearch,
+	}
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"openobserve-jaeger/internal/audit"
+	"openobserve-jaeger/internal/jaeger_service"
+	"openobserve-jaeger/internal/requestid"
+	"time"
+)
+
+// auditRequest collects the OpenObserve searches of the request and logs them with the request
+// once it's served, a nil logger (audit disabled) passes the request on.
+func auditRequest(l *audit.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if l == nil {
+			ctx.Next()
+			return
+		}
+
+		start := time.Now()
+		entry := &audit.Entry{}
+		ctx.Set(audit.ContextKey, entry)
+		ctx.Next()
+
+		r := audit.Record{
+			Timestamp: start.UnixMicro(),
+			RequestID: requestid.ID(ctx),
+			User:      ctx.GetString(authUserKey),
+			IP:        ctx.ClientIP(),
+			Route:     ctx.FullPath(),
+			Path:      ctx.Request.URL.Path,
+			Params:    ctx.Request.URL.RawQuery,
+			Status:    ctx.Writer.Status(),
+			Duration:  float64(time.Since(start).Microseconds()) / 1000,
+		}
+		entry.Fill(&r)
+		l.Log(r)
+	}
+}
+
+// recordResults records the result count of the response for the audit log: the traces, or the
+// total of the other responses.
+func recordResults(ctx *gin.Context, response *jaeger_service.JaegerStructuredResponse) {
+	n := response.Total
+	if traces, ok := response.Data.([]*ui.Trace); ok {
+		n = len(traces)
+	}
+	audit.FromContext(ctx).SetResults(n)
+}
@@ -0,0 +1,111 @@
+package http
+
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/jaeger_service"
+	"strings"
+)
+
+const (
+	authUserKey   = "auth_user"
+	bearerPrefix  = "Bearer "
+	tokenUserName = "token"
+)
+
+// authenticate protects the routes with static basic-auth users and bearer tokens,
+// paths in AllowPaths are always served without credentials.
+func authenticate(cfg config.AuthConfig) gin.HandlerFunc {
+	allowPaths := make(map[string]struct{}, len(cfg.AllowPaths))
+	for _, p := range cfg.AllowPaths {
+		allowPaths[p] = struct{}{}
+	}
+
+	return func(ctx *gin.Context) {
+		if !cfg.Enabled {
+			ctx.Next()
+			return
+		}
+
+		if _, ok := allowPaths[ctx.Request.URL.Path]; ok {
+			ctx.Next()
+			return
+		}
+
+		if user, ok := checkCredentials(cfg, ctx.Request); ok {
+			ctx.Set(authUserKey, user)
+			ctx.Next()
+			return
+		}
+
+		ctx.Header("WWW-Authenticate", `Basic realm="openobserve-jaeger"`)
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, jaeger_service.JaegerStructuredResponse{
+			Errors: []jaeger_service.JaegerStructuredError{
+				{
+					Code: http.StatusUnauthorized,
+					Msg:  "unauthorized",
+				},
+			},
+		})
+	}
+}
+
+// requireUsers rejects the authenticated users not in users with 403, empty users allows everyone.
+func requireUsers(users []string) gin.HandlerFunc {
+	allowed := make(map[string]struct{}, len(users))
+	for _, u := range users {
+		allowed[u] = struct{}{}
+	}
+
+	return func(ctx *gin.Context) {
+		if len(allowed) == 0 {
+			ctx.Next()
+			return
+		}
+
+		if _, ok := allowed[ctx.GetString(authUserKey)]; ok {
+			ctx.Next()
+			return
+		}
+
+		ctx.AbortWithStatusJSON(http.StatusForbidden, jaeger_service.JaegerStructuredResponse{
+			Errors: []jaeger_service.JaegerStructuredError{
+				{
+					Code: http.StatusForbidden,
+					Msg:  "forbidden",
+				},
+			},
+		})
+	}
+}
+
+func checkCredentials(cfg config.AuthConfig, r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if strings.HasPrefix(header, bearerPrefix) {
+		token := strings.TrimPrefix(header, bearerPrefix)
+		for _, t := range cfg.BearerTokens {
+			// an empty entry would let in the header without a token
+			if t != "" && secureCompare(token, t) {
+				return tokenUserName, true
+			}
+		}
+		return "", false
+	}
+
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return "", false
+	}
+	expected, ok := cfg.BasicUsers[user]
+	if !ok || !secureCompare(password, expected) {
+		return "", false
+	}
+
+	return user, true
+}
+
+func secureCompare(given, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
@@ -0,0 +1,59 @@
+package http
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/jaeger_service"
+	"time"
+)
+
+// setCacheControl sets the cache_control headers of the route on the response about to be written. The failed
+// and partial responses, and the traces which ended less than settle ago, are sent with no-store.
+func setCacheControl(ctx *gin.Context, response *jaeger_service.JaegerStructuredResponse) {
+	cfg := config.Get()
+	if !cfg.CacheControl.Enabled {
+		return
+	}
+	route, ok := cfg.CacheControl.Routes[ctx.FullPath()]
+	if !ok {
+		return
+	}
+
+	if len(response.Errors) > 0 || route.MaxAge <= 0 || !settled(response, time.Duration(route.Settle)*time.Second) {
+		ctx.Header("Cache-Control", "no-store")
+		return
+	}
+
+	// the responses of authenticated users are not shared between them
+	scope := "public"
+	if cfg.Auth.Enabled {
+		scope = "private"
+	}
+	ctx.Header("Cache-Control", fmt.Sprintf("%s, max-age=%d", scope, route.MaxAge))
+	if route.SurrogateMaxAge > 0 {
+		ctx.Header("Surrogate-Control", fmt.Sprintf("max-age=%d", route.SurrogateMaxAge))
+	}
+}
+
+// settled reports whether the newest span of the traces of the response ended at least settle ago.
+func settled(response *jaeger_service.JaegerStructuredResponse, settle time.Duration) bool {
+	traces, ok := response.Data.([]*ui.Trace)
+	if !ok || settle <= 0 {
+		return true
+	}
+
+	var newest uint64
+	for _, trace := range traces {
+		if trace == nil {
+			continue
+		}
+		for i := range trace.Spans {
+			if end := trace.Spans[i].StartTime + trace.Spans[i].Duration; end > newest {
+				newest = end
+			}
+		}
+	}
+	return time.Since(time.UnixMicro(int64(newest))) >= settle
+}
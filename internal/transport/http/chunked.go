@@ -0,0 +1,161 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/gin-gonic/gin"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"io"
+	"openobserve-jaeger/internal/codec"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/jaeger_service"
+	"openobserve-jaeger/internal/requestid"
+	"openobserve-jaeger/internal/timing"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultFlushSpans = 1000
+
+	traceSpansHeader = "X-Trace-Spans"
+	traceSizeHeader  = "X-Trace-Estimated-Size"
+)
+
+// wrapChunkedResponse works like wrapResponse, but traces with at least MinSpans spans
+// are encoded span by span instead of being marshaled in one piece. The handler still builds the
+// whole trace, only the encode buffer is bounded, no copy of the response is marshaled in memory. The
+// span count and the estimated size of the traces come first, in headers and as the first field of the body.
+func wrapChunkedResponse(h Hanlder) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		response, err := h(ctx)
+		if err != nil {
+			errResp := jaeger_service.ErrorResponse(err)
+			response = &errResp
+		}
+
+		attachDebugTimings(ctx, response)
+		attachDebugBackends(ctx, response)
+		attachRequestID(ctx, response)
+		setCacheControl(ctx, response)
+		setTraceSize(ctx, response)
+		recordResults(ctx, response)
+		applyResponseCompat(response)
+		defer timing.Track(ctx, timing.PhaseEncode)()
+
+		cfg := config.Get().Chunked
+		traces, ok := response.Data.([]*ui.Trace)
+		if !cfg.Enabled || !ok || countSpans(traces) < cfg.MinSpans {
+			ctx.JSON(response.StatusCode(), compatResponse(response))
+			return
+		}
+
+		if err := writeTraceChunks(ctx, response, traces, cfg); err != nil {
+			requestid.Logf(ctx, "writeTraceChunks err: %v", err)
+		}
+	}
+}
+
+// setTraceSize measures the traces of the response unless the handler did, and sends the result as headers.
+func setTraceSize(ctx *gin.Context, response *jaeger_service.JaegerStructuredResponse) {
+	if response.Size == nil {
+		traces, ok := response.Data.([]*ui.Trace)
+		if !ok || len(traces) == 0 {
+			return
+		}
+		size := jaeger_service.MeasureTraces(traces)
+		response.Size = &size
+	}
+	ctx.Header(traceSpansHeader, strconv.Itoa(response.Size.Spans))
+	ctx.Header(traceSizeHeader, strconv.FormatInt(response.Size.EstimatedBytes, 10))
+}
+
+func countSpans(traces []*ui.Trace) int {
+	count := 0
+	for _, trace := range traces {
+		if trace != nil {
+			count += len(trace.Spans)
+		}
+	}
+	return count
+}
+
+// writeTraceChunks writes {"size":{...},"data":[traces...],<rest of the response>} with the spans encoded one at a time,
+// flushing every flush_spans spans.
+func writeTraceChunks(ctx *gin.Context, response *jaeger_service.JaegerStructuredResponse, traces []*ui.Trace, cfg config.ChunkedConfig) error {
+	flushSpans := cfg.FlushSpans
+	if flushSpans <= 0 {
+		flushSpans = defaultFlushSpans
+	}
+
+	// the envelope is marshaled without data, so it follows the response fields
+	envelope := *response
+	envelope.Data = nil
+	envelope.Size = nil
+	tail, err := codec.Marshal(compatResponse(&envelope))
+	if err != nil {
+		return err
+	}
+	tail = bytes.TrimPrefix(tail, []byte(`{"data":null`))
+
+	ctx.Header("Content-Type", "application/json; charset=utf-8")
+	var w io.Writer = ctx.Writer
+	flush := ctx.Writer.Flush
+	if cfg.Gzip && strings.Contains(ctx.GetHeader("Accept-Encoding"), "gzip") {
+		ctx.Header("Content-Encoding", "gzip")
+		ctx.Header("Vary", "Accept-Encoding")
+		gz := gzip.NewWriter(ctx.Writer)
+		defer gz.Close()
+		w = gz
+		flush = func() {
+			gz.Flush()
+			ctx.Writer.Flush()
+		}
+	}
+	ctx.Status(response.StatusCode())
+
+	enc := codec.NewEncoder(w)
+	io.WriteString(w, `{"size":`)
+	if err := enc.Encode(response.Size); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `,"data":[`); err != nil {
+		return err
+	}
+	// the size reaches the client before the spans are encoded
+	flush()
+	written := 0
+	for i, trace := range traces {
+		if i > 0 {
+			io.WriteString(w, ",")
+		}
+		if trace == nil {
+			io.WriteString(w, "null")
+			continue
+		}
+
+		io.WriteString(w, `{"traceID":`)
+		enc.Encode(trace.TraceID)
+		io.WriteString(w, `,"spans":[`)
+		for j := range trace.Spans {
+			if j > 0 {
+				io.WriteString(w, ",")
+			}
+			if err := enc.Encode(&trace.Spans[j]); err != nil {
+				return err
+			}
+			written++
+			if written%flushSpans == 0 {
+				flush()
+			}
+		}
+		io.WriteString(w, `],"processes":`)
+		enc.Encode(trace.Processes)
+		io.WriteString(w, `,"warnings":`)
+		enc.Encode(trace.Warnings)
+		io.WriteString(w, "}")
+	}
+	io.WriteString(w, "]")
+	_, err = w.Write(tail)
+	return err
+}
@@ -0,0 +1,48 @@
+package http
+
+import (
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/jaeger_service"
+	"reflect"
+)
+
+// legacyResponse leaves errors out when there are none, the older jaeger-ui take an empty list for a failure.
+type legacyResponse struct {
+	*jaeger_service.JaegerStructuredResponse
+	Errors []jaeger_service.JaegerStructuredError `json:"errors,omitempty"`
+}
+
+// modernResponse leaves out the limit and offset the newer jaeger-ui ignore when they are not set.
+type modernResponse struct {
+	*jaeger_service.JaegerStructuredResponse
+	Limit  int `json:"limit,omitempty"`
+	Offset int `json:"offset,omitempty"`
+}
+
+// applyResponseCompat fills the fields response_compat requires: the data of the legacy responses is never
+// null and the errors of the modern ones are never null.
+func applyResponseCompat(response *jaeger_service.JaegerStructuredResponse) {
+	switch config.Get().ResponseCompat {
+	case config.ResponseCompatLegacy:
+		if response.Data == nil {
+			response.Data = make([]interface{}, 0)
+		} else if v := reflect.ValueOf(response.Data); v.Kind() == reflect.Slice && v.IsNil() {
+			response.Data = reflect.MakeSlice(v.Type(), 0, 0).Interface()
+		}
+	case config.ResponseCompatModern:
+		if response.Errors == nil {
+			response.Errors = make([]jaeger_service.JaegerStructuredError, 0)
+		}
+	}
+}
+
+// compatResponse is the value encoding response with the fields response_compat omits, response itself by default.
+func compatResponse(response *jaeger_service.JaegerStructuredResponse) interface{} {
+	switch config.Get().ResponseCompat {
+	case config.ResponseCompatLegacy:
+		return legacyResponse{JaegerStructuredResponse: response, Errors: response.Errors}
+	case config.ResponseCompatModern:
+		return modernResponse{JaegerStructuredResponse: response, Limit: response.Limit, Offset: response.Offset}
+	}
+	return response
+}
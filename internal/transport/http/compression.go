@@ -0,0 +1,203 @@
+package http
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"openobserve-jaeger/internal/config"
+	"strings"
+)
+
+const defaultCompressionMinSize = 1024
+
+// compress gzip or deflate encodes the responses which are larger than MinSize and
+// have an allowed content type. Responses with a Content-Encoding are left untouched.
+func compress(cfg config.CompressionConfig) gin.HandlerFunc {
+	level := cfg.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	minSize := cfg.MinSize
+	if minSize <= 0 {
+		minSize = defaultCompressionMinSize
+	}
+
+	return func(ctx *gin.Context) {
+		if !cfg.Enabled || ctx.Request.Method == http.MethodHead {
+			ctx.Next()
+			return
+		}
+
+		encoding := acceptedEncoding(ctx.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			ctx.Next()
+			return
+		}
+
+		w := &compressWriter{
+			ResponseWriter: ctx.Writer,
+			encoding:       encoding,
+			level:          level,
+			minSize:        minSize,
+			contentTypes:   cfg.ContentTypes,
+			status:         http.StatusOK,
+		}
+		ctx.Writer = w
+		defer func() {
+			if err := recover(); err != nil {
+				// the panic is answered by recoverPanics on the underlying writer, closing w would send
+				// the buffered body with a 200 first
+				ctx.Writer = w.ResponseWriter
+				panic(err)
+			}
+			w.close()
+		}()
+
+		ctx.Next()
+	}
+}
+
+// acceptedEncoding picks gzip or deflate from the Accept-Encoding header, gzip first.
+func acceptedEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		disabled := false
+		for _, param := range fields[1:] {
+			param = strings.ReplaceAll(strings.TrimSpace(param), " ", "")
+			if param == "q=0" || param == "q=0.0" || param == "q=0.00" || param == "q=0.000" {
+				disabled = true
+			}
+		}
+		accepted[name] = !disabled
+	}
+
+	if accepted["gzip"] {
+		return "gzip"
+	}
+	if accepted["deflate"] {
+		return "deflate"
+	}
+	return ""
+}
+
+// compressWriter buffers the body until it's known whether it should be compressed.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding     string
+	level        int
+	minSize      int
+	contentTypes []string
+
+	status  int
+	buf     bytes.Buffer
+	decided bool
+	cw      io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.cw != nil {
+			return w.cw.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+
+	n, err := w.buf.Write(data)
+	if w.buf.Len() >= w.minSize {
+		w.decide(true)
+	}
+	return n, err
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Written() bool {
+	return w.decided || w.buf.Len() > 0
+}
+
+func (w *compressWriter) Status() int {
+	if w.decided {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(w.buf.Len() >= w.minSize)
+	}
+	if f, ok := w.cw.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide writes the header and the buffered body, compressed or not.
+func (w *compressWriter) decide(large bool) {
+	w.decided = true
+	header := w.Header()
+	if large && header.Get("Content-Encoding") == "" && w.compressible(header.Get("Content-Type")) &&
+		w.status != http.StatusNoContent && w.status != http.StatusNotModified {
+		header.Set("Content-Encoding", w.encoding)
+		header.Add("Vary", "Accept-Encoding")
+		header.Del("Content-Length")
+		if w.encoding == "gzip" {
+			w.cw, _ = gzip.NewWriterLevel(w.ResponseWriter, w.level)
+		} else {
+			w.cw, _ = flate.NewWriter(w.ResponseWriter, w.level)
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buf.Len() == 0 {
+		return
+	}
+	if w.cw != nil {
+		w.cw.Write(w.buf.Bytes())
+	} else {
+		w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+}
+
+func (w *compressWriter) compressible(contentType string) bool {
+	if len(w.contentTypes) == 0 {
+		return true
+	}
+	for _, t := range w.contentTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *compressWriter) close() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.cw != nil {
+		w.cw.Close()
+	}
+}
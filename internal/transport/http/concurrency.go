@@ -0,0 +1,139 @@
+package http
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/jaeger_service"
+	"openobserve-jaeger/internal/metrics"
+	"openobserve-jaeger/internal/requestid"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const defaultConcurrencyMaxWait = 10 // second
+
+var (
+	inFlightRequests = metrics.NewGauge("in_flight_requests", "Requests holding a slot of the concurrency limit.", "class")
+	queuedRequests   = metrics.NewGauge("queued_requests", "Requests waiting for a slot of the concurrency limit.", "class")
+	rejectedRequests = metrics.NewCounter("concurrency_rejected_requests_total", "Requests rejected with 503 by the concurrency limit.", "class", "reason")
+)
+
+// inFlightLimiter lets max_in_flight requests of a route class through at once, the others wait
+// in a bounded queue for up to max_wait.
+type inFlightLimiter struct {
+	class    string
+	slots    chan struct{}
+	maxQueue int64
+	maxWait  time.Duration
+
+	queued int64
+}
+
+func newInFlightLimiter(class string, limit config.ConcurrencyLimit) *inFlightLimiter {
+	if limit.MaxInFlight <= 0 {
+		return nil
+	}
+	maxWait := time.Duration(limit.MaxWait) * time.Second
+	if limit.MaxWait <= 0 {
+		maxWait = defaultConcurrencyMaxWait * time.Second
+	}
+	return &inFlightLimiter{
+		class:    class,
+		slots:    make(chan struct{}, limit.MaxInFlight),
+		maxQueue: int64(limit.MaxQueue),
+		maxWait:  maxWait,
+	}
+}
+
+// acquire takes a slot, waiting in the queue if there is none. It returns the reason it got none: the
+// queue is full, max_wait passed or the client went away.
+func (l *inFlightLimiter) acquire(ctx *gin.Context) (bool, string) {
+	select {
+	case l.slots <- struct{}{}:
+		return true, ""
+	default:
+	}
+
+	if queued := atomic.AddInt64(&l.queued, 1); l.maxQueue > 0 && queued > l.maxQueue {
+		atomic.AddInt64(&l.queued, -1)
+		return false, "queue_full"
+	}
+	queuedRequests.Add(1, l.class)
+	defer func() {
+		atomic.AddInt64(&l.queued, -1)
+		queuedRequests.Add(-1, l.class)
+	}()
+
+	timer := time.NewTimer(l.maxWait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true, ""
+	case <-timer.C:
+		return false, "timeout"
+	case <-ctx.Request.Context().Done():
+		return false, "canceled"
+	}
+}
+
+func (l *inFlightLimiter) release() {
+	<-l.slots
+}
+
+// limitInFlight holds a slot of the limiter while the request is served, the requests getting none
+// are rejected with 503. A nil limiter lets everything through.
+func limitInFlight(l *inFlightLimiter) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if l == nil {
+			ctx.Next()
+			return
+		}
+
+		start := time.Now()
+		ok, reason := l.acquire(ctx)
+		if !ok {
+			rejectedRequests.Inc(l.class, reason)
+			requestid.Logf(ctx, "concurrency limit of %s requests, %s rejected: %s after %s", l.class, ctx.Request.URL.Path, reason, time.Since(start).Round(time.Millisecond))
+			msg := fmt.Sprintf("too many concurrent %s requests, no slot was free within %s, please retry later", l.class, l.maxWait)
+			if reason == "queue_full" {
+				msg = fmt.Sprintf("too many concurrent %s requests, %d are waiting already, please retry later", l.class, l.maxQueue)
+			}
+			ctx.Header("Retry-After", strconv.Itoa(int(l.maxWait.Seconds())))
+			ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, jaeger_service.JaegerStructuredResponse{
+				Errors: []jaeger_service.JaegerStructuredError{
+					{
+						Code: http.StatusServiceUnavailable,
+						Msg:  msg,
+					},
+				},
+			})
+			return
+		}
+
+		inFlightRequests.Add(1, l.class)
+		defer func() {
+			inFlightRequests.Add(-1, l.class)
+			l.release()
+		}()
+		ctx.Next()
+	}
+}
+
+// inFlightLimiters are the limiters of the trace searches and of the trace details.
+type inFlightLimiters struct {
+	search *inFlightLimiter
+	trace  *inFlightLimiter
+}
+
+func newInFlightLimiters(cfg config.ConcurrencyConfig) inFlightLimiters {
+	if !cfg.Enabled {
+		return inFlightLimiters{}
+	}
+	return inFlightLimiters{
+		search: newInFlightLimiter("search", cfg.Search),
+		trace:  newInFlightLimiter("trace", cfg.Trace),
+	}
+}
@@ -0,0 +1,75 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"openobserve-jaeger/internal/config"
+	"strconv"
+	"strings"
+)
+
+var (
+	defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodOptions}
+	defaultCORSHeaders = []string{"Authorization", "Content-Type"}
+)
+
+// cors adds the CORS headers for allowed origins and answers the preflight requests,
+// it must run before authenticate as browsers send preflights without credentials.
+func cors(cfg config.CORSConfig) gin.HandlerFunc {
+	anyOrigin := false
+	origins := make(map[string]struct{}, len(cfg.AllowedOrigins))
+	for _, o := range cfg.AllowedOrigins {
+		if o == "*" {
+			anyOrigin = true
+		}
+		origins[strings.TrimRight(o, "/")] = struct{}{}
+	}
+
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	headers := cfg.AllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(headers, ", ")
+
+	return func(ctx *gin.Context) {
+		origin := ctx.GetHeader("Origin")
+		if !cfg.Enabled || origin == "" {
+			ctx.Next()
+			return
+		}
+
+		if _, ok := origins[origin]; !ok && !anyOrigin {
+			ctx.Next()
+			return
+		}
+
+		h := ctx.Writer.Header()
+		h.Add("Vary", "Origin")
+		if anyOrigin && !cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if ctx.Request.Method != http.MethodOptions || ctx.GetHeader("Access-Control-Request-Method") == "" {
+			ctx.Next()
+			return
+		}
+
+		// preflight
+		h.Set("Access-Control-Allow-Methods", allowMethods)
+		h.Set("Access-Control-Allow-Headers", allowHeaders)
+		if cfg.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+		}
+		ctx.AbortWithStatus(http.StatusNoContent)
+	}
+}
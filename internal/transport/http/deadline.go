@@ -0,0 +1,76 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/jaeger_service"
+	"strconv"
+	"time"
+)
+
+const timeoutHeader = "X-Timeout"
+
+// deadlineExempt are the routes bounded by their own config, e.g. the tail stream by tail.max_duration
+var deadlineExempt = map[string]bool{
+	"/api/traces/:id/spans": true,
+}
+
+// deadlineBudget gives the request context a deadline, the X-Timeout header (a duration like 30s or
+// seconds) capped at max_request_timeout, or request_timeout. The OpenObserve queries of the request
+// are cancelled with it and get the remaining budget as their query timeout, a client going away
+// cancels the request context as well.
+func deadlineBudget() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if deadlineExempt[ctx.FullPath()] {
+			ctx.Next()
+			return
+		}
+
+		budget, err := requestBudget(ctx.GetHeader(timeoutHeader), config.Get())
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, jaeger_service.JaegerStructuredResponse{
+				Errors: []jaeger_service.JaegerStructuredError{
+					{
+						Code: http.StatusBadRequest,
+						Msg:  err.Error(),
+					},
+				},
+			})
+			return
+		}
+
+		if budget > 0 {
+			reqCtx, cancel := context.WithTimeout(ctx.Request.Context(), budget)
+			defer cancel()
+			ctx.Request = ctx.Request.WithContext(reqCtx)
+		}
+		ctx.Next()
+	}
+}
+
+// requestBudget returns the deadline budget of a request with the X-Timeout header value, 0 means none.
+func requestBudget(header string, cfg *config.Config) (time.Duration, error) {
+	if header == "" {
+		return time.Duration(cfg.RequestTimeout) * time.Second, nil
+	}
+
+	budget, err := time.ParseDuration(header)
+	if err != nil {
+		seconds, serr := strconv.ParseFloat(header, 64)
+		if serr != nil {
+			return 0, fmt.Errorf("invalid %s header %q, expecting a duration like 30s or seconds", timeoutHeader, header)
+		}
+		budget = time.Duration(seconds * float64(time.Second))
+	}
+	if budget <= 0 {
+		return 0, fmt.Errorf("invalid %s header %q, it must be > 0", timeoutHeader, header)
+	}
+
+	if limit := time.Duration(cfg.MaxRequestTimeout) * time.Second; limit > 0 && budget > limit {
+		budget = limit
+	}
+	return budget, nil
+}
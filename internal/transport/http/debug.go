@@ -0,0 +1,60 @@
+package http
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/openobserve_service"
+	"runtime"
+	"sync"
+)
+
+const defaultDebugAddr = "localhost:6060"
+
+var publishRuntimeStats sync.Once
+
+// NewDebugServer serves the pprof profiles under /debug/pprof/ and the runtime stats under /debug/vars
+// on debug.addr, apart from the api port so they are never reachable through the public listener.
+func NewDebugServer(cfg config.DebugConfig) *http.Server {
+	publishRuntimeStats.Do(func() {
+		expvar.Publish("runtime", expvar.Func(runtimeStats))
+		expvar.Publish("openobserve_conns", expvar.Func(func() interface{} {
+			return openobserve_service.GetConnStats()
+		}))
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	addr := cfg.Addr
+	if addr == "" {
+		addr = defaultDebugAddr
+	}
+	return &http.Server{Addr: addr, Handler: mux}
+}
+
+// runtimeStats are the goroutine, heap and gc figures of the process, read on every /debug/vars request.
+func runtimeStats() interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return map[string]interface{}{
+		"goroutines":     runtime.NumGoroutine(),
+		"gomaxprocs":     runtime.GOMAXPROCS(0),
+		"heap_alloc":     m.HeapAlloc,
+		"heap_inuse":     m.HeapInuse,
+		"heap_idle":      m.HeapIdle,
+		"heap_released":  m.HeapReleased,
+		"heap_objects":   m.HeapObjects,
+		"sys":            m.Sys,
+		"num_gc":         m.NumGC,
+		"gc_pause_total": m.PauseTotalNs,
+		"gc_pause_last":  m.PauseNs[(m.NumGC+255)%256],
+		"next_gc":        m.NextGC,
+	}
+}
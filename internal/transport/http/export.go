@@ -0,0 +1,77 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"net/http"
+	"openobserve-jaeger/internal/errors"
+	"openobserve-jaeger/internal/jaeger_service"
+	"openobserve-jaeger/internal/requestid"
+)
+
+const formatParam = "format"
+
+// ExportTrace serves /api/traces/:id/export?format=jaeger|otlp|csv, the trace as a file download: the jaeger
+// JSON the UI uploads (default), the OTLP JSON export request or the spans as CSV rows.
+func (s *jaegerServerRoute) ExportTrace(ctx *gin.Context) {
+	format := ctx.DefaultQuery(formatParam, jaeger_service.ExportJaeger)
+	var contentType, ext string
+	switch format {
+	case jaeger_service.ExportJaeger:
+		contentType, ext = "application/json", "json"
+	case jaeger_service.ExportOTLP:
+		contentType, ext = "application/json", "otlp.json"
+	case jaeger_service.ExportCSV:
+		contentType, ext = "text/csv; charset=utf-8", "csv"
+	default:
+		ctx.JSON(http.StatusBadRequest, jaeger_service.JaegerStructuredResponse{
+			Errors: []jaeger_service.JaegerStructuredError{
+				{
+					Code: http.StatusBadRequest,
+					Msg: fmt.Sprintf("parameter '%s' must be one of %s, %s or %s", formatParam,
+						jaeger_service.ExportJaeger, jaeger_service.ExportOTLP, jaeger_service.ExportCSV),
+				},
+			},
+		})
+		return
+	}
+
+	q, err := valideRequest(ctx)
+	if err != nil {
+		ctx.JSON(errors.HTTPStatus(err), jaeger_service.ErrorResponse(err))
+		return
+	}
+
+	resp := s.JaegerService.GetTrace(ctx, q)
+	attachRequestID(ctx, &resp)
+	traces, _ := resp.Data.([]*ui.Trace)
+	if len(resp.Errors) > 0 || len(traces) == 0 || traces[0] == nil || len(traces[0].Spans) == 0 {
+		if len(resp.Errors) == 0 {
+			resp.Errors = append(resp.Errors, jaeger_service.JaegerStructuredError{Code: http.StatusNotFound, Msg: "trace not found"})
+		}
+		ctx.JSON(resp.StatusCode(), resp)
+		return
+	}
+	trace := traces[0]
+
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="trace-%s.%s"`, trace.TraceID, ext))
+	ctx.Status(http.StatusOK)
+	switch format {
+	case jaeger_service.ExportJaeger:
+		err = json.NewEncoder(ctx.Writer).Encode(jaeger_service.JaegerStructuredResponse{
+			Data:   traces,
+			Total:  len(traces),
+			Errors: make([]jaeger_service.JaegerStructuredError, 0),
+		})
+	case jaeger_service.ExportOTLP:
+		err = json.NewEncoder(ctx.Writer).Encode(jaeger_service.TraceToOTLP(trace))
+	case jaeger_service.ExportCSV:
+		err = jaeger_service.WriteTraceCSV(ctx.Writer, trace)
+	}
+	if err != nil {
+		requestid.Logf(ctx, "ExportTrace %s err: %v", format, err)
+	}
+}
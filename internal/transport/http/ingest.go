@@ -0,0 +1,60 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"io"
+	"mime"
+	"net/http"
+	"openobserve-jaeger/internal/errors"
+	"openobserve-jaeger/internal/ingest"
+)
+
+// otlpStatus is the JSON form of the google.rpc.Status body of the failed OTLP/HTTP responses
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// ingestTraces serves the OTLP/HTTP trace export on POST /v1/traces, the spans are taken in the
+// forwarder's queue and the exporter gets the success response at once.
+func ingestTraces(f *ingest.Forwarder) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		mediaType, _, _ := mime.ParseMediaType(ctx.GetHeader("Content-Type"))
+		var protobuf bool
+		switch mediaType {
+		case ingest.ContentTypeJSON:
+		case ingest.ContentTypeProtobuf, "application/protobuf":
+			protobuf = true
+		default:
+			ctx.JSON(http.StatusUnsupportedMediaType, otlpStatus{
+				Code:    http.StatusUnsupportedMediaType,
+				Message: "Content-Type must be application/json or application/x-protobuf",
+			})
+			return
+		}
+
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, otlpStatus{Code: http.StatusBadRequest, Message: err.Error()})
+			return
+		}
+
+		if err := f.Enqueue(body, protobuf, ctx.GetString(authUserKey)); err != nil {
+			code := errors.HTTPStatus(err)
+			if err == ingest.ErrQueueFull {
+				// OTLP exporters retry a 503 after Retry-After
+				code = http.StatusServiceUnavailable
+				ctx.Header("Retry-After", "1")
+			}
+			ctx.JSON(code, otlpStatus{Code: code, Message: err.Error()})
+			return
+		}
+
+		// an empty ExportTraceServiceResponse
+		if protobuf {
+			ctx.Data(http.StatusOK, ingest.ContentTypeProtobuf, nil)
+			return
+		}
+		ctx.Data(http.StatusOK, ingest.ContentTypeJSON, []byte("{}"))
+	}
+}
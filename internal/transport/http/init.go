@@ -1,37 +1,191 @@
 package http
 
 import (
+	"context"
 	"github.com/gin-gonic/gin"
+	"log"
 	"net/http"
+	"openobserve-jaeger/internal/audit"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/ingest"
 	"openobserve-jaeger/internal/jaeger_service"
+	"openobserve-jaeger/internal/metrics"
+	"openobserve-jaeger/internal/openobserve_service"
+	"openobserve-jaeger/internal/timing"
+	"openobserve-jaeger/internal/version"
+	"strings"
 )
 
+// shutdownHooks run once the server stopped serving, e.g. to forward the queued spans
+var shutdownHooks []func(ctx context.Context) error
+
+// Shutdown runs the shutdown hooks, after the http server drained the in-flight requests.
+func Shutdown(ctx context.Context) {
+	for _, hook := range shutdownHooks {
+		if err := hook(ctx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+	}
+}
+
 type Hanlder func(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error)
 
 func wrapResponse(h Hanlder) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		response, err := h(ctx)
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+			errResp := jaeger_service.ErrorResponse(err)
+			response = &errResp
 		}
 
-		if len(response.Errors) > 0 {
-			ctx.JSON(response.Errors[0].Code, response)
+		attachDebugTimings(ctx, response)
+		attachDebugBackends(ctx, response)
+		attachRequestID(ctx, response)
+		attachWarnings(ctx, response)
+		setCacheControl(ctx, response)
+		recordResults(ctx, response)
+		applyResponseCompat(response)
+		defer timing.Track(ctx, timing.PhaseEncode)()
+
+		ctx.JSON(response.StatusCode(), compatResponse(response))
+	}
+}
+
+// traceActions serves the custom methods POST /api/traces:<action>, the paths of gin have no literal colon
+// so the route is a parameter starting at the colon.
+func traceActions(actions map[string]gin.HandlerFunc) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		action := ctx.Param("action")
+		if h, ok := actions[strings.TrimPrefix(action, ":")]; ok && strings.HasPrefix(action, ":") {
+			h(ctx)
 			return
 		}
-
-		ctx.JSON(http.StatusOK, response)
+		ctx.JSON(http.StatusNotFound, jaeger_service.JaegerStructuredResponse{
+			Errors: []jaeger_service.JaegerStructuredError{
+				{
+					Code: http.StatusNotFound,
+					Msg:  "unknown trace action " + action,
+				},
+			},
+		})
 	}
 }
-func NewHTTPServer() *gin.Engine {
+
+func NewHTTPServer() (http.Handler, error) {
 	j := NewJaegerServer()
 
-	engine := gin.Default()
+	if cfg := config.Get().Warmup; cfg.Enabled {
+		j.JaegerService.StartWarmup(cfg)
+	}
+	if cfg := config.Get().QualityMetrics; cfg.ScanInterval > 0 {
+		j.JaegerService.StartQualityScan(cfg)
+	}
+
+	engine, err := newEngine(config.Get().Server)
+	if err != nil {
+		return nil, err
+	}
+	// lets the handlers pass ctx on with the request span
+	engine.ContextWithFallback = true
+
+	engine.Use(identifyRequest())
+	engine.Use(recoverPanics())
+	engine.Use(traceRequest())
+	engine.Use(timeRequest())
+	engine.Use(deadlineBudget())
+	engine.Use(compress(config.Get().Compression))
+	engine.Use(cors(config.Get().CORS))
+	engine.Use(authenticate(config.Get().Auth))
+	engine.Use(limitRequestBody(config.Get().RequestBody))
 
-	engine.GET("/api/traces", wrapResponse(j.SearchTraces))
-	engine.GET("/api/traces/:id", wrapResponse(j.GetTrace))
-	engine.GET("/api/services", wrapResponse(j.GetService))
-	engine.GET("/api/services/:servicename/operations", wrapResponse(j.GetOperations))
-	return engine
+	heap := newHeapMonitor(config.Get().LoadShedding)
+	limits := newRateLimiters(config.Get().RateLimit)
+	traces, metadata := rateLimit(limits.traces), rateLimit(limits.metadata)
+	inFlight := newInFlightLimiters(config.Get().Concurrency)
+	searches, details := limitInFlight(inFlight.search), limitInFlight(inFlight.trace)
+
+	var auditLogger *audit.Logger
+	if cfg := config.Get().Audit; cfg.Enabled {
+		var err error
+		if auditLogger, err = audit.NewLogger(cfg, openobserve_service.NewOpenObserveService().IngestLogs); err != nil {
+			return nil, err
+		}
+	}
+	audited := auditRequest(auditLogger)
+
+	engine.GET("/healthz", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+	engine.GET("/metrics", gin.WrapH(metrics.Handler()))
+	if cfg := config.Get().SpanMetrics; cfg.Enabled {
+		engine.GET("/metrics/spanmetrics", gin.WrapH(j.JaegerService.NewSpanMetrics(cfg).Handler()))
+	}
+	engine.GET("/api/version", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, version.Get())
+	})
+
+	engine.GET("/api/traces", audited, traces, shedLoad(heap), searches, wrapResponse(j.SearchTraces))
+	engine.GET("/api/traces/histogram", traces, wrapResponse(j.GetDurationHistogram))
+	engine.GET("/api/traces/compare", audited, traces, shedLoad(heap), searches, wrapResponse(j.CompareTraces))
+	engine.POST("/api/traces:action", audited, traces, shedLoad(heap), searches, traceActions(map[string]gin.HandlerFunc{
+		"batch": wrapResponse(j.BatchTraces),
+	}))
+	engine.GET("/api/traces/:id", audited, traces, shedLoad(heap), details, wrapChunkedResponse(j.GetTrace))
+	engine.HEAD("/api/traces/:id", traces, wrapResponse(j.TraceExists))
+	engine.GET("/api/traces/:id/exists", traces, wrapResponse(j.TraceExists))
+	engine.GET("/api/traces/:id/linked", traces, wrapResponse(j.GetLinkedTraces))
+	engine.GET("/api/traces/:id/stats", traces, shedLoad(heap), details, wrapResponse(j.GetTraceStats))
+	engine.GET("/api/traces/:id/export", audited, traces, shedLoad(heap), details, j.ExportTrace)
+	engine.GET("/api/traces/:id/spans", traces, j.TailTraceSpans)
+	engine.GET("/api/services", metadata, wrapResponse(j.GetService))
+	engine.GET("/api/services/:servicename/operations", metadata, wrapResponse(j.GetOperations))
+	engine.GET("/api/operations", metadata, wrapResponse(j.GetOperationsWithSpanKind))
+	engine.GET("/api/service_tags", metadata, wrapResponse(j.GetServiceTags))
+	engine.GET("/api/service_tags/:key/values", metadata, wrapResponse(j.GetServiceTagValues))
+	engine.GET("/api/dependencies", traces, wrapResponse(j.GetDependencies))
+	engine.GET("/api/servicemap", traces, wrapResponse(j.GetServiceMap))
+	engine.GET("/api/quality", traces, wrapResponse(j.GetQualityReport))
+	engine.GET("/api/quality-metrics", metadata, wrapResponse(j.GetQualityMetrics))
+	engine.GET("/api/exemplars", traces, wrapResponse(j.FindExemplar))
+	if cfg := config.Get().RawSearch; cfg.Enabled {
+		engine.POST("/api/raw-search", requireUsers(cfg.Users), audited, traces, shedLoad(heap), searches, wrapResponse(j.RawSearch))
+	}
+	if cfg := config.Get().SearchJobs; cfg.Enabled {
+		engine.POST("/api/search-jobs", audited, traces, shedLoad(heap), wrapResponse(j.SubmitSearchJob))
+		engine.GET("/api/search-jobs/:id", metadata, wrapResponse(j.GetSearchJob))
+		engine.DELETE("/api/search-jobs/:id", metadata, wrapResponse(j.CancelSearchJob))
+	}
+
+	engine.POST("/api/ingest/validate", metadata, wrapResponse(j.ValidateIngest))
+	if cfg := config.Get().Ingest; cfg.Enabled {
+		forwarder := ingest.NewForwarder(cfg)
+		shutdownHooks = append(shutdownHooks, forwarder.Close)
+		engine.POST("/v1/traces", ingestTraces(forwarder))
+	}
+
+	zipkin := engine.Group("/zipkin/api/v2")
+	zipkin.GET("/traces", traces, shedLoad(heap), searches, j.ZipkinTraces)
+	zipkin.GET("/trace/:id", traces, shedLoad(heap), details, j.ZipkinTrace)
+	zipkin.GET("/services", metadata, j.ZipkinServices)
+	zipkin.GET("/spans", metadata, j.ZipkinSpans)
+
+	if cfg := config.Get().CrossOrg; cfg.Enabled {
+		engine.GET("/admin/traces/:id", requireUsers(cfg.Users), traces, shedLoad(heap), wrapResponse(j.FindTraceAcrossOrgs))
+	}
+	if cfg := config.Get().Admin; cfg.Enabled {
+		admin := engine.Group("/admin", requireUsers(cfg.Users))
+		admin.GET("/config", j.GetAdminConfig)
+		admin.POST("/cache/flush", wrapResponse(j.FlushCache))
+		admin.GET("/settings", wrapResponse(j.GetAdminSettings))
+		admin.PUT("/settings", wrapResponse(j.UpdateAdminSettings))
+		admin.GET("/diagnostics/convert", traces, shedLoad(heap), wrapResponse(j.DiagnoseConversion))
+	}
+	if cfg := config.Get().UI; cfg.Enabled {
+		ui, err := serveUI(cfg)
+		if err != nil {
+			return nil, err
+		}
+		engine.NoRoute(ui)
+	}
+	return stripBasePath(engine, config.Get().UI.BasePath), nil
 }
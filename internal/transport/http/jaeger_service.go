@@ -3,12 +3,24 @@ package http
 import (
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"log"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/errors"
 	"openobserve-jaeger/internal/jaeger_service"
 	"openobserve-jaeger/internal/openobserve_service"
+	"openobserve-jaeger/internal/requestid"
+	"openobserve-jaeger/internal/timing"
+	"strconv"
 	"time"
 )
 
+const (
+	defaultBatchMaxTraces   = 100
+	defaultBatchConcurrency = 8
+	defaultTagValuesLimit   = 100
+	maxTagValuesLimit       = 1000
+)
+
 type jaegerServerRoute struct {
 	JaegerService *jaeger_service.JaegerService
 }
@@ -21,33 +33,104 @@ func NewJaegerServer() *jaegerServerRoute {
 
 // const traceIDParam = "traceID"
 func (s *jaegerServerRoute) SearchTraces(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
-	jaegerResp := jaeger_service.JaegerStructuredResponse{
-		Data:   make([]string, 0),
-		Errors: make([]jaeger_service.JaegerStructuredError, 0),
-	}
-
+	done := timing.Track(ctx, timing.PhaseParse)
 	traceQueryParameters, err := qp.parseTraceQueryParams(ctx, ctx.Request)
+	done()
+	if err == nil {
+		err = parseTraceFields(ctx)
+	}
 	if err != nil {
-
-		jaegerResp.Errors = append(jaegerResp.Errors, jaeger_service.JaegerStructuredError{
-			Code: 405,
-			Msg:  err.Error(),
-		})
-
-		return &jaegerResp, nil
+		return nil, errors.BadRequest(err.Error())
 	}
 
-	jaegerResp = s.JaegerService.FindTraces(ctx, &traceQueryParameters.TraceQueryParameters)
+	jaegerResp := s.JaegerService.FindTraces(ctx, &traceQueryParameters.TraceQueryParameters)
 	return &jaegerResp, nil
 }
 
 func (s *jaegerServerRoute) GetTrace(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
 	q, err := valideRequest(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("start_time or end_time is not correct: %v", err)
+		return nil, err
+	}
+	requestid.Debugf(ctx, "valideRequest, q: %v", q)
+	sizeOnly, err := parseBool(ctx.Request, sizeOnlyParam)
+	if err == nil {
+		err = parseTraceFields(ctx)
+	}
+	if err != nil {
+		return nil, errors.BadRequest(err.Error())
 	}
-	log.Printf("valideRequest, q: %v", q)
+
 	jaegerStructuredResponse := s.JaegerService.GetTrace(ctx, q)
+	// ?sizeOnly=true answers with the size alone, for deciding whether the trace is worth downloading
+	if traces, ok := jaegerStructuredResponse.Data.([]*ui.Trace); ok && sizeOnly {
+		size := jaeger_service.MeasureTraces(traces)
+		jaegerStructuredResponse.Size = &size
+		jaegerStructuredResponse.Data = make([]*ui.Trace, 0)
+	}
+	return &jaegerStructuredResponse, nil
+}
+
+// GetLinkedTraces serves /api/traces/:id/linked
+func (s *jaegerServerRoute) GetLinkedTraces(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	q, err := valideRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	jaegerStructuredResponse := s.JaegerService.GetLinkedTraces(ctx, q)
+	return &jaegerStructuredResponse, nil
+}
+
+// GetTraceStats serves /api/traces/:id/stats, the span counts, durations and errors of the trace by service and operation
+func (s *jaegerServerRoute) GetTraceStats(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	q, err := valideRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	jaegerStructuredResponse := s.JaegerService.GetTraceStats(ctx, q)
+	return &jaegerStructuredResponse, nil
+}
+
+// TraceExists serves GET /api/traces/:id/exists and HEAD /api/traces/:id, 200 with the services and time bounds of
+// the trace in trace_list_index or 404, without fetching its spans
+func (s *jaegerServerRoute) TraceExists(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	q, err := valideRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	jaegerStructuredResponse := s.JaegerService.TraceExists(ctx, q)
+	return &jaegerStructuredResponse, nil
+}
+
+// CompareTraces serves /api/traces/compare?a=&b=, the optional start_time and end_time apply to both traces
+func (s *jaegerServerRoute) CompareTraces(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	q, err := valideRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{ctx.Query(compareAParam), ctx.Query(compareBParam)}
+	for i := range ids {
+		if ids[i], err = jaeger_service.NormalizeTraceID(ids[i]); err != nil {
+			return nil, errors.BadRequest(fmt.Sprintf("parameters '%s' and '%s' must be trace ids of at most 32 hex characters", compareAParam, compareBParam))
+		}
+	}
+
+	jaegerStructuredResponse := s.JaegerService.CompareTraces(ctx, q, ids[0], ids[1])
+	return &jaegerStructuredResponse, nil
+}
+
+// FindTraceAcrossOrgs serves /admin/traces/:id
+func (s *jaegerServerRoute) FindTraceAcrossOrgs(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	q, err := valideRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	jaegerStructuredResponse := s.JaegerService.FindTraceAcrossOrgs(ctx, q)
 	return &jaegerStructuredResponse, nil
 }
 
@@ -55,7 +138,7 @@ func (s *jaegerServerRoute) GetService(ctx *gin.Context) (*jaeger_service.Jaeger
 
 	q, err := valideRequest(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("start_time or end_time is not correct: %v", err)
+		return nil, err
 	}
 
 	jaegerStructuredResponse := s.JaegerService.GetService(ctx, q)
@@ -66,18 +149,300 @@ func (s *jaegerServerRoute) GetService(ctx *gin.Context) (*jaeger_service.Jaeger
 func (s *jaegerServerRoute) GetOperations(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
 	q, err := valideRequest(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("start_time or end_time is not correct: %v", err)
+		return nil, err
 	}
 
 	jaegerStructuredResponse := s.JaegerService.GetOperations(ctx, q)
 	return &jaegerStructuredResponse, nil
 }
 
+// GetOperationsWithSpanKind serves /api/operations?service=&spanKind=
+func (s *jaegerServerRoute) GetOperationsWithSpanKind(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	q, err := valideRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q.ServiceName = ctx.Query(serviceParam)
+	if q.ServiceName == "" {
+		return nil, errors.BadRequest(errServiceParameterRequired.Error())
+	}
+
+	jaegerStructuredResponse := s.JaegerService.GetOperationsWithSpanKind(ctx, q, ctx.Query(spanKindParam))
+	return &jaegerStructuredResponse, nil
+}
+
+const defaultDependenciesLookback = 24 * time.Hour
+
+// GetDependencies serves /api/dependencies?endTs=&lookback= with both in milliseconds, like jaeger-query
+func (s *jaegerServerRoute) GetDependencies(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	endTs, lookback, err := parseDependenciesWindow(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	jaegerStructuredResponse := s.JaegerService.GetDependencies(ctx, endTs, lookback)
+	return &jaegerStructuredResponse, nil
+}
+
+// parseDependenciesWindow parses the endTs and lookback query args in milliseconds, now and 24 hours by default.
+func parseDependenciesWindow(ctx *gin.Context) (time.Time, time.Duration, error) {
+	endTs := time.Now()
+	if v := ctx.Query("endTs"); v != "" {
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return time.Time{}, 0, newParseError(err, "endTs")
+		}
+		endTs = time.Unix(0, ms*int64(time.Millisecond))
+	}
+
+	lookback, err := qp.parseLookback(ctx, defaultDependenciesLookback)
+	return endTs, lookback, err
+}
+
+// GetServiceMap serves /api/servicemap?endTs=&lookback= with both in milliseconds like /api/dependencies, the
+// edges with call and error counts and p95 durations for the grafana node graph
+func (s *jaegerServerRoute) GetServiceMap(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	endTs, lookback, err := parseDependenciesWindow(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	jaegerStructuredResponse := s.JaegerService.GetServiceMap(ctx, endTs, lookback)
+	return &jaegerStructuredResponse, nil
+}
+
+// GetDurationHistogram serves /api/traces/histogram?service=&operation=&start=&end= with start and end
+// in unix microseconds like /api/traces
+func (s *jaegerServerRoute) GetDurationHistogram(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	service, start, end, err := parseServiceTimeRange(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	jaegerStructuredResponse := s.JaegerService.GetDurationHistogram(ctx, service, ctx.Query(operationParam), start, end)
+	return &jaegerStructuredResponse, nil
+}
+
+// FindExemplar serves /api/exemplars?service=&operation=&percentile=99&start=&end= with start and end
+// in unix microseconds like /api/traces, the percentile is 99 by default
+func (s *jaegerServerRoute) FindExemplar(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	service, start, end, err := parseServiceTimeRange(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	percentile := 99.0
+	if v := ctx.Query(percentileParam); v != "" {
+		percentile, err = strconv.ParseFloat(v, 64)
+		if err != nil || percentile <= 0 || percentile > 100 {
+			return nil, errors.BadRequest(fmt.Sprintf("parameter '%s' must be a number in (0, 100]", percentileParam))
+		}
+	}
+
+	jaegerStructuredResponse := s.JaegerService.FindExemplar(ctx, service, ctx.Query(operationParam), percentile, start, end)
+	return &jaegerStructuredResponse, nil
+}
+
+// GetQualityReport serves /api/quality?service=&start=&end= with start and end in unix microseconds
+func (s *jaegerServerRoute) GetQualityReport(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	service, start, end, err := parseServiceTimeRange(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	jaegerStructuredResponse := s.JaegerService.GetQualityReport(ctx, service, start, end)
+	return &jaegerStructuredResponse, nil
+}
+
+// GetQualityMetrics serves /api/quality-metrics?service=, the span completeness of the services, service is optional
+func (s *jaegerServerRoute) GetQualityMetrics(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	jaegerStructuredResponse := s.JaegerService.GetQualityMetrics(ctx, ctx.Query(serviceParam))
+	return &jaegerStructuredResponse, nil
+}
+
+// GetServiceTags serves /api/service_tags?service=&start=&end=, the tag keys set on the spans of the service
+func (s *jaegerServerRoute) GetServiceTags(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	service, start, end, err := parseServiceTimeRange(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	jaegerStructuredResponse := s.JaegerService.GetServiceTags(ctx, service, start, end)
+	return &jaegerStructuredResponse, nil
+}
+
+// GetServiceTagValues serves /api/service_tags/:key/values?service=&start=&end=&limit=, the most frequent values of the tag
+func (s *jaegerServerRoute) GetServiceTagValues(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	service, start, end, err := parseServiceTimeRange(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := defaultTagValuesLimit
+	if v := ctx.Query(limitParam); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 || limit > maxTagValuesLimit {
+			return nil, errors.BadRequest(fmt.Sprintf("parameter '%s' must be an integer in [1, %d]", limitParam, maxTagValuesLimit))
+		}
+	}
+
+	jaegerStructuredResponse := s.JaegerService.GetServiceTagValues(ctx, service, ctx.Param("key"), start, end, limit)
+	return &jaegerStructuredResponse, nil
+}
+
+// parseServiceTimeRange parses the required service and the start and end in unix microseconds,
+// the last hour by default. Invalid parameters are a BadRequest.
+func parseServiceTimeRange(ctx *gin.Context) (string, time.Time, time.Time, error) {
+	service := ctx.Query(serviceParam)
+	if service == "" {
+		return "", time.Time{}, time.Time{}, errors.BadRequest(fmt.Sprintf("parameter '%s' is required", serviceParam))
+	}
+
+	start, err := qp.parseTime(ctx.Request, startTimeParam, time.Microsecond)
+	if err != nil {
+		return "", time.Time{}, time.Time{}, errors.BadRequest(err.Error())
+	}
+	end, err := qp.parseTime(ctx.Request, endTimeParam, time.Microsecond)
+	if err != nil {
+		return "", time.Time{}, time.Time{}, errors.BadRequest(err.Error())
+	}
+	start, end = qp.normalizeTimeRange(ctx, start, end)
+	if !end.After(start) {
+		return "", time.Time{}, time.Time{}, errors.BadRequest(errStartTimeGreaterThanStartTimeMax.Error())
+	}
+	return service, start, end, nil
+}
+
+func (s *jaegerServerRoute) DiagnoseConversion(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	q, err := valideRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q.TraceID, err = jaeger_service.NormalizeTraceID(ctx.Query(traceIDParam))
+	if err != nil {
+		return nil, errors.BadRequest(fmt.Sprintf("parameter '%s' is required and cannot be longer than 32 hex characters", traceIDParam))
+	}
+
+	jaegerStructuredResponse := s.JaegerService.DiagnoseConversion(ctx, q)
+	return &jaegerStructuredResponse, nil
+}
+
+// ValidateIngest serves POST /api/ingest/validate, the body is Jaeger JSON as downloaded from the UI: {"data":[trace...]}
+func (s *jaegerServerRoute) ValidateIngest(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	var body struct {
+		Data []*ui.Trace `json:"data"`
+	}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		return nil, errors.BadRequest("malformed body, expecting Jaeger JSON {\"data\":[traces]}: " + err.Error())
+	}
+
+	jaegerStructuredResponse := s.JaegerService.ValidateIngest(body.Data)
+	return &jaegerStructuredResponse, nil
+}
+
+// batchTracesBody is the body of POST /api/traces:batch, start_time and end_time are the optional time hints
+// of a trace, in unix seconds or microseconds like the start_time and end_time of GET /api/traces/:id.
+type batchTracesBody struct {
+	Traces []struct {
+		TraceID   string `json:"traceID"`
+		StartTime int64  `json:"start_time"`
+		EndTime   int64  `json:"end_time"`
+	} `json:"traces"`
+}
+
+// BatchTraces serves POST /api/traces:batch, the data maps every trace id to the trace or its error.
+func (s *jaegerServerRoute) BatchTraces(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	var body batchTracesBody
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		return nil, errors.BadRequest("malformed body, expecting {\"traces\":[{\"traceID\":\"...\",\"start_time\":0,\"end_time\":0}]}: " + err.Error())
+	}
+	cfg := config.Get().Batch
+	maxTraces := cfg.MaxTraces
+	if maxTraces <= 0 {
+		maxTraces = defaultBatchMaxTraces
+	}
+	if len(body.Traces) == 0 || len(body.Traces) > maxTraces {
+		return nil, errors.BadRequest(fmt.Sprintf("a batch takes 1 to %d traces, got %d", maxTraces, len(body.Traces)))
+	}
+
+	traces := make([]jaeger_service.BatchTrace, 0, len(body.Traces))
+	for _, t := range body.Traces {
+		traceID, err := jaeger_service.NormalizeTraceID(t.TraceID)
+		if err != nil {
+			return nil, errors.BadRequest(fmt.Sprintf("traceID %q must be a trace id of at most 32 hex characters", t.TraceID))
+		}
+		traces = append(traces, jaeger_service.BatchTrace{
+			TraceID:   traceID,
+			StartTime: unixTime(t.StartTime),
+			EndTime:   unixTime(t.EndTime),
+		})
+	}
+
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	jaegerStructuredResponse := s.JaegerService.BatchTraces(ctx, traces, concurrency)
+	return &jaegerStructuredResponse, nil
+}
+
+// rawSearchBody is the body of POST /api/raw-search, start and end are in unix seconds or microseconds,
+// they default to the last raw_search.max_range.
+type rawSearchBody struct {
+	SQL   string `json:"sql"`
+	Start int64  `json:"start"`
+	End   int64  `json:"end"`
+}
+
+// RawSearch serves POST /api/raw-search, the data is the hits of the statement.
+func (s *jaegerServerRoute) RawSearch(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	var body rawSearchBody
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		return nil, errors.BadRequest("malformed body, expecting {\"sql\":\"SELECT ...\",\"start\":0,\"end\":0}: " + err.Error())
+	}
+
+	jaegerStructuredResponse := s.JaegerService.RawSearch(ctx, body.SQL, unixTime(body.Start), unixTime(body.End))
+	return &jaegerStructuredResponse, nil
+}
+
+// SubmitSearchJob serves POST /api/search-jobs, it takes the query args of /api/traces and answers with
+// the job to poll at once.
+func (s *jaegerServerRoute) SubmitSearchJob(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	traceQueryParameters, err := qp.parseTraceQueryParams(ctx, ctx.Request)
+	if err == nil {
+		err = parseTraceFields(ctx)
+	}
+	if err != nil {
+		return nil, errors.BadRequest(err.Error())
+	}
+
+	jaegerStructuredResponse := s.JaegerService.SubmitSearchJob(ctx, &traceQueryParameters.TraceQueryParameters, ctx.GetString(authUserKey))
+	return &jaegerStructuredResponse, nil
+}
+
+// GetSearchJob serves GET /api/search-jobs/:id, the data is the traces once the job is done.
+func (s *jaegerServerRoute) GetSearchJob(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	jaegerStructuredResponse := s.JaegerService.GetSearchJob(ctx, ctx.Param("id"), ctx.GetString(authUserKey))
+	return &jaegerStructuredResponse, nil
+}
+
+// CancelSearchJob serves DELETE /api/search-jobs/:id
+func (s *jaegerServerRoute) CancelSearchJob(ctx *gin.Context) (*jaeger_service.JaegerStructuredResponse, error) {
+	jaegerStructuredResponse := s.JaegerService.CancelSearchJob(ctx, ctx.Param("id"), ctx.GetString(authUserKey))
+	return &jaegerStructuredResponse, nil
+}
+
 func valideRequest(ctx *gin.Context) (*openobserve_service.OOQuery, error) {
+	defer timing.Track(ctx, timing.PhaseParse)()
+
 	// 参数获取
 	traceID := ctx.Param("id")
-	if len(traceID) > 32 {
-		return nil, fmt.Errorf("TraceID cannot be longer than 32 hex characters: %s", traceID)
+	if traceID != "" {
+		var err error
+		if traceID, err = jaeger_service.NormalizeTraceID(traceID); err != nil {
+			return nil, err
+		}
 	}
 
 	servicename := ctx.Param("servicename")
@@ -95,24 +460,26 @@ func valideRequest(ctx *gin.Context) (*openobserve_service.OOQuery, error) {
 
 	err := ctx.BindQuery(&q)
 	if err != nil {
-		return nil, fmt.Errorf("start_time or end_time is not correct: %v", err)
+		return nil, errors.BadRequest(fmt.Sprintf("start_time or end_time is not correct: %v", err))
 	}
 
 	if q.StartTimeUnix > 0 {
-		if len(fmt.Sprintf("%d", q.StartTimeUnix)) < 16 {
-			q.StartTime = time.Unix(q.StartTimeUnix, 0)
-		} else {
-			q.StartTime = time.UnixMicro(q.StartTimeUnix)
-		}
+		q.StartTime = unixTime(q.StartTimeUnix)
 	}
-
 	if q.EndTimeUnix > 0 {
-		if len(fmt.Sprintf("%d", q.EndTimeUnix)) < 16 {
-			q.EndTime = time.Unix(q.EndTimeUnix, 0)
-		} else {
-			q.EndTime = time.UnixMicro(q.EndTimeUnix)
-		}
+		q.EndTime = unixTime(q.EndTimeUnix)
 	}
-
 	return q, nil
 }
+
+// unixTime converts the start_time and end_time of the trace apis, unix seconds or microseconds by their
+// digits, 0 is the zero time.
+func unixTime(v int64) time.Time {
+	if v <= 0 {
+		return time.Time{}
+	}
+	if len(fmt.Sprintf("%d", v)) < 16 {
+		return time.Unix(v, 0)
+	}
+	return time.UnixMicro(v)
+}
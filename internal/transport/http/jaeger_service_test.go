@@ -0,0 +1,39 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetTraceMalformedID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	j := NewJaegerServer()
+	engine := gin.New()
+	engine.GET("/api/traces/:id", wrapResponse(j.GetTrace))
+
+	for _, id := range []string{"not-a-trace-id", "123456789012345678901234567890123"} {
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/traces/"+id, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("GET /api/traces/%s: status %d, want 400, body %s", id, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestSearchTracesInvalidParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	j := NewJaegerServer()
+	engine := gin.New()
+	engine.GET("/api/traces", wrapResponse(j.SearchTraces))
+
+	for _, query := range []string{"service=a&start=yesterday", "service=a&limit=many", "service=a&minDuration=fast"} {
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/traces?"+query, nil))
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"code":400`) {
+			t.Errorf("GET /api/traces?%s: status %d, want 400, body %s", query, rec.Code, rec.Body.String())
+		}
+	}
+}
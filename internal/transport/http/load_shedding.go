@@ -0,0 +1,85 @@
+package http
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/jaeger_service"
+	"openobserve-jaeger/internal/requestid"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+const defaultHeapCheckInterval = 5 // second
+
+// heapMonitor samples the runtime heap periodically, ReadMemStats stops the world
+// so it must not be called in the request path.
+type heapMonitor struct {
+	watermark uint64
+	heapAlloc uint64
+}
+
+func newHeapMonitor(cfg config.LoadSheddingConfig) *heapMonitor {
+	if cfg.HeapWatermarkMB <= 0 {
+		return nil
+	}
+
+	interval := cfg.CheckInterval
+	if interval <= 0 {
+		interval = defaultHeapCheckInterval
+	}
+
+	m := &heapMonitor{
+		watermark: uint64(cfg.HeapWatermarkMB) << 20,
+	}
+	m.sample()
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			m.sample()
+		}
+	}()
+
+	return m
+}
+
+func (m *heapMonitor) sample() {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	atomic.StoreUint64(&m.heapAlloc, ms.HeapAlloc)
+}
+
+func (m *heapMonitor) overloaded() (uint64, bool) {
+	heap := atomic.LoadUint64(&m.heapAlloc)
+	return heap, heap > m.watermark
+}
+
+// shedLoad rejects requests with 503 while the heap is above the watermark,
+// it should only wrap the routes which may load large traces into memory.
+func shedLoad(m *heapMonitor) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if m == nil {
+			ctx.Next()
+			return
+		}
+
+		heap, overloaded := m.overloaded()
+		if !overloaded {
+			ctx.Next()
+			return
+		}
+
+		requestid.Logf(ctx, "load shedding %s, heap: %d MB, watermark: %d MB", ctx.Request.URL.Path, heap>>20, m.watermark>>20)
+		ctx.AbortWithStatusJSON(http.StatusServiceUnavailable, jaeger_service.JaegerStructuredResponse{
+			Errors: []jaeger_service.JaegerStructuredError{
+				{
+					Code: http.StatusServiceUnavailable,
+					Msg:  fmt.Sprintf("server is overloaded (heap %d MB above watermark %d MB), please retry later or narrow the query", heap>>20, m.watermark>>20),
+				},
+			},
+		})
+	}
+}
@@ -5,16 +5,27 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/jaegertracing/jaeger/model"
 	"net/http"
+	"openobserve-jaeger/internal/config"
 	"openobserve-jaeger/internal/jaeger_service"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	defaultQueryLimit  = 20
-	defaultLogDocLimit = 100
+	defaultQueryLimit = 20
+	// defaultMaxQueryLimit applies when openobserve.max_search_limit is not set
+	defaultMaxQueryLimit = 1500
+	defaultLogDocLimit   = 100
+	// tagOrSeparator joins the alternatives of a tag filter
+	tagOrSeparator = " OR "
+	// defaultMaxSearchRange applies when openobserve.default_queryui_max_search_range_time is not set
+	defaultMaxSearchRange = time.Hour
+	// defaultDurationUnit is the unit of the bare integer minDuration and maxDuration when duration_unit is not set
+	defaultDurationUnit = "us"
 
 	traceIDParam     = "traceID"
 	operationParam   = "operation"
@@ -28,14 +39,52 @@ const (
 	spanKindParam    = "spanKind"
 	endTimeParam     = "end"
 	prettyPrintParam = "prettyPrint"
-	versionParam     = "version"
+	versionParam     = "version" // the former search variants, see legacySearchVersions
+	strategyParam    = "strategy"
+	lookbackParam    = "lookback"
+	strictParam      = "strict"
+	sampleParam      = "sample"
+	tzParam          = "tz"
+	downstreamParam  = "downstreamOf"
+	compareAParam    = "a"
+	compareBParam    = "b"
+	percentileParam  = "percentile"
+	sizeOnlyParam    = "sizeOnly"
+	fieldsParam      = "fields"
+	excludeParam     = "exclude"
 )
 
+// knownTraceQueryParams are the parameters accepted by /api/traces in strict mode
+var knownTraceQueryParams = map[string]struct{}{
+	traceIDParam:     {},
+	operationParam:   {},
+	tagParam:         {},
+	tagsParam:        {},
+	startTimeParam:   {},
+	limitParam:       {},
+	minDurationParam: {},
+	maxDurationParam: {},
+	serviceParam:     {},
+	spanKindParam:    {},
+	endTimeParam:     {},
+	prettyPrintParam: {},
+	versionParam:     {},
+	strategyParam:    {},
+	lookbackParam:    {},
+	strictParam:      {},
+	sampleParam:      {},
+	tzParam:          {},
+	downstreamParam:  {},
+	debugParam:       {},
+	fieldsParam:      {},
+	excludeParam:     {},
+}
+
 var (
 	errMaxDurationGreaterThanMin        = fmt.Errorf("'%s' should be greater than '%s'", maxDurationParam, minDurationParam)
 	errStartTimeGreaterThanStartTimeMax = errors.New("StartTime should not be greater than EndTime")
 	// errServiceParameterRequired occurs when no service name is defined.
-	errServiceParameterRequired = fmt.Errorf("parameter '%s' is required", serviceParam)
+	errServiceParameterRequired = fmt.Errorf("parameter '%s' or '%s' is required", serviceParam, downstreamParam)
 )
 
 type (
@@ -47,20 +96,44 @@ type (
 
 	traceQueryParameters struct {
 		jaeger_service.TraceQueryParameters
-		traceIDs []string
 	}
 
 	durationParser = func(s string) (time.Duration, error)
+
+	// unknownParamsError is returned in strict mode for query parameters that are not supported
+	unknownParamsError struct {
+		params []string
+	}
 )
 
+func (e *unknownParamsError) Error() string {
+	return fmt.Sprintf("unknown query parameters: %s", strings.Join(e.params, ", "))
+}
+
 var qp = queryParser{
 	queryLookbackDuration: 1 * time.Hour,
 	timeNow:               time.Now,
 }
 
-func newDurationStringParser() durationParser {
+// newDurationStringParser parses the Go duration strings like "1.5ms", and the bare integers as counts of unit,
+// e.g. minDuration=1500 of the clients sending microseconds.
+func newDurationStringParser(unit string) durationParser {
+	if unit == "" {
+		unit = defaultDurationUnit
+	}
 	return func(s string) (time.Duration, error) {
-		return time.ParseDuration(s)
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			u, err := time.ParseDuration("1" + unit)
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(n) * u, nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("%q is neither a duration string like 1.5ms nor an integer count of %s", s, unit)
+		}
+		return d, nil
 	}
 }
 
@@ -78,11 +151,14 @@ func newDurationStringParser() durationParser {
 //	The search UI itself does not insist on exact units because it supports string like 1ms.
 //	Go makes parsing duration strings like "1ms" very easy, hence why parsing of such strings is
 //	deferred to the backend rather than Jaeger UI.
+//	Bare integers are accepted too, in the duration_unit of the config (microseconds by default), for the
+//	clients sending minDuration=1500.
 //
 // Trace query syntax:
 //
 //	query ::= param | param '&' query
-//	param ::= service | operation | limit | start | end | minDuration | maxDuration | tag | tags
+//	param ::= traceID | service | operation | limit | start | end | minDuration | maxDuration | tag | tags
+//	traceID ::= 'traceID=' strValue (repeatable, skips the trace list search)
 //	service ::= 'service=' strValue
 //	operation ::= 'operation=' strValue
 //	limit ::= 'limit=' intValue
@@ -94,27 +170,49 @@ func newDurationStringParser() durationParser {
 //	key := strValue
 //	keyValue := strValue ':' strValue
 //	tags :== 'tags=' jsonMap
+//	strict ::= 'strict=true' (fail the request when one of its backend queries fails)
+//	sample ::= 'sample=' floatValue in (0, 1] (return about this ratio of the matched traces, total stays the matched count)
+//	tz ::= 'tz=' IANA zone name, e.g. Europe/Berlin (adds human-readable timestamps next to the epoch values)
+//	downstreamOf ::= 'downstreamOf=' strValue (repeatable, searches the service and all the services it calls, directly or not)
 func (p *queryParser) parseTraceQueryParams(ctx *gin.Context, r *http.Request) (*traceQueryParameters, error) {
+	if config.Get().StrictQueryParams {
+		if err := checkUnknownParams(r, knownTraceQueryParams); err != nil {
+			return nil, err
+		}
+	}
+
 	service, _ := ctx.GetQueryArray(serviceParam)
+	downstreamOf, _ := ctx.GetQueryArray(downstreamParam)
 
 	operation, _ := ctx.GetQueryArray(operationParam)
 
+	traceIDs, err := parseTraceIDs(ctx.QueryArray(traceIDParam))
+	if err != nil {
+		return nil, err
+	}
+
 	startTime, err := p.parseTime(r, startTimeParam, time.Microsecond)
 	if err != nil {
 		return nil, err
 	}
+	// traces asked by id are looked up like the trace detail page when no start is given
+	if len(traceIDs) > 0 && r.FormValue(startTimeParam) == "" {
+		startTime = p.timeNow().Add(-time.Hour * time.Duration(config.Get().OpenObserve.DefaultTraceDetailSearchRange))
+	}
 	endTime, err := p.parseTime(r, endTimeParam, time.Microsecond)
 	if err != nil {
 		return nil, err
 	}
+	startTime, endTime = p.normalizeTimeRange(ctx, startTime, endTime)
 
-	tags, err := p.parseTags(r.Form[tagParam], r.Form[tagsParam])
+	tags, tagGroups, err := p.parseTags(r.Form[tagParam], r.Form[tagsParam])
 	if err != nil {
 		return nil, err
 	}
 
 	limitParam := r.FormValue(limitParam)
-	limit := defaultQueryLimit
+	limit := 0
+	defaultLimit := limitParam == ""
 	if limitParam != "" {
 		limitParsed, err := strconv.ParseInt(limitParam, 10, 32)
 		if err != nil {
@@ -122,8 +220,9 @@ func (p *queryParser) parseTraceQueryParams(ctx *gin.Context, r *http.Request) (
 		}
 		limit = int(limitParsed)
 	}
+	limit = searchLimit(ctx, limit, !defaultLimit)
 
-	parser := newDurationStringParser()
+	parser := newDurationStringParser(config.Get().DurationUnit)
 	minDuration, err := parseDuration(r, minDurationParam, parser, 0)
 	if err != nil {
 		return nil, err
@@ -134,38 +233,64 @@ func (p *queryParser) parseTraceQueryParams(ctx *gin.Context, r *http.Request) (
 		return nil, err
 	}
 
-	var traceIDs []string
+	strategy, err := parseSearchStrategy(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var strict bool
+	if strictValue := r.FormValue(strictParam); strictValue != "" {
+		strict, err = strconv.ParseBool(strictValue)
+		if err != nil {
+			return nil, newParseError(err, strictParam)
+		}
+	}
 
-	for _, id := range ctx.Params {
-		traceIDs = append(traceIDs, id.Value)
+	var sample float64
+	if sampleValue := r.FormValue(sampleParam); sampleValue != "" {
+		sample, err = strconv.ParseFloat(sampleValue, 64)
+		if err != nil {
+			return nil, newParseError(err, sampleParam)
+		}
+		if sample <= 0 || sample > 1 {
+			return nil, newParseError(errors.New("sample must be in (0, 1]"), sampleParam)
+		}
 	}
 
-	var version string
-	version = r.FormValue(versionParam)
+	location, err := parseLocation(r.FormValue(tzParam))
+	if err != nil {
+		return nil, err
+	}
 
 	traceQuery := &traceQueryParameters{
 		TraceQueryParameters: jaeger_service.TraceQueryParameters{
+			TraceIDs:      traceIDs,
 			ServiceName:   service,
+			DownstreamOf:  downstreamOf,
 			OperationName: operation,
 			StartTimeMin:  startTime,
 			StartTimeMax:  endTime,
 			Tags:          tags,
+			TagGroups:     tagGroups,
 			NumTraces:     limit,
 			DurationMin:   minDuration,
 			DurationMax:   maxDuration,
-			Version:       version,
+			Strategy:      strategy,
+			Strict:        strict,
+			SampleRatio:   sample,
+			Location:      location,
+			DefaultLimit:  defaultLimit,
 		},
-		traceIDs: traceIDs,
 	}
 
-	if err := p.validateTraceQuery(traceQuery); err != nil {
+	if err := p.validateTraceQuery(ctx, traceQuery); err != nil {
 		return nil, err
 	}
 	return traceQuery, nil
 }
 
-func (p *queryParser) validateTraceQuery(traceQuery *traceQueryParameters) error {
-	if len(traceQuery.traceIDs) == 0 && len(traceQuery.ServiceName) == 0 {
+func (p *queryParser) validateTraceQuery(ctx *gin.Context, traceQuery *traceQueryParameters) error {
+	if len(traceQuery.TraceIDs) == 0 && len(traceQuery.ServiceName) == 0 && len(traceQuery.DownstreamOf) == 0 {
 		return errServiceParameterRequired
 	}
 	if traceQuery.DurationMin != 0 && traceQuery.DurationMax != 0 {
@@ -179,34 +304,218 @@ func (p *queryParser) validateTraceQuery(traceQuery *traceQueryParameters) error
 			return errStartTimeGreaterThanStartTimeMax
 		}
 
-		if traceQuery.StartTimeMax.Sub(traceQuery.StartTimeMin) > (time.Hour + 5*time.Minute) {
-			return errors.New(fmt.Sprintf("time range should not be greater than 1 Hour"))
+		// the range limit protects the trace list search, which is skipped for trace ids
+		maxRange := maxSearchRange(ctx.GetString(authUserKey))
+		if len(traceQuery.TraceIDs) == 0 && traceQuery.StartTimeMax.Sub(traceQuery.StartTimeMin) > maxRange+5*time.Minute {
+			return fmt.Errorf("time range should not be greater than %s", maxRange)
+		}
+	}
+
+	return nil
+}
+
+// legacySearchVersions maps the version query arg values of the former search variants to their strategies
+var legacySearchVersions = map[string]string{
+	"v3": "background_no_wal",
+	"v4": "background",
+}
+
+// parseSearchStrategy returns the strategy query arg, else the strategy of the legacy version arg, empty for
+// search_strategy. An unknown strategy is rejected with the known ones and what they change.
+func parseSearchStrategy(r *http.Request) (string, error) {
+	name := r.FormValue(strategyParam)
+	if name == "" {
+		return legacySearchVersions[r.FormValue(versionParam)], nil
+	}
+
+	cfg := config.Get()
+	if _, ok := cfg.LookupSearchStrategy(name); ok {
+		return name, nil
+	}
+	known := make([]string, 0, len(config.BuiltinSearchStrategies))
+	for _, n := range cfg.SearchStrategyNames() {
+		s, _ := cfg.LookupSearchStrategy(n)
+		known = append(known, fmt.Sprintf("%s (%s)", n, s))
+	}
+	return "", newParseError(fmt.Errorf("unknown search strategy %q, one of %s", name, strings.Join(known, ", ")), strategyParam)
+}
+
+// searchLimit returns the limit of a trace search, openobserve.default_search_limit if none was set or a
+// non-positive one, reduced to openobserve.max_search_limit. The corrections of a set limit are warned about.
+func searchLimit(ctx *gin.Context, limit int, set bool) int {
+	cfg := config.Get().OpenObserve
+	def, maxLimit := defaultQueryLimit, defaultMaxQueryLimit
+	if cfg.DefaultSearchLimit > 0 {
+		def = cfg.DefaultSearchLimit
+	}
+	if cfg.MaxSearchLimit > 0 {
+		maxLimit = cfg.MaxSearchLimit
+	}
+	if def > maxLimit {
+		def = maxLimit
+	}
+
+	switch {
+	case !set:
+		return def
+	case limit <= 0:
+		addWarning(ctx, fmt.Sprintf("limit %d is not positive, the default %d was used", limit, def))
+		return def
+	case limit > maxLimit:
+		addWarning(ctx, fmt.Sprintf("limit %d was reduced to openobserve.max_search_limit %d", limit, maxLimit))
+		return maxLimit
+	}
+	return limit
+}
+
+// maxSearchRange is the longest time range user may search: openobserve.max_search_range_users of the
+// user, else openobserve.default_queryui_max_search_range_time, default 1 hour.
+func maxSearchRange(user string) time.Duration {
+	cfg := config.Get().OpenObserve
+	hours := cfg.DefaultQueryUIMaxSearchRange
+	if override, ok := cfg.MaxSearchRangeUsers[user]; ok && user != "" {
+		hours = override
+	}
+	if hours <= 0 {
+		return defaultMaxSearchRange
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// checkUnknownParams rejects the query parameters missing in known, so typos don't silently do nothing.
+func checkUnknownParams(r *http.Request, known map[string]struct{}) error {
+	unknown := make([]string, 0)
+	for k := range r.URL.Query() {
+		if _, ok := known[k]; !ok {
+			unknown = append(unknown, k)
 		}
 	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	sort.Strings(unknown)
+	return &unknownParamsError{params: unknown}
+}
+
+// parseLocation loads the tz query arg, an empty one means no location.
+func parseLocation(tz string) (*time.Location, error) {
+	if tz == "" {
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, newParseError(err, tzParam)
+	}
+	return loc, nil
+}
 
+// parseTraceFields parses the fields and exclude query args of the trace routes into ctx, the conversion
+// drops the span parts they exclude.
+func parseTraceFields(ctx *gin.Context) error {
+	fields, err := jaeger_service.ParseTraceFields(ctx.Query(fieldsParam), ctx.Query(excludeParam))
+	if err != nil {
+		return fmt.Errorf("unable to parse params '%s' and '%s': %w", fieldsParam, excludeParam, err)
+	}
+	if len(fields) > 0 {
+		ctx.Set(jaeger_service.TraceFieldsKey, fields)
+	}
 	return nil
 }
 
-func (p *queryParser) parseTags(simpleTags []string, jsonTags []string) (map[string]string, error) {
+// parseTraceIDs validates the traceID query args, which are sent by the UI compare and deep-link flows.
+func parseTraceIDs(ids []string) ([]string, error) {
+	traceIDs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if len(id) > 32 {
+			return nil, fmt.Errorf("TraceID cannot be longer than 32 hex characters: %s", id)
+		}
+		if _, err := model.TraceIDFromString(id); err != nil {
+			return nil, newParseError(err, traceIDParam)
+		}
+		traceIDs = append(traceIDs, id)
+	}
+	return traceIDs, nil
+}
+
+// parseTags parses the tag and tags parameters into the tags which must all match and the OR groups. A key
+// given several times matches any of its values, and " OR " joins alternatives: tag=k:a OR b, tag=k:a OR j:b
+// or tags={"k":"a OR b"}; in a tag parameter a part without a colon is another value of the previous key.
+func (p *queryParser) parseTags(simpleTags []string, jsonTags []string) (map[string]string, [][]jaeger_service.TagTerm, error) {
 	retMe := make(map[string]string)
+	groups := make([][]jaeger_service.TagTerm, 0)
+	// the terms of the keys given more than once, in the order they were repeated
+	repeated := make(map[string][]jaeger_service.TagTerm)
+	repeatedKeys := make([]string, 0)
+	add := func(k, v string) {
+		if terms, ok := repeated[k]; ok {
+			repeated[k] = append(terms, jaeger_service.TagTerm{Key: k, Value: v})
+			return
+		}
+		if old, ok := retMe[k]; ok {
+			delete(retMe, k)
+			repeated[k] = []jaeger_service.TagTerm{{Key: k, Value: old}, {Key: k, Value: v}}
+			repeatedKeys = append(repeatedKeys, k)
+			return
+		}
+		retMe[k] = v
+	}
+
 	for _, tag := range simpleTags {
+		if strings.Contains(tag, tagOrSeparator) {
+			group, err := parseTagGroup(tag)
+			if err != nil {
+				return nil, nil, err
+			}
+			groups = append(groups, group)
+			continue
+		}
 		keyAndValue := strings.Split(tag, ":")
 		if l := len(keyAndValue); l > 1 {
-			retMe[keyAndValue[0]] = strings.Join(keyAndValue[1:], ":")
+			add(keyAndValue[0], strings.Join(keyAndValue[1:], ":"))
 		} else {
-			return nil, fmt.Errorf("malformed 'tag' parameter, expecting key:value, received: %s", tag)
+			return nil, nil, fmt.Errorf("malformed 'tag' parameter, expecting key:value, received: %s", tag)
 		}
 	}
 	for _, tags := range jsonTags {
 		var fromJSON map[string]string
 		if err := json.Unmarshal([]byte(tags), &fromJSON); err != nil {
-			return nil, fmt.Errorf("malformed 'tags' parameter, cannot unmarshal JSON: %w", err)
+			return nil, nil, fmt.Errorf("malformed 'tags' parameter, cannot unmarshal JSON: %w", err)
 		}
 		for k, v := range fromJSON {
-			retMe[k] = v
+			if !strings.Contains(v, tagOrSeparator) {
+				add(k, v)
+				continue
+			}
+			group := make([]jaeger_service.TagTerm, 0)
+			for _, value := range strings.Split(v, tagOrSeparator) {
+				group = append(group, jaeger_service.TagTerm{Key: k, Value: strings.TrimSpace(value)})
+			}
+			groups = append(groups, group)
+		}
+	}
+
+	for _, k := range repeatedKeys {
+		groups = append(groups, repeated[k])
+	}
+	return retMe, groups, nil
+}
+
+// parseTagGroup parses the " OR " joined terms of a tag parameter, k:a OR b OR j:c.
+func parseTagGroup(tag string) ([]jaeger_service.TagTerm, error) {
+	group := make([]jaeger_service.TagTerm, 0)
+	key := ""
+	for _, part := range strings.Split(tag, tagOrSeparator) {
+		part = strings.TrimSpace(part)
+		value := part
+		if i := strings.Index(part, ":"); i > 0 {
+			key, value = part[:i], part[i+1:]
+		} else if key == "" {
+			return nil, fmt.Errorf("malformed 'tag' parameter, expecting key:value OR key:value, received: %s", tag)
 		}
+		group = append(group, jaeger_service.TagTerm{Key: key, Value: value})
 	}
-	return retMe, nil
+	return group, nil
 }
 
 // parseTime parses the time parameter of an HTTP request that is represented the number of "units" since epoch.
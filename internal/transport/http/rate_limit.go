@@ -0,0 +1,141 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/jaeger_service"
+	"openobserve-jaeger/internal/metrics"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	rateLimitKeyIP     = "ip"
+	rateLimitKeyAPIKey = "api_key"
+
+	// idle buckets are refilled anyway, they are dropped to bound the memory of one-off clients
+	rateLimitIdleTimeout = 10 * time.Minute
+	rateLimitSweepSize   = 10000
+)
+
+var rateLimitedRequests = metrics.NewCounter("rate_limited_requests_total", "Requests rejected with 429 by the rate limiter.", "class")
+
+// bucket is a token bucket refilled at rate tokens per second up to burst.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter keeps one bucket per client and route class.
+type rateLimiter struct {
+	class string
+	limit config.RateLimit
+	key   string
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func newRateLimiter(class string, limit config.RateLimit, key string) *rateLimiter {
+	return &rateLimiter{
+		class:   class,
+		limit:   limit,
+		key:     key,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// allow takes a token of client, if there is none it returns how long until the next one.
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	burst := float64(l.limit.Burst)
+	if burst < 1 {
+		burst = math.Max(1, math.Ceil(l.limit.Rate))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.buckets) >= rateLimitSweepSize {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > rateLimitIdleTimeout {
+				delete(l.buckets, k)
+			}
+		}
+	}
+
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[client] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.limit.Rate * float64(time.Second))
+}
+
+// clientKey identifies the caller by its credentials (the Authorization header, hashed) in api_key mode
+// and by its IP otherwise, or when it sends none.
+func (l *rateLimiter) clientKey(ctx *gin.Context) string {
+	if l.key == rateLimitKeyAPIKey {
+		if auth := ctx.GetHeader("Authorization"); auth != "" {
+			sum := sha256.Sum256([]byte(auth))
+			return "key:" + hex.EncodeToString(sum[:8])
+		}
+	}
+	return "ip:" + ctx.ClientIP()
+}
+
+// rateLimit rejects the requests of a client above the class limit with 429,
+// a nil limiter or a zero rate lets everything through.
+func rateLimit(l *rateLimiter) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if l == nil || l.limit.Rate <= 0 {
+			ctx.Next()
+			return
+		}
+
+		ok, wait := l.allow(l.clientKey(ctx), time.Now())
+		if ok {
+			ctx.Next()
+			return
+		}
+
+		rateLimitedRequests.Inc(l.class)
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		ctx.AbortWithStatusJSON(http.StatusTooManyRequests, jaeger_service.JaegerStructuredResponse{
+			Errors: []jaeger_service.JaegerStructuredError{
+				{
+					Code: http.StatusTooManyRequests,
+					Msg:  fmt.Sprintf("rate limit of %g %s requests per second exceeded, retry in %s", l.limit.Rate, l.class, wait.Round(time.Millisecond)),
+				},
+			},
+		})
+	}
+}
+
+// rateLimiters are the limiters of the expensive trace routes and of the cheap metadata routes.
+type rateLimiters struct {
+	traces   *rateLimiter
+	metadata *rateLimiter
+}
+
+func newRateLimiters(cfg config.RateLimitConfig) rateLimiters {
+	if !cfg.Enabled {
+		return rateLimiters{}
+	}
+	return rateLimiters{
+		traces:   newRateLimiter("traces", cfg.Traces, cfg.Key),
+		metadata: newRateLimiter("metadata", cfg.Metadata, cfg.Key),
+	}
+}
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net"
+	"net/http"
+	"openobserve-jaeger/internal/jaeger_service"
+	"openobserve-jaeger/internal/metrics"
+	"openobserve-jaeger/internal/requestid"
+	"os"
+	"runtime/debug"
+	"strings"
+	"syscall"
+)
+
+var panicsTotal = metrics.NewCounter("http_panics_total", "Panics recovered while serving a request, by route.", "route")
+
+// recoverPanics replaces the gin recovery: the panic is logged with its stack and the request id, counted in
+// http_panics_total, and answered with a 500 in the jaeger error envelope instead of an empty body.
+func recoverPanics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+
+			route := ctx.FullPath()
+			if route == "" {
+				route = "unmatched"
+			}
+			if brokenPipe(err) {
+				// the client went away, there is nobody to answer
+				requestid.Logf(ctx, "connection lost serving %s %s: %v", ctx.Request.Method, route, err)
+				ctx.Abort()
+				return
+			}
+
+			panicsTotal.Inc(route)
+			requestid.Logf(ctx, "panic serving %s %s: %v\n%s", ctx.Request.Method, route, err, debug.Stack())
+			if ctx.Writer.Written() {
+				// the response is partly written already, it can only be cut short
+				ctx.Abort()
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, jaeger_service.JaegerStructuredResponse{
+				Errors: []jaeger_service.JaegerStructuredError{
+					{
+						Code:      http.StatusInternalServerError,
+						Msg:       "internal error, see the proxy log for the request id",
+						RequestID: requestid.ID(ctx),
+					},
+				},
+			})
+		}()
+		ctx.Next()
+	}
+}
+
+// brokenPipe reports whether the panic is a write to a connection the client closed.
+func brokenPipe(err interface{}) bool {
+	e, ok := err.(error)
+	if !ok {
+		return false
+	}
+	var se *os.SyscallError
+	if errors.As(e, &se) {
+		return errors.Is(se.Err, syscall.EPIPE) || errors.Is(se.Err, syscall.ECONNRESET)
+	}
+	var oe *net.OpError
+	if errors.As(e, &oe) {
+		msg := strings.ToLower(oe.Error())
+		return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"compress/gzip"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"openobserve-jaeger/internal/config"
+	"strings"
+	"testing"
+)
+
+func servePanic(compression config.CompressionConfig, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(identifyRequest(), recoverPanics(), compress(compression))
+	engine.GET("/api/panic", handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/panic", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRecoverPanicsBehindCompression(t *testing.T) {
+	cases := []struct {
+		name    string
+		cfg     config.CompressionConfig
+		handler gin.HandlerFunc
+	}{
+		{
+			name:    "compression off",
+			cfg:     config.CompressionConfig{Enabled: false},
+			handler: func(ctx *gin.Context) { panic("boom") },
+		},
+		{
+			name:    "compression on",
+			cfg:     config.CompressionConfig{Enabled: true},
+			handler: func(ctx *gin.Context) { panic("boom") },
+		},
+		{
+			name: "compression on, body buffered",
+			cfg:  config.CompressionConfig{Enabled: true, MinSize: 1 << 20},
+			handler: func(ctx *gin.Context) {
+				ctx.Header("Content-Type", "application/json")
+				ctx.Writer.WriteString(`{"partial":`)
+				panic("boom")
+			},
+		},
+	}
+	for _, c := range cases {
+		rec := servePanic(c.cfg, c.handler)
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("%s: status %d, want 500", c.name, rec.Code)
+		}
+		if encoding := rec.Header().Get("Content-Encoding"); encoding != "" {
+			t.Fatalf("%s: Content-Encoding %q, want none", c.name, encoding)
+		}
+		if body := rec.Body.String(); !strings.Contains(body, "internal error") || strings.Contains(body, `{"partial":`) {
+			t.Fatalf("%s: body %q, want the error envelope alone", c.name, body)
+		}
+	}
+}
+
+func TestRecoverPanicsAfterCompressedWrite(t *testing.T) {
+	rec := servePanic(config.CompressionConfig{Enabled: true, MinSize: 1}, func(ctx *gin.Context) {
+		ctx.Header("Content-Type", "application/json")
+		ctx.Writer.WriteString(`{"partial":`)
+		ctx.Writer.Flush()
+		panic("boom")
+	})
+
+	// the header is out already, the response can only be cut short
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("status %d, Content-Encoding %q", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+	if _, err := gzip.NewReader(rec.Body); err != nil {
+		t.Fatalf("gzip header: %v", err)
+	}
+}
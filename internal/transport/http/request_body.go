@@ -0,0 +1,76 @@
+package http
+
+import (
+	"compress/gzip"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/jaeger_service"
+	"strings"
+)
+
+const defaultMaxRequestBodySize = 10 << 20
+
+// limitRequestBody caps the request body size and transparently decodes gzip bodies,
+// the limit applies to the decompressed size so small gzip bombs are rejected too.
+func limitRequestBody(cfg config.RequestBodyConfig) gin.HandlerFunc {
+	maxSize := cfg.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxRequestBodySize
+	}
+
+	return func(ctx *gin.Context) {
+		if ctx.Request.Body == nil || ctx.Request.Body == http.NoBody {
+			ctx.Next()
+			return
+		}
+
+		var body io.ReadCloser = ctx.Request.Body
+		switch encoding := strings.ToLower(strings.TrimSpace(ctx.GetHeader("Content-Encoding"))); encoding {
+		case "", "identity":
+		case "gzip":
+			if !cfg.Gzip {
+				abortRequestBody(ctx, http.StatusUnsupportedMediaType, "gzip request bodies are not accepted")
+				return
+			}
+			gz, err := gzip.NewReader(ctx.Request.Body)
+			if err != nil {
+				abortRequestBody(ctx, http.StatusBadRequest, "malformed gzip request body: "+err.Error())
+				return
+			}
+			body = gzipBody{Reader: gz, body: ctx.Request.Body}
+			ctx.Request.Header.Del("Content-Encoding")
+			ctx.Request.Header.Del("Content-Length")
+			ctx.Request.ContentLength = -1
+		default:
+			abortRequestBody(ctx, http.StatusUnsupportedMediaType, "unsupported Content-Encoding: "+encoding)
+			return
+		}
+
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, body, maxSize)
+		ctx.Next()
+	}
+}
+
+// gzipBody closes both the gzip reader and the underlying request body
+type gzipBody struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (b gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
+
+func abortRequestBody(ctx *gin.Context, code int, msg string) {
+	ctx.AbortWithStatusJSON(code, jaeger_service.JaegerStructuredResponse{
+		Errors: []jaeger_service.JaegerStructuredError{
+			{
+				Code: code,
+				Msg:  msg,
+			},
+		},
+	})
+}
@@ -0,0 +1,33 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"openobserve-jaeger/internal/jaeger_service"
+	"openobserve-jaeger/internal/requestid"
+)
+
+// identifyRequest takes the X-Request-ID of the caller or generates one, echoes it in the response
+// and puts it in the context for the log lines and the error metadata.
+func identifyRequest() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		r := requestid.New(ctx.GetHeader(requestid.Header))
+		ctx.Set(requestid.ContextKey, r)
+		ctx.Header(requestid.Header, r.ID)
+		ctx.Next()
+	}
+}
+
+// attachRequestID adds the request id and the OpenObserve session ids of the request to the errors,
+// so a failing query can be looked up in the OpenObserve logs.
+func attachRequestID(ctx *gin.Context, response *jaeger_service.JaegerStructuredResponse) {
+	r := requestid.FromContext(ctx)
+	if r == nil || len(response.Errors) == 0 {
+		return
+	}
+
+	sessions := r.Sessions()
+	for i := range response.Errors {
+		response.Errors[i].RequestID = r.ID
+		response.Errors[i].Sessions = sessions
+	}
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/errors"
+	"openobserve-jaeger/internal/jaeger_service"
+	"time"
+)
+
+const (
+	defaultTailPollInterval = 2 * time.Second
+	defaultTailMaxDuration  = 10 * time.Minute
+)
+
+// TailTraceSpans serves /api/traces/:id/spans as server-sent events: a "spans" event with a
+// jaeger trace of the newly arrived spans per poll, "error" events for the failed polls
+// and an "end" event once tail.max_duration is reached.
+func (s *jaegerServerRoute) TailTraceSpans(ctx *gin.Context) {
+	q, err := valideRequest(ctx)
+	if err != nil {
+		ctx.AbortWithStatusJSON(errors.HTTPStatus(err), jaeger_service.ErrorResponse(err))
+		return
+	}
+
+	cfg := config.Get().Tail
+	interval := defaultTailPollInterval
+	if cfg.PollInterval > 0 {
+		interval = time.Duration(cfg.PollInterval) * time.Second
+	}
+	maxDuration := defaultTailMaxDuration
+	if cfg.MaxDuration > 0 {
+		maxDuration = time.Duration(cfg.MaxDuration) * time.Second
+	}
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+
+	tail := s.JaegerService.NewSpanTail(ctx, q)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	deadline := time.After(maxDuration)
+	for {
+		trace, jaegerErr := tail.Next(ctx)
+		if jaegerErr != nil {
+			ctx.SSEvent("error", jaegerErr)
+		} else if trace != nil {
+			ctx.SSEvent("spans", trace)
+		}
+		ctx.Writer.Flush()
+
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case <-deadline:
+			ctx.SSEvent("end", gin.H{"reason": "max duration reached"})
+			ctx.Writer.Flush()
+			return
+		case <-ticker.C:
+		}
+	}
+}
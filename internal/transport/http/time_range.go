@@ -0,0 +1,67 @@
+package http
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"openobserve-jaeger/internal/config"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultFutureSkew    = 60  // second
+	defaultSwapTolerance = 300 // second
+)
+
+// normalizeTimeRange applies time_range to the [start, end] of a search: a start after the end by at most
+// swap_tolerance is swapped with it, then an end later than now plus future_skew is clamped to it. The
+// corrections are added to the warnings of the response, without time_range.normalize the range is returned
+// as is and an inverted one fails the validation.
+func (p *queryParser) normalizeTimeRange(ctx *gin.Context, start, end time.Time) (time.Time, time.Time) {
+	cfg := config.Get().TimeRange
+	if !cfg.Normalize {
+		return start, end
+	}
+	skew, tolerance := time.Duration(defaultFutureSkew)*time.Second, time.Duration(defaultSwapTolerance)*time.Second
+	if cfg.FutureSkew > 0 {
+		skew = time.Duration(cfg.FutureSkew) * time.Second
+	}
+	if cfg.SwapTolerance > 0 {
+		tolerance = time.Duration(cfg.SwapTolerance) * time.Second
+	}
+
+	if start.After(end) && start.Sub(end) <= tolerance {
+		addWarning(ctx, fmt.Sprintf("start %s was after end %s, they were swapped", formatRangeTime(start), formatRangeTime(end)))
+		start, end = end, start
+	}
+	if maxEnd := p.timeNow().Add(skew); end.After(maxEnd) {
+		addWarning(ctx, fmt.Sprintf("end %s is in the future, it was clamped to %s", formatRangeTime(end), formatRangeTime(maxEnd)))
+		end = maxEnd
+	}
+	return start, end
+}
+
+// parseLookback parses the lookback query arg in milliseconds, def if it's missing. A negative lookback is
+// rejected, or taken as positive with time_range.normalize.
+func (p *queryParser) parseLookback(ctx *gin.Context, def time.Duration) (time.Duration, error) {
+	v := ctx.Query(lookbackParam)
+	if v == "" {
+		return def, nil
+	}
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, newParseError(err, lookbackParam)
+	}
+	if ms < 0 {
+		if !config.Get().TimeRange.Normalize {
+			return 0, newParseError(fmt.Errorf("negative lookback %d", ms), lookbackParam)
+		}
+		addWarning(ctx, fmt.Sprintf("lookback %dms is negative, %dms was used", ms, -ms))
+		ms = -ms
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+func formatRangeTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"openobserve-jaeger/internal/jaeger_service"
+	"openobserve-jaeger/internal/metrics"
+	"openobserve-jaeger/internal/openobserve_service"
+	"openobserve-jaeger/internal/timing"
+	"time"
+)
+
+const (
+	debugParam    = "debug"
+	debugTimings  = "timings"
+	debugBackends = "backends"
+	phaseTotal    = "total"
+)
+
+var requestPhaseDuration = metrics.NewHistogram("request_phase_duration_seconds",
+	"Request latency split by phase: parse, sql_build, openobserve, conversion, encode and total.",
+	nil, "route", "phase")
+
+// timeRequest records the phase timings of the request in the request_phase_duration_seconds metric.
+func timeRequest() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		t := timing.New()
+		ctx.Set(timing.ContextKey, t)
+		if ctx.Query(debugParam) == debugBackends {
+			ctx.Set(openobserve_service.BackendsContextKey, &openobserve_service.Backends{})
+		}
+		start := time.Now()
+
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			return
+		}
+		for phase, d := range t.Phases() {
+			requestPhaseDuration.Observe(d.Seconds(), route, phase)
+		}
+		requestPhaseDuration.Observe(time.Since(start).Seconds(), route, phaseTotal)
+	}
+}
+
+// attachDebugBackends adds the OpenObserve base urls queried so far to the response on ?debug=backends.
+func attachDebugBackends(ctx *gin.Context, response *jaeger_service.JaegerStructuredResponse) {
+	if b, ok := ctx.Value(openobserve_service.BackendsContextKey).(*openobserve_service.Backends); ok {
+		response.Backends = b.List()
+	}
+}
+
+// attachDebugTimings adds the phase timings so far to the response on ?debug=timings,
+// the encode phase is only in the metrics as it's still running.
+func attachDebugTimings(ctx *gin.Context, response *jaeger_service.JaegerStructuredResponse) {
+	if ctx.Query(debugParam) != debugTimings {
+		return
+	}
+
+	phases := timing.FromContext(ctx).Phases()
+	response.Timings = make(map[string]float64, len(phases))
+	for phase, d := range phases {
+		response.Timings[phase] = float64(d.Microseconds()) / 1e3
+	}
+}
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"openobserve-jaeger/internal/requestid"
+	"openobserve-jaeger/internal/tracing"
+)
+
+// traceRequest starts a server span per request, continuing the W3C traceparent of the caller.
+// The span is put in the request context, which gin.Context falls back to (ContextWithFallback),
+// so the OpenObserve client spans become its children.
+func traceRequest() gin.HandlerFunc {
+	propagator := propagation.TraceContext{}
+	return func(ctx *gin.Context) {
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		reqCtx := propagator.Extract(ctx.Request.Context(), propagation.HeaderCarrier(ctx.Request.Header))
+		reqCtx, span := tracing.Tracer().Start(reqCtx, ctx.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.method", ctx.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("http.target", ctx.Request.URL.RequestURI()),
+				attribute.String("http.user_agent", ctx.Request.UserAgent()),
+			),
+		)
+		defer span.End()
+		ctx.Request = ctx.Request.WithContext(reqCtx)
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(attribute.Int("http.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if id := requestid.ID(ctx); id != "" {
+			span.SetAttributes(attribute.String("http.request_id", id))
+		}
+		if user, ok := ctx.Get("auth_user"); ok {
+			span.SetAttributes(attribute.String("enduser.id", user.(string)))
+		}
+	}
+}
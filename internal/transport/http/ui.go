@@ -0,0 +1,45 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"openobserve-jaeger/internal/config"
+	"openobserve-jaeger/internal/webui"
+	"strings"
+)
+
+// serveUI serves the embedded jaeger-ui for the GET requests matching no route, the other
+// requests and the unknown /api paths keep the 404 of gin.
+func serveUI(cfg config.UIConfig) (gin.HandlerFunc, error) {
+	handler, err := webui.NewHandler(webui.BasePath(cfg.BasePath))
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx *gin.Context) {
+		if ctx.Request.Method != http.MethodGet && ctx.Request.Method != http.MethodHead ||
+			strings.HasPrefix(ctx.Request.URL.Path, "/api/") {
+			return
+		}
+		ctx.Status(http.StatusOK)
+		handler.ServeHTTP(ctx.Writer, ctx.Request)
+	}, nil
+}
+
+// stripBasePath serves the requests under basePath as if they had no prefix, so the UI and the api work
+// under a path of a shared domain while the routes, their config keys and the requests without the prefix
+// stay the same.
+func stripBasePath(h http.Handler, basePath string) http.Handler {
+	basePath = webui.BasePath(basePath)
+	if basePath == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path := r.URL.Path; path == basePath || strings.HasPrefix(path, basePath+"/") {
+			r2 := r.Clone(r.Context())
+			r2.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(path, basePath), "/")
+			r2.URL.RawPath = ""
+			r = r2
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,19 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"openobserve-jaeger/internal/jaeger_service"
+)
+
+const warningsKey = "response_warnings"
+
+// addWarning notes a correction the parsing made to the request, e.g. a clamped time range or limit.
+func addWarning(ctx *gin.Context, warning string) {
+	warnings := ctx.GetStringSlice(warningsKey)
+	ctx.Set(warningsKey, append(warnings, warning))
+}
+
+// attachWarnings adds the corrections made to the request to the warnings of the response.
+func attachWarnings(ctx *gin.Context, response *jaeger_service.JaegerStructuredResponse) {
+	response.Warnings = append(response.Warnings, ctx.GetStringSlice(warningsKey)...)
+}
@@ -0,0 +1,184 @@
+package http
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	ui "github.com/jaegertracing/jaeger/model/json"
+	"net/http"
+	"openobserve-jaeger/internal/jaeger_service"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	zipkinDefaultLimit    = 10
+	zipkinDefaultLookback = time.Hour
+)
+
+// ZipkinTraces serves GET /zipkin/api/v2/traces
+func (s *jaegerServerRoute) ZipkinTraces(ctx *gin.Context) {
+	q, err := parseZipkinQuery(ctx)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp := s.JaegerService.FindTraces(ctx, q)
+	if len(resp.Errors) > 0 && !resp.Partial() {
+		ctx.String(resp.StatusCode(), resp.Errors[0].Msg)
+		return
+	}
+
+	traces, _ := resp.Data.([]*ui.Trace)
+	zipkinTraces := make([][]jaeger_service.ZipkinSpan, 0, len(traces))
+	for _, trace := range traces {
+		zipkinTraces = append(zipkinTraces, jaeger_service.ToZipkinSpans(trace))
+	}
+	ctx.JSON(http.StatusOK, zipkinTraces)
+}
+
+// ZipkinTrace serves GET /zipkin/api/v2/trace/:id
+func (s *jaegerServerRoute) ZipkinTrace(ctx *gin.Context) {
+	q, err := valideRequest(ctx)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp := s.JaegerService.GetTrace(ctx, q)
+	traces, _ := resp.Data.([]*ui.Trace)
+	if len(traces) == 0 || traces[0] == nil {
+		code, msg := http.StatusNotFound, "trace not found"
+		if len(resp.Errors) > 0 {
+			code, msg = resp.StatusCode(), resp.Errors[0].Msg
+		}
+		ctx.String(code, msg)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, jaeger_service.ToZipkinSpans(traces[0]))
+}
+
+// ZipkinServices serves GET /zipkin/api/v2/services
+func (s *jaegerServerRoute) ZipkinServices(ctx *gin.Context) {
+	q, err := valideRequest(ctx)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeZipkinNames(ctx, s.JaegerService.GetService(ctx, q))
+}
+
+// ZipkinSpans serves GET /zipkin/api/v2/spans?serviceName=
+func (s *jaegerServerRoute) ZipkinSpans(ctx *gin.Context) {
+	q, err := valideRequest(ctx)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	q.ServiceName = ctx.Query("serviceName")
+	if q.ServiceName == "" {
+		ctx.String(http.StatusBadRequest, "serviceName is required")
+		return
+	}
+
+	writeZipkinNames(ctx, s.JaegerService.GetOperations(ctx, q))
+}
+
+func writeZipkinNames(ctx *gin.Context, resp jaeger_service.JaegerStructuredResponse) {
+	if len(resp.Errors) > 0 {
+		ctx.String(resp.StatusCode(), resp.Errors[0].Msg)
+		return
+	}
+
+	names := make([]string, 0)
+	if values, ok := resp.Data.([]interface{}); ok {
+		for _, v := range values {
+			names = append(names, fmt.Sprint(v))
+		}
+	}
+	ctx.JSON(http.StatusOK, names)
+}
+
+// parseZipkinQuery maps the Zipkin v2 trace query to TraceQueryParameters:
+//
+//	serviceName, spanName ('all' means any), annotationQuery ('error and http.method=GET'),
+//	minDuration, maxDuration (microseconds), endTs, lookback (milliseconds), limit
+func parseZipkinQuery(ctx *gin.Context) (*jaeger_service.TraceQueryParameters, error) {
+	end := time.Now()
+	if v := ctx.Query("endTs"); v != "" {
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, newParseError(err, "endTs")
+		}
+		end = time.Unix(0, ms*int64(time.Millisecond))
+	}
+
+	lookback, err := qp.parseLookback(ctx, zipkinDefaultLookback)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := zipkinDefaultLimit
+	if v := ctx.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, newParseError(err, "limit")
+		}
+		limit = searchLimit(ctx, n, true)
+	}
+
+	var minDuration, maxDuration time.Duration
+	for param, d := range map[string]*time.Duration{"minDuration": &minDuration, "maxDuration": &maxDuration} {
+		if v := ctx.Query(param); v != "" {
+			us, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, newParseError(err, param)
+			}
+			*d = time.Duration(us) * time.Microsecond
+		}
+	}
+
+	q := &traceQueryParameters{
+		TraceQueryParameters: jaeger_service.TraceQueryParameters{
+			StartTimeMin: end.Add(-lookback),
+			StartTimeMax: end,
+			Tags:         parseZipkinAnnotationQuery(ctx.Query("annotationQuery")),
+			NumTraces:    limit,
+			DurationMin:  minDuration,
+			DurationMax:  maxDuration,
+		},
+	}
+	if service := ctx.Query("serviceName"); service != "" {
+		q.ServiceName = []string{service}
+	}
+	if spanName := ctx.Query("spanName"); spanName != "" && spanName != "all" {
+		q.OperationName = []string{spanName}
+	}
+
+	if err := qp.validateTraceQuery(ctx, q); err != nil {
+		return nil, err
+	}
+	return &q.TraceQueryParameters, nil
+}
+
+// parseZipkinAnnotationQuery parses 'key=value and key' into tags, a bare key matches "true".
+func parseZipkinAnnotationQuery(query string) map[string]string {
+	tags := make(map[string]string)
+	for _, term := range strings.Split(query, " and ") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		kv := strings.SplitN(term, "=", 2)
+		if len(kv) == 2 {
+			tags[kv[0]] = kv[1]
+		} else {
+			tags[kv[0]] = "true"
+		}
+	}
+	return tags
+}
@@ -0,0 +1,33 @@
+package version
+
+import (
+	"openobserve-jaeger/internal/codec"
+	"runtime"
+)
+
+// set at build time, e.g.
+// go build -ldflags "-X openobserve-jaeger/internal/version.Version=v1.2.0 -X openobserve-jaeger/internal/version.Commit=$(git rev-parse --short HEAD)"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// Info is the build info of the /api/version response
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+	Encoder   string `json:"jsonEncoder"` // encoding/json, or sonic when built with -tags sonic
+}
+
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Encoder:   codec.Name(),
+	}
+}
@@ -0,0 +1,24 @@
+package webui
+
+import "strings"
+
+// The Cache-Control of the jaeger-ui files
+const (
+	// immutableCacheControl lets the browsers and the CDNs keep a file for a year, a new build has new names
+	immutableCacheControl = "public, max-age=31536000, immutable"
+	// noCacheControl has the caches revalidate the file on every use, so an upgrade is seen at once
+	noCacheControl = "no-cache"
+)
+
+// cacheControl returns the Cache-Control of the UI file name, a path relative to the build root. The
+// files of the build under static/ have content hashed names and never change, index.html names the
+// hashed files of its build and is revalidated, the other files get no header.
+func cacheControl(name string) string {
+	switch {
+	case strings.HasPrefix(name, "static/"):
+		return immutableCacheControl
+	case name == "" || name == "index.html":
+		return noCacheControl
+	}
+	return ""
+}
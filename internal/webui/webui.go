@@ -0,0 +1,59 @@
+package webui
+
+import (
+	"bytes"
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// assets is the jaeger-ui build, a placeholder index.html unless the build is copied into assets before building
+//
+//go:embed assets
+var assets embed.FS
+
+// baseTag is the base element of the jaeger-ui index.html, jaeger-query rewrites it to its base path as well
+const baseTag = `<base href="/"`
+
+// NewHandler serves the embedded jaeger-ui for a site under basePath (without the trailing slash, empty
+// for the root). The request paths are relative to basePath, the client side routes of the UI such as
+// /search and /trace/:id get the index.html.
+func NewHandler(basePath string) (http.Handler, error) {
+	files, err := fs.Sub(assets, "assets")
+	if err != nil {
+		return nil, err
+	}
+	index, err := fs.ReadFile(files, "index.html")
+	if err != nil {
+		return nil, err
+	}
+	index = bytes.Replace(index, []byte(baseTag), []byte(`<base href="`+basePath+`/"`), 1)
+
+	static := http.FileServer(http.FS(files))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		if name != "" && name != "index.html" {
+			if info, err := fs.Stat(files, name); err == nil && !info.IsDir() {
+				if cc := cacheControl(name); cc != "" {
+					w.Header().Set("Cache-Control", cc)
+				}
+				static.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", cacheControl("index.html"))
+		w.Write(index)
+	}), nil
+}
+
+// BasePath normalizes the configured base path: a leading and no trailing slash, empty for the root.
+func BasePath(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}